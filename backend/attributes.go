@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/attributes"
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+// ColorFamily — цветовая семья для фильтра каталога
+type ColorFamily struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Hex  string `json:"hex,omitempty"`
+}
+
+type AttributeDictionaryResponse struct {
+	ColorFamilies []ColorFamily      `json:"colorFamilies"`
+	Values        []attributes.Value `json:"values"`
+}
+
+// isUniqueViolation — ошибка нарушения уникального ключа PostgreSQL
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// colorFamilies — семьи в порядке словаря. Название и образец семьи берутся
+// у цвета, код которого совпадает с семьей.
+func colorFamilies(values []attributes.Value) []ColorFamily {
+	families := []ColorFamily{}
+	index := make(map[string]int)
+	for _, v := range values {
+		if v.Kind != attributes.KindColor {
+			continue
+		}
+		code := v.Family
+		if code == "" {
+			code = v.Code
+		}
+		i, ok := index[code]
+		if !ok {
+			index[code] = len(families)
+			families = append(families, ColorFamily{Code: code, Name: v.Name, Hex: v.Hex})
+			continue
+		}
+		if v.Code == code {
+			families[i].Name, families[i].Hex = v.Name, v.Hex
+		}
+	}
+	return families
+}
+
+func scanAttributeValue(row interface{ Scan(...any) error }) (attributes.Value, error) {
+	var v attributes.Value
+	err := row.Scan(&v.ID, &v.Kind, &v.Code, &v.Name, &v.Hex, &v.Family, &v.Number,
+		pq.Array(&v.Synonyms), &v.SortOrder)
+	return v, err
+}
+
+func (s *DatabaseStore) getAttributeValues() ([]attributes.Value, error) {
+	rows, err := s.db.Query(`
+		SELECT id, kind, code, name, hex, family, number, synonyms, sort_order
+		FROM attribute_values
+		ORDER BY kind, sort_order, code
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []attributes.Value{}
+	for rows.Next() {
+		v, err := scanAttributeValue(rows)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// synonymsParam — синонимы для записи: без пустых строк, '{}' вместо NULL
+func synonymsParam(v attributes.Value) pq.StringArray {
+	synonyms := pq.StringArray{}
+	for _, s := range v.Synonyms {
+		if s = strings.TrimSpace(s); s != "" {
+			synonyms = append(synonyms, s)
+		}
+	}
+	return synonyms
+}
+
+func (s *DatabaseStore) addAttributeValue(v attributes.Value) (attributes.Value, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO attribute_values (kind, code, name, hex, family, number, synonyms, sort_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, v.Kind, v.Code, v.Name, v.Hex, v.Family, v.Number, synonymsParam(v), v.SortOrder).Scan(&v.ID)
+	return v, err
+}
+
+func (s *DatabaseStore) updateAttributeValue(v attributes.Value) (*attributes.Value, error) {
+	res, err := s.db.Exec(`
+		UPDATE attribute_values
+		SET kind = $2, code = $3, name = $4, hex = $5, family = $6, number = $7,
+		    synonyms = $8, sort_order = $9, updated_at = NOW()
+		WHERE id = $1
+	`, v.ID, v.Kind, v.Code, v.Name, v.Hex, v.Family, v.Number, synonymsParam(v), v.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return &v, nil
+}
+
+func (s *DatabaseStore) deleteAttributeValue(id int64) error {
+	_, err := s.db.Exec("DELETE FROM attribute_values WHERE id = $1", id)
+	return err
+}
+
+// updateMaterialAttributes сохраняет нормализованные цвет, семью, ширину
+// ламели и тип ткани. Возвращает число измененных материалов.
+func (s *DatabaseStore) updateMaterialAttributes(materials []Material) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	updated := 0
+	for _, m := range materials {
+		res, err := tx.Exec(`
+			UPDATE materials
+			SET color = $2, color_family = $3, slat_width_mm = $4, fabric_type = $5, updated_at = NOW()
+			WHERE id = $1
+			  AND (color IS DISTINCT FROM $2 OR color_family <> $3 OR slat_width_mm <> $4 OR fabric_type <> $5)
+		`, m.ID, m.Color, m.ColorFamily, m.SlatWidthMm, m.FabricType)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		updated += int(n)
+	}
+	return updated, tx.Commit()
+}
+
+func (a *App) attributeDictionary() (*attributes.Dictionary, error) {
+	values, err := a.Storage.getAttributeValues()
+	if err != nil {
+		return nil, err
+	}
+	return attributes.New(values), nil
+}
+
+// applyDictionary приводит цвет материала к словарю и заполняет цветовую
+// семью. Ширину ламели и тип ткани, заданные вручную, не трогает.
+// Светопропускаемость материала каталога не меняется.
+func applyDictionary(dict *attributes.Dictionary, m *Material) {
+	sm := supplier.Material{
+		Name:       m.Name,
+		Category:   m.Category,
+		Color:      m.Color,
+		Attributes: map[string]string{"Светопропускаемость": strconv.Itoa(m.LightTransmission)},
+	}
+	dict.Apply(&sm)
+
+	m.Color, m.ColorFamily = sm.Color, sm.ColorFamily
+	if m.SlatWidthMm == 0 {
+		m.SlatWidthMm = sm.SlatWidthMm
+	}
+	if m.FabricType == "" {
+		m.FabricType = sm.FabricType
+	}
+}
+
+func (a *App) normalizeMaterialAttributes(m *Material) error {
+	dict, err := a.attributeDictionary()
+	if err != nil {
+		return err
+	}
+	applyDictionary(dict, m)
+	return nil
+}
+
+// filterCatalog оставляет материалы, подходящие под фильтры каталога:
+// colorFamily (несколько через запятую), fabricType, slatWidth
+func filterCatalog(materials []Material, q map[string][]string) []Material {
+	get := func(key string) string {
+		if v := q[key]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+
+	families := make(map[string]bool)
+	for _, f := range strings.Split(get("colorFamily"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			families[f] = true
+		}
+	}
+	fabric := get("fabricType")
+	slatWidth, _ := strconv.Atoi(get("slatWidth"))
+
+	filtered := []Material{}
+	for _, m := range materials {
+		if len(families) > 0 && !families[m.ColorFamily] {
+			continue
+		}
+		if fabric != "" && m.FabricType != fabric {
+			continue
+		}
+		if slatWidth > 0 && m.SlatWidthMm != slatWidth {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered
+}
+
+// Handlers
+func (a *App) handleAttributes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values, err := a.Storage.getAttributeValues()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, AttributeDictionaryResponse{
+			ColorFamilies: colorFamilies(values),
+			Values:        values,
+		})
+	}
+}
+
+func (a *App) handleCreateAttributeValue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var v attributes.Value
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		created, err := a.Storage.addAttributeValue(v)
+		if isUniqueViolation(err) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "attribute value already exists"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func (a *App) handleUpdateAttributeValue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid attribute ID"})
+			return
+		}
+
+		var v attributes.Value
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		v.ID = id
+		updated, err := a.Storage.updateAttributeValue(v)
+		if isUniqueViolation(err) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "attribute value already exists"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if updated == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "attribute value not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func (a *App) handleDeleteAttributeValue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid attribute ID"})
+			return
+		}
+
+		if err := a.Storage.deleteAttributeValue(id); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// handleApplyAttributes заново нормализует все материалы каталога по словарю,
+// например после добавления синонимов
+func (a *App) handleApplyAttributes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dict, err := a.attributeDictionary()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		materials, err := a.Storage.getMaterials()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+
+		for i := range materials {
+			applyDictionary(dict, &materials[i])
+		}
+		updated, err := a.Storage.updateMaterialAttributes(materials)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"checked": len(materials), "updated": updated})
+	}
+}
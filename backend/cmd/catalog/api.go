@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// apiFlags — адрес backend и токен администратора
+type apiFlags struct {
+	url     string
+	token   string
+	timeout time.Duration
+}
+
+func (f *apiFlags) register(fs *flag.FlagSet) {
+	defaultURL := os.Getenv("CATALOG_API_URL")
+	if defaultURL == "" {
+		defaultURL = "http://localhost:8080"
+	}
+	fs.StringVar(&f.url, "api", defaultURL, "адрес backend (по умолчанию из CATALOG_API_URL)")
+	fs.StringVar(&f.token, "token", os.Getenv("ADMIN_API_TOKEN"), "токен администратора (по умолчанию из ADMIN_API_TOKEN)")
+	fs.DurationVar(&f.timeout, "timeout", 2*time.Minute, "таймаут запроса")
+}
+
+type apiClient struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+func (f *apiFlags) client() *apiClient {
+	return &apiClient{
+		base:   strings.TrimRight(f.url, "/"),
+		token:  f.token,
+		client: &http.Client{Timeout: f.timeout},
+	}
+}
+
+// apiError — ответ backend с кодом ошибки
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Message)
+}
+
+// do выполняет запрос к /api и декодирует JSON-ответ в out
+func (c *apiClient) do(method, path string, query url.Values, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.base + "/api" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			msg = e.Error
+		}
+		return &apiError{Status: resp.StatusCode, Message: msg}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
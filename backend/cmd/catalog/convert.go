@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+)
+
+func runConvert(args []string) int {
+	fs := newFlagSet("convert", "-in прайс.xlsx [-mapping настройки.json] [-out файл]")
+	input := fs.String("in", "", "прайс-лист CSV/XLSX или выгрузка JSON; - — stdin")
+	mappingFile := fs.String("mapping", "", "настройки колонок прайс-листа (например, data/pricelists/intersklad.json)")
+	supplierName := fs.String("supplier", "", "имя поставщика в выгрузке, если его нет в настройках колонок")
+	attributesFile := fs.String("attributes", "", "словарь характеристик, сохраненный из GET /api/attributes")
+	output := fs.String("out", stdio, "файл для выгрузки; - — stdout")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *input == "" {
+		log.Print("не указан -in")
+		fs.Usage()
+		return exitUsage
+	}
+
+	dict, err := loadDictionary(*attributesFile)
+	if err != nil {
+		log.Printf("словарь характеристик: %v", err)
+		return exitError
+	}
+	catalog, err := readInput(*input, *mappingFile)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	if catalog.Supplier == "" {
+		catalog.Supplier = *supplierName
+	}
+	if dict != nil {
+		dict.ApplyAll(catalog.Materials)
+	}
+
+	if err := writeJSONOutput(*output, catalog); err != nil {
+		log.Print(err)
+		return exitError
+	}
+	log.Printf("материалов: %d", len(catalog.Materials))
+	return reportErrors(catalog.Errors)
+}
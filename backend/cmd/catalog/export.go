@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"log"
+	"strconv"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/catalogimport"
+)
+
+// exportColumns — заголовки CSV; совпадают с названиями полей API
+var exportColumns = []string{
+	"id", "supplierCode", "name", "category", "color", "colorFamily", "slatWidthMm",
+	"fabricType", "lightTransmission", "supplierCost", "pricePerM2", "imageUrl",
+}
+
+func formatPrice(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func exportCSV(materials []catalogimport.Existing) ([]byte, error) {
+	var buf bytes.Buffer
+	// BOM — чтобы Excel открыл файл в UTF-8
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.Comma = ';'
+	w.Write(exportColumns)
+	for _, m := range materials {
+		cost := ""
+		if m.SupplierCost != nil {
+			cost = formatPrice(*m.SupplierCost)
+		}
+		slatWidth := ""
+		if m.SlatWidthMm > 0 {
+			slatWidth = strconv.Itoa(m.SlatWidthMm)
+		}
+		w.Write([]string{
+			strconv.FormatInt(m.ID, 10), m.SupplierCode, m.Name, m.Category, m.Color, m.ColorFamily,
+			slatWidth, m.FabricType, strconv.Itoa(m.LightTransmission), cost, formatPrice(m.PricePerM2), m.ImageURL,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func runExport(args []string) int {
+	var api apiFlags
+	fs := newFlagSet("export", "[-api адрес] [-token токен] [-format json|csv] [-out файл]")
+	api.register(fs)
+	format := fs.String("format", "json", "формат: json или csv")
+	output := fs.String("out", stdio, "файл для выгрузки; - — stdout")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *format != "json" && *format != "csv" {
+		log.Printf("неизвестный формат %q", *format)
+		return exitUsage
+	}
+
+	var materials []catalogimport.Existing
+	if err := api.client().do("GET", "/materials", nil, nil, &materials); err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	var err error
+	if *format == "csv" {
+		var data []byte
+		if data, err = exportCSV(materials); err == nil {
+			err = writeOutput(*output, data)
+		}
+	} else {
+		err = writeJSONOutput(*output, materials)
+	}
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	log.Printf("материалов: %d", len(materials))
+	return exitOK
+}
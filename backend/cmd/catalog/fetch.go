@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/httpcache"
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+	_ "github.com/ezhigval/piter-jaluzi/backend/internal/supplier/intersklad"
+)
+
+func runFetch(args []string) int {
+	fs := newFlagSet("fetch", "[-supplier intersklad] [-out файл]")
+	supplierName := fs.String("supplier", "intersklad", "поставщик: "+strings.Join(supplier.Names(), ", "))
+	output := fs.String("out", stdio, "файл для выгрузки; - — stdout")
+	fixturesDir := fs.String("fixtures", "", "читать сохраненные страницы из каталога вместо сайта (например, internal/supplier/intersklad/testdata)")
+	delay := fs.Duration("delay", 2*time.Second, "минимальная пауза между запросами к сайту")
+	maxPages := fs.Int("max-pages", 50, "максимум страниц пагинации на категорию")
+	httpMode := fs.String("http-mode", "normal", "normal — сеть с дисковым кешем, record — записать ответы, replay — только записанные ответы")
+	cacheDir := fs.String("cache-dir", "", "каталог кеша и записанных ответов (по умолчанию .cache/<поставщик>)")
+	cacheMaxAge := fs.Duration("cache-max-age", 0, "сколько отдавать ответ из кеша без перепроверки (0 — всегда перепроверять)")
+	attributesFile := fs.String("attributes", "", "словарь характеристик, сохраненный из GET /api/attributes; без него цвета и типы тканей не нормализуются")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	mode, err := httpcache.ParseMode(*httpMode)
+	if err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	if *cacheDir == "" {
+		*cacheDir = ".cache/" + *supplierName
+	}
+	dict, err := loadDictionary(*attributesFile)
+	if err != nil {
+		log.Printf("словарь характеристик: %v", err)
+		return exitError
+	}
+
+	var fetcher supplier.Fetcher = supplier.NewHTTPFetcher(supplier.HTTPOptions{
+		Delay:    *delay,
+		Mode:     mode,
+		CacheDir: *cacheDir,
+		MaxAge:   *cacheMaxAge,
+	})
+	if *fixturesDir != "" {
+		fetcher = supplier.FixtureFetcher{Dir: *fixturesDir}
+	}
+	src, err := supplier.New(*supplierName, supplier.Options{Fetcher: fetcher, MaxPages: *maxPages})
+	if err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+
+	if *fixturesDir != "" {
+		log.Printf("%s: режим фикстур %s", src.Name(), *fixturesDir)
+	} else {
+		log.Printf("%s: режим HTTP %s, кеш %s", src.Name(), mode, *cacheDir)
+	}
+
+	catalog, err := supplier.Scrape(context.Background(), src)
+	if err != nil {
+		log.Printf("ошибка получения категорий: %v", err)
+		return exitError
+	}
+	if dict != nil {
+		dict.ApplyAll(catalog.Materials)
+	}
+	if len(catalog.Materials) == 0 {
+		reportErrors(catalog.Errors)
+		log.Print("ни один материал не разобран")
+		return exitError
+	}
+
+	if err := writeJSONOutput(*output, catalog); err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	categoryStats := make(map[string]int)
+	for _, m := range catalog.Materials {
+		categoryStats[m.Category]++
+	}
+	categories := make([]string, 0, len(categoryStats))
+	for category := range categoryStats {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	log.Printf("материалов: %d", len(catalog.Materials))
+	for _, category := range categories {
+		log.Printf("  %s: %d", category, categoryStats[category])
+	}
+	return reportErrors(catalog.Errors)
+}
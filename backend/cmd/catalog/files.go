@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/attributes"
+	"github.com/ezhigval/piter-jaluzi/backend/internal/catalogimport"
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+// stdio — путь "-" означает stdin или stdout
+const stdio = "-"
+
+func openInput(path string) (io.ReadCloser, error) {
+	if path == stdio {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// writeOutput записывает данные в файл или stdout
+func writeOutput(path string, data []byte) error {
+	if path == stdio {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	log.Printf("сохранено в %s", path)
+	return nil
+}
+
+func writeJSONOutput(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, append(data, '\n'))
+}
+
+// isPriceList — файл похож на прайс-лист, а не на выгрузку в JSON
+func isPriceList(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".txt", ".xlsx", ".xls":
+		return true
+	}
+	return false
+}
+
+// readInput читает выгрузку каталога (JSON) или прайс-лист поставщика.
+// Прайс-лист разбирается по настройкам колонок из mappingFile.
+func readInput(path, mappingFile string) (supplier.Catalog, error) {
+	f, err := openInput(path)
+	if err != nil {
+		return supplier.Catalog{}, err
+	}
+	defer f.Close()
+
+	if mappingFile == "" && !isPriceList(path) {
+		catalog, err := catalogimport.ReadJSON(f)
+		if err != nil {
+			return catalog, fmt.Errorf("%s: %w", path, err)
+		}
+		return catalog, nil
+	}
+
+	mapping := catalogimport.DefaultMapping()
+	if mappingFile != "" {
+		if mapping, err = catalogimport.LoadMapping(mappingFile); err != nil {
+			return supplier.Catalog{}, err
+		}
+	}
+	catalog, err := catalogimport.ReadPriceList(f, filepath.Base(path), mapping)
+	if err != nil {
+		return catalog, fmt.Errorf("%s: %w", path, err)
+	}
+	return catalog, nil
+}
+
+// loadDictionary читает словарь характеристик; пустой путь — без словаря
+func loadDictionary(path string) (*attributes.Dictionary, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return attributes.Load(f)
+}
+
+// reportErrors выводит отброшенные строки и возвращает код выхода
+func reportErrors(errs []supplier.ItemError) int {
+	if len(errs) == 0 {
+		return exitOK
+	}
+	log.Printf("не разобрано: %d", len(errs))
+	for _, e := range errs {
+		log.Printf("  %s: %s", e.URL, e.Error)
+	}
+	return exitRejected
+}
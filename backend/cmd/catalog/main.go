@@ -0,0 +1,102 @@
+// Команда catalog — инструменты каталога поставщиков: загрузка с сайта,
+// преобразование прайс-листов, сравнение с каталогом и импорт в работающий
+// backend, выгрузка каталога.
+//
+// Данные пишутся в stdout или в файл из -out, сообщения — в stderr, поэтому
+// команды можно соединять через конвейер:
+//
+//	catalog fetch | catalog import -in -
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Коды выхода
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+	// exitRejected — команда выполнена, но часть строк выгрузки отброшена
+	exitRejected = 3
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{"fetch", "загрузить каталог с сайта поставщика", runFetch},
+	{"convert", "преобразовать прайс-лист CSV/XLSX в выгрузку каталога", runConvert},
+	{"diff", "сравнить выгрузку с каталогом backend, ничего не меняя", runDiff},
+	{"import", "применить выгрузку к каталогу backend", runImport},
+	{"export", "выгрузить каталог backend в JSON или CSV", runExport},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Использование: catalog <команда> [флаги]")
+	fmt.Fprintln(os.Stderr, "\nКоманды:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nФлаги команды: catalog <команда> -h")
+	fmt.Fprintln(os.Stderr, "Коды выхода: 0 — успех, 1 — ошибка, 2 — неверные аргументы, 3 — часть строк отброшена")
+}
+
+// newFlagSet — флаги подкоманды; ошибки разбора возвращаются, а не
+// завершают программу
+func newFlagSet(name, usageLine string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Использование: catalog %s %s\n\nФлаги:\n", name, usageLine)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags разбирает флаги; при ошибке возвращает код выхода
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK, false
+	}
+	if err != nil {
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		log.Printf("лишние аргументы: %v", fs.Args())
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("catalog: ")
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage()
+		os.Exit(exitOK)
+	}
+	for _, c := range commands {
+		if c.name == name {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+	log.Printf("неизвестная команда %q", name)
+	usage()
+	os.Exit(exitUsage)
+}
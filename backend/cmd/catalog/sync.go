@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/catalogimport"
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+// syncFlags — общие флаги diff и import
+type syncFlags struct {
+	api         apiFlags
+	input       string
+	mappingFile string
+	threshold   float64
+	prefix      string
+	notify      bool
+	output      string
+}
+
+func (f *syncFlags) register(fs *flag.FlagSet) {
+	f.api.register(fs)
+	fs.StringVar(&f.input, "in", "", "выгрузка JSON или прайс-лист CSV/XLSX; - — stdin")
+	fs.StringVar(&f.mappingFile, "mapping", "", "настройки колонок прайс-листа (например, data/pricelists/intersklad.json)")
+	fs.Float64Var(&f.threshold, "threshold", 1, "порог изменения закупочной цены, %")
+	fs.StringVar(&f.prefix, "prefix", "", "префикс артикулов поставщика (по умолчанию определяется по выгрузке)")
+	fs.BoolVar(&f.notify, "notify", false, "отправить отчет подписчикам Telegram")
+	fs.StringVar(&f.output, "out", "", "сохранить полный ответ backend в JSON; - — stdout")
+}
+
+func (f *syncFlags) parse(fs *flag.FlagSet, args []string) (int, bool) {
+	code, ok := parseFlags(fs, args)
+	if !ok {
+		return code, false
+	}
+	if f.input == "" {
+		log.Print("не указан -in")
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+func (f *syncFlags) query() url.Values {
+	q := url.Values{}
+	q.Set("threshold", strconv.FormatFloat(f.threshold, 'f', -1, 64))
+	if f.prefix != "" {
+		q.Set("prefix", f.prefix)
+	}
+	if f.notify {
+		q.Set("notify", "true")
+	}
+	return q
+}
+
+// load читает выгрузку; прайс-лист разбирается здесь, на backend
+// уходит JSON
+func (f *syncFlags) load() (supplier.Catalog, bool) {
+	catalog, err := readInput(f.input, f.mappingFile)
+	if err != nil {
+		log.Print(err)
+		return catalog, false
+	}
+	if len(catalog.Materials) == 0 {
+		reportErrors(catalog.Errors)
+		log.Print("в выгрузке нет материалов")
+		return catalog, false
+	}
+	return catalog, true
+}
+
+// planResponse — ответ POST /api/import/plan
+type planResponse struct {
+	catalogimport.Plan
+	Summary     string `json:"summary"`
+	NotifyError string `json:"notifyError,omitempty"`
+}
+
+// applyResponse — ответ POST /api/import/apply
+type applyResponse struct {
+	DryRun      bool                      `json:"dryRun"`
+	Counts      catalogimport.ApplyCounts `json:"counts"`
+	Errors      []supplier.ItemError      `json:"errors,omitempty"`
+	Summary     string                    `json:"summary"`
+	NotifyError string                    `json:"notifyError,omitempty"`
+}
+
+// post отправляет выгрузку на backend и при -out сохраняет ответ целиком
+func (f *syncFlags) post(path string, q url.Values, catalog supplier.Catalog, out any) bool {
+	var raw json.RawMessage
+	if err := f.api.client().do("POST", path, q, catalog, &raw); err != nil {
+		log.Print(err)
+		return false
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		log.Printf("POST %s: %v", path, err)
+		return false
+	}
+	if f.output != "" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, raw, "", "  "); err != nil {
+			log.Print(err)
+			return false
+		}
+		indented.WriteByte('\n')
+		if err := writeOutput(f.output, indented.Bytes()); err != nil {
+			log.Print(err)
+			return false
+		}
+	}
+	return true
+}
+
+// printSummary выводит отчет backend. Если полный ответ идет в stdout,
+// отчет уходит в stderr.
+func (f *syncFlags) printSummary(summary, notifyError string) {
+	if f.output == stdio {
+		log.Print(summary)
+	} else {
+		fmt.Println(summary)
+	}
+	if notifyError != "" {
+		log.Printf("отчет в Telegram не отправлен: %s", notifyError)
+	}
+}
+
+func runDiff(args []string) int {
+	var f syncFlags
+	fs := newFlagSet("diff", "-in выгрузка.json [-api адрес] [-exit-code]")
+	f.register(fs)
+	exitCode := fs.Bool("exit-code", false, "завершиться с кодом 1, если применение что-то изменит")
+	if code, ok := f.parse(fs, args); !ok {
+		return code
+	}
+
+	catalog, ok := f.load()
+	if !ok {
+		return exitError
+	}
+	var resp planResponse
+	if !f.post("/import/plan", f.query(), catalog, &resp) {
+		return exitError
+	}
+	f.printSummary(resp.Summary, resp.NotifyError)
+
+	if *exitCode && resp.HasChanges() {
+		return exitError
+	}
+	return reportErrors(resp.Errors)
+}
+
+func runImport(args []string) int {
+	var f syncFlags
+	fs := newFlagSet("import", "-in выгрузка.json [-api адрес] [-token токен] [-dry-run]")
+	f.register(fs)
+	dryRun := fs.Bool("dry-run", false, "посчитать изменения без записи в каталог")
+	if code, ok := f.parse(fs, args); !ok {
+		return code
+	}
+
+	catalog, ok := f.load()
+	if !ok {
+		return exitError
+	}
+	q := f.query()
+	if *dryRun {
+		q.Set("dryRun", "true")
+	}
+	var resp applyResponse
+	if !f.post("/import/apply", q, catalog, &resp) {
+		return exitError
+	}
+	f.printSummary(resp.Summary, resp.NotifyError)
+	return reportErrors(resp.Errors)
+}
@@ -1,459 +0,0 @@
-package main
-
-import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"os"
-	"strings"
-	"time"
-)
-
-// InterskladMaterial представляет материал с intersklad.ru
-type InterskladMaterial struct {
-	SupplierCode      string  `json:"supplierCode"`
-	Name              string  `json:"name"`
-	Category          string  `json:"category"`
-	Color             string  `json:"color"`
-	LightTransmission int     `json:"lightTransmission"`
-	PricePerM2        float64 `json:"pricePerM2"`
-	ImageURL          string  `json:"imageUrl"`
-	Description       string  `json:"description"`
-}
-
-// MaterialData для хранения спарсенных данных
-type MaterialData struct {
-	Materials []InterskladMaterial `json:"materials"`
-	Source    string               `json:"source"`
-	ParsedAt  string               `json:"parsedAt"`
-}
-
-// parseCategory генерирует материалы для категории на основе анализа сайта
-func parseCategory(categoryURL string) ([]InterskladMaterial, error) {
-	categoryName := extractCategoryName(categoryURL)
-
-	// Генерируем реалистичные материалы на основе анализа intersklad.ru
-	var materials []InterskladMaterial
-
-	switch categoryName {
-	case "Горизонтальные жалюзи":
-		materials = generateHorizontalMaterials()
-	case "Вертикальные жалюзи":
-		materials = generateVerticalMaterials()
-	case "Рулонные шторы":
-		materials = generateRollerMaterials()
-	default:
-		materials = generateSampleMaterials()
-	}
-
-	return materials, nil
-}
-
-// generateHorizontalMaterials генерирует горизонтальные жалюзи
-func generateHorizontalMaterials() []InterskladMaterial {
-	return []InterskladMaterial{
-		{
-			SupplierCode:      "INT-HOR-ALU-25-WHITE",
-			Name:              "Алюминиевые горизонтальные жалюзи 25мм, белый",
-			Category:          "Горизонтальные жалюзи",
-			Color:             "Белый",
-			LightTransmission: 70,
-			PricePerM2:        450,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/123/horizontal_white.jpg",
-			Description:       "Классические алюминиевые жалюзи 25мм для офисов и домов",
-		},
-		{
-			SupplierCode:      "INT-HOR-ALU-25-BROWN",
-			Name:              "Алюминиевые горизонтальные жалюзи 25мм, коричневый",
-			Category:          "Горизонтальные жалюзи",
-			Color:             "Коричневый",
-			LightTransmission: 65,
-			PricePerM2:        480,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/124/horizontal_brown.jpg",
-			Description:       "Алюминиевые жалюзи в классическом коричневом цвете",
-		},
-		{
-			SupplierCode:      "INT-HOR-ALU-16-SILVER",
-			Name:              "Алюминиевые горизонтальные жалюзи 16мм, серебро",
-			Category:          "Горизонтальные жалюзи",
-			Color:             "Серебряный",
-			LightTransmission: 75,
-			PricePerM2:        520,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/125/horizontal_silver.jpg",
-			Description:       "Тонкие алюминиевые жалюзи 16мм с перламутровым покрытием",
-		},
-		{
-			SupplierCode:      "INT-HOR-WOOD-50-OAK",
-			Name:              "Деревянные горизонтальные жалюзи 50мм, дуб",
-			Category:          "Горизонтальные жалюзи",
-			Color:             "Дуб",
-			LightTransmission: 40,
-			PricePerM2:        1200,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/126/wooden_oak.jpg",
-			Description:       "Экологичные деревянные жалюзи из натурального дуба",
-		},
-		{
-			SupplierCode:      "INT-HOR-WOOD-50-WENGE",
-			Name:              "Деревянные горизонтальные жалюзи 50мм, венге",
-			Category:          "Горизонтальные жалюзи",
-			Color:             "Венге",
-			LightTransmission: 35,
-			PricePerM2:        1350,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/127/wooden_wenge.jpg",
-			Description:       "Деревянные жалюзи в темном цвете венге с защитным покрытием",
-		},
-	}
-}
-
-// generateVerticalMaterials генерирует вертикальные жалюзи
-func generateVerticalMaterials() []InterskladMaterial {
-	return []InterskladMaterial{
-		{
-			SupplierCode:      "INT-VERT-TEXTURE-89-BEIGE",
-			Name:              "Вертикальные тканевые жалюзи 89мм, бежевый",
-			Category:          "Вертикальные жалюзи",
-			Color:             "Бежевый",
-			LightTransmission: 50,
-			PricePerM2:        650,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/128/vertical_beige.jpg",
-			Description:       "Тканевые вертикальные жалюзи с текстурой, 89мм",
-		},
-		{
-			SupplierCode:      "INT-VERT-TEXTURE-89-GREY",
-			Name:              "Вертикальные тканевые жалюзи 89мм, серый",
-			Category:          "Вертикальные жалюзи",
-			Color:             "Серый",
-			LightTransmission: 45,
-			PricePerM2:        680,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/129/vertical_grey.jpg",
-			Description:       "Современные тканевые жалюзи в сером цвете",
-		},
-		{
-			SupplierCode:      "INT-VERT-POLYESTER-89-BLUE",
-			Name:              "Вертикальные жалюзи из полиэстера 89мм, синий",
-			Category:          "Вертикальные жалюзи",
-			Color:             "Синий",
-			LightTransmission: 60,
-			PricePerM2:        550,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/130/vertical_blue.jpg",
-			Description:       "Полиэстеровые вертикальные жалюзи с защитой от выцветания",
-		},
-		{
-			SupplierCode:      "INT-VERT-JACQUARD-89-GREEN",
-			Name:              "Вертикальные жалюзи жаккард 89мм, зеленый",
-			Category:          "Вертикальные жалюзи",
-			Color:             "Зеленый",
-			LightTransmission: 40,
-			PricePerM2:        850,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/131/vertical_green.jpg",
-			Description:       "Премиальные жаккардовые жалюзи с узором",
-		},
-		{
-			SupplierCode:      "INT-VERT-PVC-89-WHITE",
-			Name:              "Вертикальные жалюзи из ПВХ 89мм, белый",
-			Category:          "Вертикальные жалюзи",
-			Color:             "Белый",
-			LightTransmission: 80,
-			PricePerM2:        420,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/132/vertical_pvc.jpg",
-			Description:       "Практичные ПВХ жалюзи для влажных помещений",
-		},
-	}
-}
-
-// generateRollerMaterials генерирует рулонные шторы
-func generateRollerMaterials() []InterskladMaterial {
-	return []InterskladMaterial{
-		{
-			SupplierCode:      "INT-ROLLER-BLACKOUT-GREY",
-			Name:              "Рулонные шторы блэкаут, серый",
-			Category:          "Рулонные шторы",
-			Color:             "Серый",
-			LightTransmission: 0,
-			PricePerM2:        850,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/133/roller_blackout.jpg",
-			Description:       "Полнозатемняющие рулонные шторы блэкаут",
-		},
-		{
-			SupplierCode:      "INT-ROLLER-ZEBRA-WHITE",
-			Name:              "Рулонные шторы зебра, бело-серый",
-			Category:          "Рулонные шторы",
-			Color:             "Бело-серый",
-			LightTransmission: 30,
-			PricePerM2:        950,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/134/roller_zebra.jpg",
-			Description:       "Современные рулонные шторы зебра с двойным полотном",
-		},
-		{
-			SupplierCode:      "INT-ROLLER-TRANSPARENT-BEIGE",
-			Name:              "Прозрачные рулонные шторы, бежевый",
-			Category:          "Рулонные шторы",
-			Color:             "Бежевый",
-			LightTransmission: 85,
-			PricePerM2:        450,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/135/roller_transparent.jpg",
-			Description:       "Легкие прозрачные шторы для защиты от яркого солнца",
-		},
-		{
-			SupplierCode:      "INT-ROLLER-DIMOUT-BLUE",
-			Name:              "Рулонные шторы димаут, синий",
-			Category:          "Рулонные шторы",
-			Color:             "Синий",
-			LightTransmission: 15,
-			PricePerM2:        750,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/136/roller_dimout.jpg",
-			Description:       "Полупрозрачные шторы димаут для мягкого рассеивания света",
-		},
-		{
-			SupplierCode:      "INT-ROLLER-DAY-NIGHT-WHITE",
-			Name:              "Рулонные шторы день-ночь, белый",
-			Category:          "Рулонные шторы",
-			Color:             "Белый",
-			LightTransmission: 25,
-			PricePerM2:        1100,
-			ImageURL:          "https://www.intersklad.ru/upload/iblock/137/roller_daynight.jpg",
-			Description:       "Универсальные шторы день-ночь с регулировкой света",
-		},
-	}
-}
-
-// extractCategoryName извлекает название категории из URL
-func extractCategoryName(url string) string {
-	if strings.Contains(url, "gorizontalnaya") {
-		return "Горизонтальные жалюзи"
-	} else if strings.Contains(url, "vertikalnaya") {
-		return "Вертикальные жалюзи"
-	} else if strings.Contains(url, "rulonnaya") {
-		return "Рулонные шторы"
-	}
-	return "Другие комплектующие"
-}
-
-// parsePrice парсит цену из текста
-func parsePrice(priceText string) float64 {
-	priceText = strings.ToLower(priceText)
-	priceText = strings.ReplaceAll(priceText, "руб.", "")
-	priceText = strings.ReplaceAll(priceText, "р", "")
-	priceText = strings.ReplaceAll(priceText, " ", "")
-
-	var price float64
-	fmt.Sscanf(priceText, "%f", &price)
-
-	// Если цена не найдена, устанавливаем среднюю цену по категории
-	if price == 0 {
-		price = 500 + (float64(time.Now().Unix() % 1000)) // Случайная цена для примера
-	}
-
-	return price
-}
-
-// extractColorFromName извлекает цвет из названия
-func extractColorFromName(name string) string {
-	name = strings.ToLower(name)
-
-	colors := map[string]string{
-		"белый":      "Белый",
-		"черный":     "Черный",
-		"серый":      "Серый",
-		"бежевый":    "Бежевый",
-		"коричневый": "Коричневый",
-		"зеленый":    "Зеленый",
-		"синий":      "Синий",
-		"красный":    "Красный",
-		"желтый":     "Желтый",
-		"золотой":    "Золотой",
-		"прозрачный": "Прозрачный",
-	}
-
-	for colorKey, colorValue := range colors {
-		if strings.Contains(name, colorKey) {
-			return colorValue
-		}
-	}
-
-	return "Разноцветный"
-}
-
-// determineLightTransmission определяет светопропускаемость
-func determineLightTransmission(name, category string) int {
-	name = strings.ToLower(name)
-
-	// Блэкаут ткани
-	if strings.Contains(name, "blackout") || strings.Contains(name, "блэкаут") {
-		return 0
-	}
-
-	// Прозрачные материалы
-	if strings.Contains(name, "прозрачный") || strings.Contains(name, "transparent") {
-		return 90
-	}
-
-	// Полупрозрачные
-	if strings.Contains(name, "полупрозрачный") || strings.Contains(name, "light") {
-		return 70
-	}
-
-	// По умолчанию в зависимости от категории
-	switch category {
-	case "Горизонтальные жалюзи":
-		return 60
-	case "Вертикальные жалюзи":
-		return 50
-	case "Рулонные шторы":
-		return 40
-	default:
-		return 50
-	}
-}
-
-// generateSampleMaterials генерирует примерные материалы на основе реальных данных
-func generateSampleMaterials() []InterskladMaterial {
-	return []InterskladMaterial{
-		{
-			SupplierCode:      "INT-HOR-ALU-25",
-			Name:              "Алюминиевые горизонтальные жалюзи 25мм, белый",
-			Category:          "Горизонтальные жалюзи",
-			Color:             "Белый",
-			LightTransmission: 70,
-			PricePerM2:        450,
-			ImageURL:          "https://www.intersklad.ru/images/horizontal-white.jpg",
-			Description:       "Классические алюминиевые жалюзи для офисов и домов",
-		},
-		{
-			SupplierCode:      "INT-VERT-TEXTURE-89",
-			Name:              "Вертикальные тканевые жалюзи 89мм, бежевый",
-			Category:          "Вертикальные жалюзи",
-			Color:             "Бежевый",
-			LightTransmission: 50,
-			PricePerM2:        650,
-			ImageURL:          "https://www.intersklad.ru/images/vertical-beige.jpg",
-			Description:       "Тканевые вертикальные жалюзи с текстурой",
-		},
-		{
-			SupplierCode:      "INT-ROLLER-BLACKOUT",
-			Name:              "Рулонные шторы блэкаут, серый",
-			Category:          "Рулонные шторы",
-			Color:             "Серый",
-			LightTransmission: 0,
-			PricePerM2:        850,
-			ImageURL:          "https://www.intersklad.ru/images/roller-grey.jpg",
-			Description:       "Полнозатемняющие рулонные шторы",
-		},
-		{
-			SupplierCode:      "INT-HOR-WOOD-50",
-			Name:              "Деревянные горизонтальные жалюзи 50мм, натуральное дерево",
-			Category:          "Горизонтальные жалюзи",
-			Color:             "Коричневый",
-			LightTransmission: 40,
-			PricePerM2:        1200,
-			ImageURL:          "https://www.intersklad.ru/images/wooden-brown.jpg",
-			Description:       "Экологичные деревянные жалюзи из натурального дерева",
-		},
-		{
-			SupplierCode:      "INT-VERT-POLYESTER",
-			Name:              "Вертикальные жалюзи из полиэстера, синий",
-			Category:          "Вертикальные жалюзи",
-			Color:             "Синий",
-			LightTransmission: 60,
-			PricePerM2:        550,
-			ImageURL:          "https://www.intersklad.ru/images/vertical-blue.jpg",
-			Description:       "Полиэстеровые вертикальные жалюзи с защитой от выцветания",
-		},
-		{
-			SupplierCode:      "INT-ROLLER-ZEBRA",
-			Name:              "Рулонные шторы зебра, черно-белый",
-			Category:          "Рулонные шторы",
-			Color:             "Черно-белый",
-			LightTransmission: 30,
-			PricePerM2:        950,
-			ImageURL:          "https://www.intersklad.ru/images/roller-zebra.jpg",
-			Description:       "Современные рулонные шторы зебра с двойным полотном",
-		},
-	}
-}
-
-func main() {
-	fmt.Println("Парсер каталога Intersklad.ru для Jaluxi")
-	fmt.Println("==========================================")
-
-	// Базовые URL категорий
-	categories := []string{
-		"https://www.intersklad.ru/catalog/gorizontalnaya-komplektatsiya/",
-		"https://www.intersklad.ru/catalog/vertikalnaya-komplektatsiya/",
-		"https://www.intersklad.ru/catalog/rulonnaya-komplektatsiya/",
-	}
-
-	var allMaterials []InterskladMaterial
-
-	// Парсим каждую категорию
-	for _, categoryURL := range categories {
-		fmt.Printf("Парсинг категории: %s\n", categoryURL)
-
-		materials, err := parseCategory(categoryURL)
-		if err != nil {
-			fmt.Printf("Ошибка парсинга категории %s: %v\n", categoryURL, err)
-			// Если парсинг не удался, используем примерные данные
-			fmt.Println("Используем сгенерированные примерные данные...")
-			materials = generateSampleMaterials()
-		}
-
-		allMaterials = append(allMaterials, materials...)
-		time.Sleep(1 * time.Second) // Задержка между запросами
-	}
-
-	// Если материалов мало, добавляем примерные
-	if len(allMaterials) < 10 {
-		fmt.Println("Добавляем примерные материалы для демонстрации...")
-		sampleMaterials := generateSampleMaterials()
-		allMaterials = append(allMaterials, sampleMaterials...)
-	}
-
-	// Создаем структуру данных
-	data := MaterialData{
-		Materials: allMaterials,
-		Source:    "https://www.intersklad.ru/",
-		ParsedAt:  time.Now().Format("2006-01-02 15:04:05"),
-	}
-
-	// Сохраняем в JSON файл
-	outputFile := "intersklad_materials.json"
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		log.Fatalf("Ошибка сериализации: %v", err)
-	}
-
-	err = os.WriteFile(outputFile, jsonData, 0644)
-	if err != nil {
-		log.Fatalf("Ошибка записи файла: %v", err)
-	}
-
-	fmt.Printf("\nДанные сохранены в файл: %s\n", outputFile)
-	fmt.Printf("Всего материалов: %d\n", len(allMaterials))
-
-	// Выводим статистику по категориям
-	categoryStats := make(map[string]int)
-	for _, material := range allMaterials {
-		categoryStats[material.Category]++
-	}
-
-	fmt.Println("\nСтатистика по категориям:")
-	for category, count := range categoryStats {
-		fmt.Printf("  %s: %d материалов\n", category, count)
-	}
-
-	// Пример материала
-	if len(allMaterials) > 0 {
-		fmt.Println("\nПример материала:")
-		m := allMaterials[0]
-		fmt.Printf("  Артикул: %s\n", m.SupplierCode)
-		fmt.Printf("  Название: %s\n", m.Name)
-		fmt.Printf("  Категория: %s\n", m.Category)
-		fmt.Printf("  Цвет: %s\n", m.Color)
-		fmt.Printf("  Светопропускаемость: %d%%\n", m.LightTransmission)
-		fmt.Printf("  Цена за м²: %.0f ₽\n", m.PricePerM2)
-		fmt.Printf("  Описание: %s\n", m.Description)
-	}
-
-	fmt.Println("\nДля загрузки данных в БД используйте:")
-	fmt.Printf("curl -X POST http://localhost:8080/api/materials -H 'Content-Type: application/json' -d @%s\n", outputFile)
-}
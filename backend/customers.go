@@ -0,0 +1,464 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+var errMergeSelf = errors.New("cannot merge customer with itself")
+
+// customerLinkedTables — таблицы со ссылкой customer_id; при объединении
+// клиентов их записи переходят к оставшемуся клиенту
+var customerLinkedTables = []string{"leads", "quotes", "orders"}
+
+// Customer — клиент, определяется по нормализованному телефону. Заявки с
+// того же номера привязываются к нему автоматически.
+type Customer struct {
+	ID          int64     `json:"id"`
+	Phone       string    `json:"phone"`
+	ExtraPhones []string  `json:"extraPhones"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email,omitempty"`
+	Addresses   []string  `json:"addresses"`
+	Note        string    `json:"note,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	// LeadsCount и LastLeadAt считаются по привязанным заявкам
+	LeadsCount int        `json:"leadsCount"`
+	LastLeadAt *time.Time `json:"lastLeadAt,omitempty"`
+}
+
+type CustomerUpdateRequest struct {
+	Name      string   `json:"name" validate:"max=200"`
+	Email     string   `json:"email" validate:"omitempty,email,max=200"`
+	Addresses []string `json:"addresses" validate:"max=20,dive,max=500"`
+	Note      string   `json:"note" validate:"max=5000"`
+}
+
+type CustomerMergeRequest struct {
+	DuplicateID int64 `json:"duplicateId" validate:"required,gt=0"`
+}
+
+// CustomerCard — клиент со всей историей обращений и заказов
+type CustomerCard struct {
+	Customer Customer `json:"customer"`
+	Leads    []Lead   `json:"leads"`
+	Quotes   []Quote  `json:"quotes"`
+	Orders   []Order  `json:"orders"`
+}
+
+// DuplicateCandidate — пара клиентов, похожих на одного человека
+type DuplicateCandidate struct {
+	CustomerID  int64  `json:"customerId"`
+	DuplicateID int64  `json:"duplicateId"`
+	Match       string `json:"match"`
+}
+
+// upsertCustomerTx находит клиента по телефону (включая телефоны
+// объединенных клиентов) или создает нового и дополняет его имя, email
+// и адреса данными обращения. Возвращает ID клиента.
+func upsertCustomerTx(tx *sql.Tx, phone, name, email, address string) (int64, error) {
+	var id int64
+	err := tx.QueryRow("SELECT id FROM customers WHERE $1 = ANY(extra_phones) LIMIT 1", phone).Scan(&id)
+	switch {
+	case err == nil:
+		_, err = tx.Exec(`
+			UPDATE customers
+			SET name = COALESCE(NULLIF($2, ''), name),
+			    email = COALESCE(NULLIF($3, ''), email),
+			    addresses = CASE WHEN $4 = '' OR $4 = ANY(addresses) THEN addresses ELSE array_append(addresses, $4) END,
+			    updated_at = NOW()
+			WHERE id = $1
+		`, id, name, email, address)
+		return id, err
+	case err != sql.ErrNoRows:
+		return 0, err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO customers (phone, name, email, addresses)
+		VALUES ($1, $2, $3, CASE WHEN $4 = '' THEN '{}'::TEXT[] ELSE ARRAY[$4] END)
+		ON CONFLICT (phone) DO UPDATE
+		SET name = COALESCE(NULLIF(EXCLUDED.name, ''), customers.name),
+		    email = COALESCE(NULLIF(EXCLUDED.email, ''), customers.email),
+		    addresses = CASE WHEN $4 = '' OR $4 = ANY(customers.addresses) THEN customers.addresses
+		                     ELSE array_append(customers.addresses, $4) END,
+		    updated_at = NOW()
+		RETURNING id
+	`, phone, name, email, address).Scan(&id)
+	return id, err
+}
+
+// Customers
+const customerColumns = `c.id, c.phone, c.extra_phones, c.name, c.email, c.addresses, c.note, c.created_at, c.updated_at,
+	(SELECT COUNT(*) FROM leads l WHERE l.customer_id = c.id),
+	(SELECT MAX(l.created_at) FROM leads l WHERE l.customer_id = c.id)`
+
+func scanCustomer(row interface{ Scan(...any) error }) (Customer, error) {
+	var c Customer
+	err := row.Scan(&c.ID, &c.Phone, pq.Array(&c.ExtraPhones), &c.Name, &c.Email, pq.Array(&c.Addresses), &c.Note,
+		&c.CreatedAt, &c.UpdatedAt, &c.LeadsCount, &c.LastLeadAt)
+	if c.ExtraPhones == nil {
+		c.ExtraPhones = []string{}
+	}
+	if c.Addresses == nil {
+		c.Addresses = []string{}
+	}
+	return c, err
+}
+
+func (s *DatabaseStore) findCustomer(id int64) (*Customer, error) {
+	c, err := scanCustomer(s.db.QueryRow("SELECT "+customerColumns+" FROM customers c WHERE c.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// getCustomers ищет клиентов по телефону (точно, с учетом объединенных)
+// или по части имени и email; пустые параметры не ограничивают
+func (s *DatabaseStore) getCustomers(phone, search string, limit, offset int) ([]Customer, error) {
+	rows, err := s.db.Query(`
+		SELECT `+customerColumns+` FROM customers c
+		WHERE ($1 = '' OR c.phone = $1 OR $1 = ANY(c.extra_phones))
+		  AND ($2 = '' OR c.name ILIKE '%' || $2 || '%' OR c.email ILIKE '%' || $2 || '%')
+		ORDER BY c.updated_at DESC, c.id DESC
+		LIMIT $3 OFFSET $4
+	`, phone, search, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	customers := []Customer{}
+	for rows.Next() {
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
+func (s *DatabaseStore) updateCustomer(id int64, in CustomerUpdateRequest) (*Customer, error) {
+	addresses := []string{}
+	for _, a := range in.Addresses {
+		if a = strings.TrimSpace(a); a != "" {
+			addresses = append(addresses, a)
+		}
+	}
+	res, err := s.db.Exec(`
+		UPDATE customers SET name = $2, email = $3, addresses = $4, note = $5, updated_at = NOW()
+		WHERE id = $1
+	`, id, strings.TrimSpace(in.Name), strings.TrimSpace(in.Email), pq.Array(addresses), in.Note)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return s.findCustomer(id)
+}
+
+func (s *DatabaseStore) getCustomerLeads(customerID int64) ([]Lead, error) {
+	rows, err := s.db.Query("SELECT "+leadColumns+" FROM leads WHERE customer_id = $1 ORDER BY created_at DESC, id DESC", customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leads := []Lead{}
+	for rows.Next() {
+		l, err := scanLead(rows)
+		if err != nil {
+			return nil, err
+		}
+		leads = append(leads, l)
+	}
+	return leads, rows.Err()
+}
+
+func (s *DatabaseStore) getCustomerQuotes(customerID int64) ([]Quote, error) {
+	rows, err := s.db.Query("SELECT "+quoteColumns+" FROM quotes WHERE customer_id = $1 ORDER BY created_at DESC", customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotes := []Quote{}
+	for rows.Next() {
+		q, err := scanQuote(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, rows.Err()
+}
+
+// mergeCustomers переносит к клиенту targetID все обращения дубликата, его
+// телефоны, адреса и недостающие имя и email, а дубликат удаляет.
+// Возвращает nil, если кого-то из двух нет.
+func (s *DatabaseStore) mergeCustomers(targetID, duplicateID int64) (*Customer, error) {
+	if targetID == duplicateID {
+		return nil, errMergeSelf
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка в порядке ID, чтобы встречные объединения не зависли
+	var locked int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM (SELECT id FROM customers WHERE id IN ($1, $2) ORDER BY id FOR UPDATE) c
+	`, targetID, duplicateID).Scan(&locked)
+	if err != nil {
+		return nil, err
+	}
+	if locked != 2 {
+		return nil, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE customers t
+		SET extra_phones = ARRAY(
+		        SELECT DISTINCT p FROM unnest(t.extra_phones || d.phone::TEXT || d.extra_phones) p WHERE p <> t.phone
+		    ),
+		    name = CASE WHEN t.name = '' THEN d.name ELSE t.name END,
+		    email = CASE WHEN t.email = '' THEN d.email ELSE t.email END,
+		    addresses = t.addresses || ARRAY(
+		        SELECT a FROM unnest(d.addresses) a WHERE NOT a = ANY(t.addresses)
+		    ),
+		    note = CASE WHEN d.note = '' THEN t.note WHEN t.note = '' THEN d.note ELSE t.note || E'\n' || d.note END,
+		    updated_at = NOW()
+		FROM customers d
+		WHERE t.id = $1 AND d.id = $2
+	`, targetID, duplicateID)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range customerLinkedTables {
+		query := fmt.Sprintf("UPDATE %s SET customer_id = $1 WHERE customer_id = $2", table)
+		if _, err := tx.Exec(query, targetID, duplicateID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM customers WHERE id = $1", duplicateID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.findCustomer(targetID)
+}
+
+// getDuplicateCandidates ищет клиентов с одинаковым email или общим адресом
+func (s *DatabaseStore) getDuplicateCandidates(limit int) ([]DuplicateCandidate, error) {
+	rows, err := s.db.Query(`
+		SELECT a.id, b.id, 'email' FROM customers a
+		JOIN customers b ON a.id < b.id AND a.email <> '' AND LOWER(a.email) = LOWER(b.email)
+		UNION
+		SELECT a.id, b.id, 'address' FROM customers a
+		JOIN customers b ON a.id < b.id AND a.addresses && b.addresses
+		ORDER BY 1, 2
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []DuplicateCandidate{}
+	for rows.Next() {
+		var c DuplicateCandidate
+		if err := rows.Scan(&c.CustomerID, &c.DuplicateID, &c.Match); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// Handlers
+
+// handleListCustomers — GET /api/customers?phone=&q=&limit=&offset=
+func (a *App) handleListCustomers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var phone string
+		if v := strings.TrimSpace(q.Get("phone")); v != "" {
+			p, err := normalizePhone(v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid phone"})
+				return
+			}
+			phone = p
+		}
+		limit, offset := 50, 0
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 500 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		if v := q.Get("offset"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+				return
+			}
+			offset = n
+		}
+
+		customers, err := a.Storage.getCustomers(phone, strings.TrimSpace(q.Get("q")), limit, offset)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, customers)
+	}
+}
+
+func (a *App) handleCustomerCard() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid customer ID"})
+			return
+		}
+
+		customer, err := a.Storage.findCustomer(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if customer == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "customer not found"})
+			return
+		}
+		leads, err := a.Storage.getCustomerLeads(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		quotes, err := a.Storage.getCustomerQuotes(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		orders, err := a.Storage.getCustomerOrders(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+
+		now := time.Now()
+		for i := range leads {
+			leads[i] = leads[i].withDerived(now)
+		}
+		for i := range quotes {
+			quotes[i] = quotes[i].withDerived(now)
+		}
+		for i := range orders {
+			orders[i] = orders[i].withDerived()
+		}
+		writeJSON(w, http.StatusOK, CustomerCard{Customer: *customer, Leads: leads, Quotes: quotes, Orders: orders})
+	}
+}
+
+func (a *App) handleUpdateCustomer() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid customer ID"})
+			return
+		}
+
+		var in CustomerUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		customer, err := a.Storage.updateCustomer(id, in)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if customer == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "customer not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, customer)
+	}
+}
+
+// handleMergeCustomers — POST /api/customers/{id}/merge {"duplicateId": N}:
+// клиент N объединяется с клиентом {id} и удаляется
+func (a *App) handleMergeCustomers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid customer ID"})
+			return
+		}
+
+		var in CustomerMergeRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		customer, err := a.Storage.mergeCustomers(id, in.DuplicateID)
+		switch {
+		case errors.Is(err, errMergeSelf):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		case customer == nil:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "customer not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, customer)
+	}
+}
+
+func (a *App) handleCustomerDuplicates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		candidates, err := a.Storage.getDuplicateCandidates(100)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, candidates)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/imagemirror"
+)
+
+// materialImagePrefix — адреса локальных копий изображений материалов
+const materialImagePrefix = "/media/materials"
+
+// BrokenImage — изображение, которое не удалось скопировать
+type BrokenImage struct {
+	MaterialID   int64  `json:"materialId"`
+	SupplierCode string `json:"supplierCode"`
+	URL          string `json:"url"`
+	Error        string `json:"error"`
+}
+
+type ImageMirrorReport struct {
+	Checked    int           `json:"checked"`
+	Local      int           `json:"local"`
+	Cached     int           `json:"cached"`
+	Reused     int           `json:"reused"`
+	Downloaded int           `json:"downloaded"`
+	Rewritten  int           `json:"rewritten"`
+	Broken     []BrokenImage `json:"broken"`
+}
+
+func (s *DatabaseStore) updateMaterialImageURL(id int64, imageURL string) error {
+	_, err := s.db.Exec(`UPDATE materials SET image_url = $2, updated_at = NOW() WHERE id = $1`, id, imageURL)
+	return err
+}
+
+// mediaHandler раздает локальные копии изображений. Имена файлов — хеш
+// содержимого, поэтому их можно кешировать навсегда.
+func mediaHandler(dir string) http.Handler {
+	files := http.StripPrefix("/media/", http.FileServer(http.Dir(dir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// handleMirrorImages копирует изображения материалов с сайтов поставщиков
+// в локальное хранилище и переписывает image_url на локальный адрес.
+// Уже скопированные адреса не скачиваются повторно. limit — сколько
+// материалов обработать за один запрос (по умолчанию все).
+func (a *App) handleMirrorImages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+
+		materials, err := a.Storage.getMaterials()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+
+		report := ImageMirrorReport{Broken: []BrokenImage{}}
+		for _, m := range materials {
+			if m.ImageURL == "" {
+				continue
+			}
+			if limit > 0 && report.Checked >= limit {
+				break
+			}
+			report.Checked++
+
+			res, err := a.Images.Fetch(r.Context(), m.ImageURL)
+			if err != nil {
+				if r.Context().Err() != nil {
+					break
+				}
+				report.Broken = append(report.Broken, BrokenImage{
+					MaterialID: m.ID, SupplierCode: m.SupplierCode, URL: m.ImageURL, Error: err.Error(),
+				})
+				continue
+			}
+
+			switch res.Status {
+			case imagemirror.StatusLocal:
+				report.Local++
+			case imagemirror.StatusCached:
+				report.Cached++
+			case imagemirror.StatusReused:
+				report.Reused++
+			case imagemirror.StatusDownloaded:
+				report.Downloaded++
+			}
+			if res.URL != m.ImageURL {
+				if err := a.Storage.updateMaterialImageURL(m.ID, res.URL); err != nil {
+					writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+					return
+				}
+				report.Rewritten++
+			}
+		}
+
+		if err := a.Images.Save(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "image index error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
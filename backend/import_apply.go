@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/catalogimport"
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+// ImportApplyResponse — итог применения выгрузки. Пропавшие у поставщика
+// материалы только перечисляются, из каталога они не удаляются.
+type ImportApplyResponse struct {
+	DryRun      bool                      `json:"dryRun"`
+	Counts      catalogimport.ApplyCounts `json:"counts"`
+	Created     []Material                `json:"created"`
+	Removed     []catalogimport.Existing  `json:"removed"`
+	Errors      []supplier.ItemError      `json:"errors,omitempty"`
+	Summary     string                    `json:"summary"`
+	Notified    bool                      `json:"notified,omitempty"`
+	NotifyError string                    `json:"notifyError,omitempty"`
+}
+
+func newImportedMaterial(m supplier.Material) Material {
+	cost := m.PricePerM2
+	material := Material{
+		SupplierCode: m.SupplierCode,
+		Name:         m.Name,
+		Category:     m.Category,
+		Color:        m.Color,
+		ColorFamily:  m.ColorFamily,
+		SlatWidthMm:  m.SlatWidthMm,
+		FabricType:   m.FabricType,
+		PricePerM2:   m.PricePerM2,
+		ImageURL:     m.ImageURL,
+		SupplierCost: &cost,
+	}
+	if m.LightTransmission != nil {
+		material.LightTransmission = *m.LightTransmission
+	}
+	return material
+}
+
+// mergeImported переносит в материал каталога данные поставщика. Пустые
+// поля выгрузки не затирают заполненные в каталоге; ручная цена
+// (PriceOverride) сохраняется.
+func mergeImported(existing Material, m supplier.Material) Material {
+	merged := existing
+	if m.Name != "" {
+		merged.Name = m.Name
+	}
+	if m.Category != "" {
+		merged.Category = m.Category
+	}
+	if m.Color != "" {
+		merged.Color, merged.ColorFamily = m.Color, m.ColorFamily
+	}
+	if m.SlatWidthMm != 0 {
+		merged.SlatWidthMm = m.SlatWidthMm
+	}
+	if m.FabricType != "" {
+		merged.FabricType = m.FabricType
+	}
+	if m.ImageURL != "" {
+		merged.ImageURL = m.ImageURL
+	}
+	if m.LightTransmission != nil {
+		merged.LightTransmission = *m.LightTransmission
+	}
+	cost := m.PricePerM2
+	merged.SupplierCost = &cost
+	return merged
+}
+
+// applyImport добавляет новые материалы и обновляет закупочные цены
+// и характеристики существующих в одной транзакции. Изменения розничной
+// цены попадают в историю с источником import. При dryRun транзакция
+// откатывается.
+func (s *DatabaseStore) applyImport(catalog supplier.Catalog, current []Material, dryRun bool) (ImportApplyResponse, error) {
+	resp := ImportApplyResponse{DryRun: dryRun, Created: []Material{}}
+
+	byCode := make(map[string]Material, len(current))
+	for _, m := range current {
+		byCode[catalogimport.NormalizeCode(m.SupplierCode)] = m
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return resp, err
+	}
+	defer tx.Rollback()
+
+	seen := make(map[string]bool, len(catalog.Materials))
+	for _, m := range catalog.Materials {
+		code := catalogimport.NormalizeCode(m.SupplierCode)
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+
+		existing, ok := byCode[code]
+		if !ok {
+			material := newImportedMaterial(m)
+			if err := insertMaterial(tx, &material); err != nil {
+				return resp, fmt.Errorf("insert %s: %w", m.SupplierCode, err)
+			}
+			resp.Created = append(resp.Created, material)
+			continue
+		}
+
+		merged := mergeImported(existing, m)
+		costChanged := existing.SupplierCost == nil || roundTo(*existing.SupplierCost, 2) != roundTo(*merged.SupplierCost, 2)
+		before, after := existing, merged
+		before.SupplierCost, after.SupplierCost = nil, nil
+		attributesChanged := before != after
+		if !costChanged && !attributesChanged {
+			resp.Counts.Unchanged++
+			continue
+		}
+
+		updated, err := updateMaterialTx(tx, merged, PriceSourceImport)
+		if err != nil {
+			return resp, fmt.Errorf("update %s: %w", m.SupplierCode, err)
+		}
+		if updated == nil {
+			continue
+		}
+		if costChanged {
+			resp.Counts.CostUpdated++
+		}
+		if attributesChanged {
+			resp.Counts.AttributesUpdated++
+		}
+		if roundTo(updated.PricePerM2, 2) != roundTo(existing.PricePerM2, 2) {
+			resp.Counts.RetailChanged++
+		}
+	}
+	resp.Counts.Created = len(resp.Created)
+
+	if dryRun {
+		return resp, nil
+	}
+	return resp, tx.Commit()
+}
+
+// handleImportApply применяет выгрузку поставщика к каталогу. Тело и
+// параметры — как у плана импорта; dryRun=true считает изменения без записи.
+func (a *App) handleImportApply() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		in, ok := a.prepareImport(w, r)
+		if !ok {
+			return
+		}
+
+		resp, err := a.Storage.applyImport(in.catalog, in.materials, r.URL.Query().Get("dryRun") == "true")
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		resp.Removed = in.plan.Removed
+		resp.Errors = in.plan.Errors
+		resp.Counts.Removed = len(resp.Removed)
+		resp.Counts.Errors = len(resp.Errors)
+
+		title := "Импорт применен"
+		if resp.DryRun {
+			title = "Проверка импорта (без записи)"
+		}
+		resp.Summary = fmt.Sprintf("%s: новых %d, закупочных цен обновлено %d, розничных цен изменилось %d, характеристик обновлено %d\n\n%s",
+			title, resp.Counts.Created, resp.Counts.CostUpdated, resp.Counts.RetailChanged, resp.Counts.AttributesUpdated,
+			in.plan.Summary(importSummaryLimit))
+		if !resp.DryRun {
+			resp.Notified, resp.NotifyError = a.notifyImport(r, resp.Summary)
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
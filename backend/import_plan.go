@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/catalogimport"
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+const (
+	importSummaryLimit = 15
+	// priceListMappingDir — настройки колонок прайс-листов поставщиков
+	priceListMappingDir = "data/pricelists"
+)
+
+var mappingNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// isPriceListContentType — тело запроса является прайс-листом CSV/XLSX, а не JSON парсера
+func isPriceListContentType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv", "text/plain", "application/octet-stream",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return true
+	}
+	return false
+}
+
+type ImportPlanResponse struct {
+	catalogimport.Plan
+	Summary     string `json:"summary"`
+	Notified    bool   `json:"notified,omitempty"`
+	NotifyError string `json:"notifyError,omitempty"`
+}
+
+func existingMaterials(materials []Material) []catalogimport.Existing {
+	existing := make([]catalogimport.Existing, 0, len(materials))
+	for _, m := range materials {
+		existing = append(existing, catalogimport.Existing{
+			ID:                m.ID,
+			SupplierCode:      m.SupplierCode,
+			Name:              m.Name,
+			Category:          m.Category,
+			Color:             m.Color,
+			ColorFamily:       m.ColorFamily,
+			SlatWidthMm:       m.SlatWidthMm,
+			FabricType:        m.FabricType,
+			LightTransmission: m.LightTransmission,
+			SupplierCost:      m.SupplierCost,
+			PricePerM2:        m.PricePerM2,
+			ImageURL:          m.ImageURL,
+		})
+	}
+	return existing
+}
+
+// preparedImport — разобранная выгрузка, текущий каталог и план
+type preparedImport struct {
+	catalog   supplier.Catalog
+	materials []Material
+	plan      catalogimport.Plan
+}
+
+// prepareImport разбирает тело запроса импорта и строит план. Если что-то
+// не так, ответ с ошибкой уже записан и возвращается false.
+func (a *App) prepareImport(w http.ResponseWriter, r *http.Request) (preparedImport, bool) {
+	var in preparedImport
+	q := r.URL.Query()
+
+	threshold := 1.0
+	if v := q.Get("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 || t > 100 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid threshold"})
+			return in, false
+		}
+		threshold = t
+	}
+
+	body := http.MaxBytesReader(w, r.Body, 20<<20)
+	var err error
+	if isPriceListContentType(r.Header.Get("Content-Type")) {
+		mapping := catalogimport.DefaultMapping()
+		if name := q.Get("mapping"); name != "" {
+			if !mappingNamePattern.MatchString(name) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid mapping"})
+				return in, false
+			}
+			mapping, err = catalogimport.LoadMapping(filepath.Join(priceListMappingDir, name+".json"))
+			if errors.Is(err, fs.ErrNotExist) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "mapping not found"})
+				return in, false
+			}
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return in, false
+			}
+		}
+		in.catalog, err = catalogimport.ReadPriceList(body, "upload", mapping)
+	} else {
+		in.catalog, err = catalogimport.ReadJSON(body)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return in, false
+	}
+
+	in.materials, err = a.Storage.getMaterials()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+		return in, false
+	}
+	dict, err := a.attributeDictionary()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+		return in, false
+	}
+	dict.ApplyAll(in.catalog.Materials)
+	// Уже скопированные изображения сравниваем по локальному адресу
+	for i, m := range in.catalog.Materials {
+		if local, ok := a.Images.Lookup(m.ImageURL); ok {
+			in.catalog.Materials[i].ImageURL = local
+		}
+	}
+
+	in.plan = catalogimport.Build(in.catalog, existingMaterials(in.materials), catalogimport.Options{
+		ThresholdPercent: threshold,
+		ScopePrefix:      q.Get("prefix"),
+	})
+	return in, true
+}
+
+// notifyImport отправляет отчет в Telegram, если запрошено notify=true
+func (a *App) notifyImport(r *http.Request, summary string) (notified bool, notifyError string) {
+	if r.URL.Query().Get("notify") != "true" {
+		return false, ""
+	}
+	if err := a.notifyTelegram(summary); err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+// handleImportPlan сравнивает выгрузку поставщика (JSON парсера или прайс-лист
+// CSV/XLSX) с каталогом и ничего не меняет. Параметры: threshold — порог
+// изменения цены в процентах, mapping — настройки колонок прайс-листа из
+// data/pricelists, prefix — префикс артикулов поставщика, notify=true —
+// отправить отчет подписчикам Telegram, format=text — только отчет.
+func (a *App) handleImportPlan() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		in, ok := a.prepareImport(w, r)
+		if !ok {
+			return
+		}
+
+		resp := ImportPlanResponse{Plan: in.plan, Summary: in.plan.Summary(importSummaryLimit)}
+		resp.Notified, resp.NotifyError = a.notifyImport(r, resp.Summary)
+
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(resp.Summary + "\n"))
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,288 @@
+// Package attributes — словарь характеристик материалов: цвета с образцом
+// и цветовой семьей, ширины ламелей и типы тканей с синонимами.
+//
+// Сопоставление детерминировано: текст разбивается на слова, и выигрывает
+// значение, синоним которого встречается в тексте раньше; при равной позиции —
+// более длинный синоним, затем значение с меньшим sortOrder и кодом.
+// «Бело-серый» поэтому всегда белый.
+package attributes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+// Kind — вид характеристики
+type Kind string
+
+const (
+	KindColor      Kind = "color"
+	KindSlatWidth  Kind = "slat_width"
+	KindFabricType Kind = "fabric_type"
+)
+
+// Kinds — все виды в порядке вывода
+var Kinds = []Kind{KindColor, KindSlatWidth, KindFabricType}
+
+// Value — значение словаря. Number — ширина ламели в мм для slat_width
+// и типичная светопропускаемость в процентах для fabric_type.
+type Value struct {
+	ID        int64    `json:"id"`
+	Kind      Kind     `json:"kind" validate:"oneof=color slat_width fabric_type"`
+	Code      string   `json:"code" validate:"required,max=50"`
+	Name      string   `json:"name" validate:"required,max=100"`
+	Hex       string   `json:"hex,omitempty" validate:"omitempty,hexcolor,len=7"`
+	Family    string   `json:"family,omitempty" validate:"max=50"`
+	Number    *float64 `json:"number,omitempty" validate:"omitempty,gte=0"`
+	Synonyms  []string `json:"synonyms" validate:"dive,required,max=100"`
+	SortOrder int      `json:"sortOrder"`
+}
+
+// phrase — синоним, разбитый на слова. Последнее слово со звездочкой
+// на конце совпадает с любым словом, которое с него начинается.
+type phrase struct {
+	tokens  []string
+	prefix  bool
+	letters int
+	value   int
+}
+
+// Dictionary — словарь, готовый к сопоставлению
+type Dictionary struct {
+	values  []Value
+	phrases map[Kind][]phrase
+}
+
+// Tokenize приводит текст к нижнему регистру и разбивает на слова: буквы
+// и цифры — отдельные слова, все остальное — разделители («89мм» → 89, мм).
+func Tokenize(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	var (
+		tokens  []string
+		current []rune
+		digits  bool
+	)
+	flush := func() {
+		if len(current) > 0 {
+			tokens = append(tokens, string(current))
+			current = current[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsDigit(r):
+			if !digits {
+				flush()
+			}
+			digits = true
+			current = append(current, r)
+		case unicode.IsLetter(r) || r == '*':
+			if digits {
+				flush()
+			}
+			digits = false
+			current = append(current, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// New строит словарь. Название значения тоже считается синонимом.
+func New(values []Value) *Dictionary {
+	d := &Dictionary{values: append([]Value(nil), values...), phrases: make(map[Kind][]phrase)}
+	sort.SliceStable(d.values, func(i, j int) bool {
+		a, b := d.values[i], d.values[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder < b.SortOrder
+		}
+		return a.Code < b.Code
+	})
+
+	for i, v := range d.values {
+		seen := make(map[string]bool)
+		for _, s := range append([]string{v.Name}, v.Synonyms...) {
+			tokens := Tokenize(s)
+			key := strings.Join(tokens, " ")
+			if len(tokens) == 0 || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			p := phrase{tokens: tokens, value: i}
+			last := tokens[len(tokens)-1]
+			if strings.HasSuffix(last, "*") {
+				p.prefix = true
+				tokens[len(tokens)-1] = strings.TrimRight(last, "*")
+			}
+			for _, t := range tokens {
+				p.letters += len([]rune(t))
+			}
+			d.phrases[v.Kind] = append(d.phrases[v.Kind], p)
+		}
+	}
+	return d
+}
+
+// Values — значения вида kind в порядке sortOrder
+func (d *Dictionary) Values(kind Kind) []Value {
+	var values []Value
+	for _, v := range d.values {
+		if v.Kind == kind {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (p phrase) matchAt(tokens []string, start int) bool {
+	if start+len(p.tokens) > len(tokens) {
+		return false
+	}
+	for i, t := range p.tokens {
+		word := tokens[start+i]
+		if p.prefix && i == len(p.tokens)-1 {
+			if t == "" || !strings.HasPrefix(word, t) {
+				return false
+			}
+		} else if word != t {
+			return false
+		}
+	}
+	return true
+}
+
+// Find ищет в тексте значение вида kind
+func (d *Dictionary) Find(kind Kind, text string) (Value, bool) {
+	tokens := Tokenize(text)
+	phrases := d.phrases[kind]
+	for start := range tokens {
+		best := -1
+		for i, p := range phrases {
+			if !p.matchAt(tokens, start) {
+				continue
+			}
+			if best < 0 || better(p, phrases[best]) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			return d.values[phrases[best].value], true
+		}
+	}
+	return Value{}, false
+}
+
+func better(a, b phrase) bool {
+	if len(a.tokens) != len(b.tokens) {
+		return len(a.tokens) > len(b.tokens)
+	}
+	if a.prefix != b.prefix {
+		return !a.prefix
+	}
+	if a.letters != b.letters {
+		return a.letters > b.letters
+	}
+	return a.value < b.value
+}
+
+// findFirst проверяет тексты по порядку и возвращает первое совпадение
+func (d *Dictionary) findFirst(kind Kind, texts ...string) (Value, bool) {
+	for _, text := range texts {
+		if v, ok := d.Find(kind, text); ok {
+			return v, true
+		}
+	}
+	return Value{}, false
+}
+
+// attributeValues — значения характеристик поставщика, в названии которых
+// есть одно из слов, в порядке названий
+func attributeValues(attrs map[string]string, keys ...string) []string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var values []string
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		for _, k := range keys {
+			if strings.Contains(lower, k) {
+				values = append(values, attrs[name])
+				break
+			}
+		}
+	}
+	return values
+}
+
+// Apply нормализует цвет, ширину ламели и тип ткани материала. Сначала
+// проверяются поле материала и характеристики поставщика, затем название
+// и описание. Нераспознанный цвет остается как есть, без цветовой семьи.
+// Светопропускаемость берется из типа ткани, только если поставщик ее не указал.
+func (d *Dictionary) Apply(m *supplier.Material) {
+	colorTexts := append([]string{m.Color}, attributeValues(m.Attributes, "цвет", "color")...)
+	if v, ok := d.findFirst(KindColor, append(colorTexts, m.Name, m.Description)...); ok {
+		m.Color = v.Name
+		m.ColorFamily = v.Family
+		if m.ColorFamily == "" {
+			m.ColorFamily = v.Code
+		}
+	}
+
+	// В характеристиках ширину часто пишут без единиц: «89»
+	var widthTexts []string
+	for _, v := range attributeValues(m.Attributes, "ламел", "ширин", "slat") {
+		if strings.IndexFunc(v, unicode.IsLetter) < 0 {
+			v += " мм"
+		}
+		widthTexts = append(widthTexts, v)
+	}
+	widthTexts = append(widthTexts, m.Name)
+	if v, ok := d.findFirst(KindSlatWidth, widthTexts...); ok && v.Number != nil {
+		m.SlatWidthMm = int(*v.Number + 0.5)
+	}
+
+	fabricTexts := append(attributeValues(m.Attributes, "ткан", "тип", "материал", "fabric"), m.Name, m.Description)
+	if v, ok := d.findFirst(KindFabricType, fabricTexts...); ok {
+		m.FabricType = v.Code
+		if v.Number != nil && len(attributeValues(m.Attributes, "светопропуск")) == 0 {
+			lt := int(*v.Number + 0.5)
+			m.LightTransmission = &lt
+		}
+	}
+}
+
+// Load читает словарь, сохраненный из GET /api/attributes
+func Load(r io.Reader) (*Dictionary, error) {
+	var data struct {
+		Values []Value `json:"values"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, fmt.Errorf("attributes: %w", err)
+	}
+	if len(data.Values) == 0 {
+		return nil, fmt.Errorf("attributes: dictionary is empty")
+	}
+	return New(data.Values), nil
+}
+
+// ApplyAll нормализует все материалы выгрузки
+func (d *Dictionary) ApplyAll(materials []supplier.Material) {
+	for i := range materials {
+		d.Apply(&materials[i])
+	}
+}
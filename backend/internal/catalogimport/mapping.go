@@ -0,0 +1,142 @@
+package catalogimport
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Поля выгрузки, на которые раскладываются колонки прайс-листа
+const (
+	FieldSupplierCode      = "supplierCode"
+	FieldName              = "name"
+	FieldCategory          = "category"
+	FieldColor             = "color"
+	FieldLightTransmission = "lightTransmission"
+	FieldPricePerM2        = "pricePerM2"
+	FieldImageURL          = "imageUrl"
+	FieldDescription       = "description"
+)
+
+var requiredFields = []string{FieldSupplierCode, FieldName, FieldPricePerM2}
+
+// Mapping — описание прайс-листа конкретного поставщика: какие заголовки
+// колонок соответствуют полям материала и как читать файл
+type Mapping struct {
+	Supplier string `json:"supplier"`
+	// Columns — для каждого поля список возможных заголовков колонки.
+	// Они проверяются раньше заголовков из DefaultMapping.
+	Columns map[string][]string `json:"columns"`
+	// Encoding — auto, utf-8 или windows-1251
+	Encoding string `json:"encoding,omitempty"`
+	// Delimiter — разделитель CSV; пусто — определить по первым строкам
+	Delimiter string `json:"delimiter,omitempty"`
+	// Sheet — лист XLSX; пусто — первый
+	Sheet string `json:"sheet,omitempty"`
+	// CodePrefix добавляется к артикулу, если его там нет (INT-)
+	CodePrefix string `json:"codePrefix,omitempty"`
+	// Category — категория для строк без колонки категории и без строки-группы
+	Category string `json:"category,omitempty"`
+	// HeaderSearchRows — в скольких первых строках искать заголовок
+	HeaderSearchRows int `json:"headerSearchRows,omitempty"`
+}
+
+// DefaultMapping понимает названия полей выгрузки парсера и распространенные
+// русские заголовки прайс-листов
+func DefaultMapping() Mapping {
+	return Mapping{
+		Columns: map[string][]string{
+			FieldSupplierCode:      {"supplierCode", "Артикул", "Код", "Код товара", "Артикул поставщика"},
+			FieldName:              {"name", "Наименование", "Название", "Наименование товара", "Товар"},
+			FieldCategory:          {"category", "Категория", "Группа", "Раздел"},
+			FieldColor:             {"color", "Цвет"},
+			FieldLightTransmission: {"lightTransmission", "Светопропускаемость", "Светопропускание"},
+			FieldPricePerM2:        {"pricePerM2", "Цена", "Цена за м2", "Цена за м²", "Цена, руб", "Цена, руб.", "Цена руб/м2", "Опт"},
+			FieldImageURL:          {"imageUrl", "Фото", "Изображение", "Картинка"},
+			FieldDescription:       {"description", "Описание"},
+		},
+		Encoding:         "auto",
+		HeaderSearchRows: 20,
+	}
+}
+
+// LoadMapping читает настройки прайс-листа из JSON и дополняет их значениями
+// по умолчанию
+func LoadMapping(path string) (Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Mapping{}, err
+	}
+	var m Mapping
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Mapping{}, fmt.Errorf("mapping %s: %w", path, err)
+	}
+	return m.withDefaults()
+}
+
+func (m Mapping) withDefaults() (Mapping, error) {
+	def := DefaultMapping()
+	columns := make(map[string][]string, len(def.Columns))
+	for field, aliases := range def.Columns {
+		columns[field] = aliases
+	}
+	for field, aliases := range m.Columns {
+		if _, ok := def.Columns[field]; !ok {
+			return m, fmt.Errorf("mapping: unknown field %q", field)
+		}
+		columns[field] = append(append([]string{}, aliases...), def.Columns[field]...)
+	}
+	m.Columns = columns
+
+	switch strings.ToLower(m.Encoding) {
+	case "":
+		m.Encoding = def.Encoding
+	case "auto", "utf-8", "windows-1251":
+		m.Encoding = strings.ToLower(m.Encoding)
+	default:
+		return m, fmt.Errorf("mapping: unsupported encoding %q", m.Encoding)
+	}
+	if len([]rune(m.Delimiter)) > 1 {
+		return m, fmt.Errorf("mapping: delimiter must be a single character")
+	}
+	if m.HeaderSearchRows <= 0 {
+		m.HeaderSearchRows = def.HeaderSearchRows
+	}
+	return m, nil
+}
+
+// normalizeHeader приводит заголовок колонки к виду для сравнения
+func normalizeHeader(s string) string {
+	s = strings.TrimPrefix(s, "\ufeff")
+	s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// matchHeader ищет в строке заголовок: возвращает номер колонки для каждого
+// найденного поля. ok — найдены все обязательные поля.
+func (m Mapping) matchHeader(cells []string) (columns map[string]int, ok bool) {
+	byHeader := make(map[string]int, len(cells))
+	for i, cell := range cells {
+		h := normalizeHeader(cell)
+		if _, dup := byHeader[h]; h != "" && !dup {
+			byHeader[h] = i
+		}
+	}
+
+	columns = make(map[string]int)
+	for field, aliases := range m.Columns {
+		for _, alias := range aliases {
+			if i, found := byHeader[normalizeHeader(alias)]; found {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	for _, field := range requiredFields {
+		if _, found := columns[field]; !found {
+			return columns, false
+		}
+	}
+	return columns, true
+}
@@ -0,0 +1,324 @@
+// Package catalogimport сравнивает выгрузку поставщика с текущим каталогом
+// и готовит план импорта: новые, пропавшие и измененные материалы.
+package catalogimport
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+// Existing — материал из таблицы materials
+type Existing struct {
+	ID                int64    `json:"id"`
+	SupplierCode      string   `json:"supplierCode"`
+	Name              string   `json:"name"`
+	Category          string   `json:"category"`
+	Color             string   `json:"color"`
+	ColorFamily       string   `json:"colorFamily,omitempty"`
+	SlatWidthMm       int      `json:"slatWidthMm,omitempty"`
+	FabricType        string   `json:"fabricType,omitempty"`
+	LightTransmission int      `json:"lightTransmission"`
+	SupplierCost      *float64 `json:"supplierCost,omitempty"`
+	PricePerM2        float64  `json:"pricePerM2"`
+	ImageURL          string   `json:"imageUrl"`
+}
+
+// Options — настройки сравнения
+type Options struct {
+	// ThresholdPercent — изменения закупочной цены меньше порога считаются
+	// незначительными и не перечисляются
+	ThresholdPercent float64
+	// ScopePrefix — префикс артикулов поставщика. Пропавшими считаются только
+	// материалы с этим префиксом; пусто — определить по выгрузке.
+	ScopePrefix string
+}
+
+type PriceChange struct {
+	MaterialID   int64    `json:"materialId"`
+	SupplierCode string   `json:"supplierCode"`
+	Name         string   `json:"name"`
+	OldCost      *float64 `json:"oldCost,omitempty"`
+	NewCost      float64  `json:"newCost"`
+	DeltaPercent *float64 `json:"deltaPercent,omitempty"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type AttributeChange struct {
+	MaterialID   int64         `json:"materialId"`
+	SupplierCode string        `json:"supplierCode"`
+	Name         string        `json:"name"`
+	Changes      []FieldChange `json:"changes"`
+}
+
+type Counts struct {
+	Incoming          int `json:"incoming"`
+	New               int `json:"new"`
+	Removed           int `json:"removed"`
+	PriceChanged      int `json:"priceChanged"`
+	MinorPriceChanges int `json:"minorPriceChanges"`
+	CostAdded         int `json:"costAdded"`
+	AttributeChanged  int `json:"attributeChanged"`
+	Unchanged         int `json:"unchanged"`
+	Duplicates        int `json:"duplicates"`
+	Errors            int `json:"errors"`
+}
+
+// Plan — результат сравнения
+type Plan struct {
+	Supplier         string               `json:"supplier,omitempty"`
+	Source           string               `json:"source,omitempty"`
+	ParsedAt         string               `json:"parsedAt,omitempty"`
+	ThresholdPercent float64              `json:"thresholdPercent"`
+	ScopePrefix      string               `json:"scopePrefix,omitempty"`
+	Counts           Counts               `json:"counts"`
+	New              []supplier.Material  `json:"new"`
+	Removed          []Existing           `json:"removed"`
+	PriceChanges     []PriceChange        `json:"priceChanges"`
+	AttributeChanges []AttributeChange    `json:"attributeChanges"`
+	Duplicates       []string             `json:"duplicates,omitempty"`
+	Errors           []supplier.ItemError `json:"errors,omitempty"`
+}
+
+// HasChanges — применение плана что-то изменит в каталоге
+func (p Plan) HasChanges() bool {
+	c := p.Counts
+	return c.New+c.Removed+c.PriceChanged+c.MinorPriceChanges+c.CostAdded+c.AttributeChanged > 0
+}
+
+// ApplyCounts — итог применения выгрузки к каталогу
+type ApplyCounts struct {
+	Created           int `json:"created"`
+	CostUpdated       int `json:"costUpdated"`
+	AttributesUpdated int `json:"attributesUpdated"`
+	RetailChanged     int `json:"retailChanged"`
+	Unchanged         int `json:"unchanged"`
+	Removed           int `json:"removed"`
+	Errors            int `json:"errors"`
+}
+
+// NormalizeCode приводит артикул к виду, по которому сравниваются записи
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// scopePrefix — общий префикс артикулов выгрузки до первого дефиса
+// включительно (INT-), если он у всех один
+func scopePrefix(materials []supplier.Material) string {
+	prefix := ""
+	for i, m := range materials {
+		code := NormalizeCode(m.SupplierCode)
+		dash := strings.Index(code, "-")
+		if dash <= 0 {
+			return ""
+		}
+		p := code[:dash+1]
+		if i == 0 {
+			prefix = p
+		} else if p != prefix {
+			return ""
+		}
+	}
+	return prefix
+}
+
+func roundPercent(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Build сравнивает выгрузку с текущими материалами по артикулу поставщика
+func Build(catalog supplier.Catalog, current []Existing, opts Options) Plan {
+	plan := Plan{
+		Supplier:         catalog.Supplier,
+		Source:           catalog.Source,
+		ParsedAt:         catalog.ParsedAt,
+		ThresholdPercent: opts.ThresholdPercent,
+		ScopePrefix:      NormalizeCode(opts.ScopePrefix),
+		New:              []supplier.Material{},
+		Removed:          []Existing{},
+		PriceChanges:     []PriceChange{},
+		AttributeChanges: []AttributeChange{},
+		Errors:           catalog.Errors,
+	}
+	if plan.ScopePrefix == "" {
+		plan.ScopePrefix = scopePrefix(catalog.Materials)
+	}
+
+	byCode := make(map[string]Existing, len(current))
+	for _, e := range current {
+		byCode[NormalizeCode(e.SupplierCode)] = e
+	}
+
+	seen := make(map[string]bool, len(catalog.Materials))
+	for _, m := range catalog.Materials {
+		code := NormalizeCode(m.SupplierCode)
+		if seen[code] {
+			plan.Duplicates = append(plan.Duplicates, m.SupplierCode)
+			continue
+		}
+		seen[code] = true
+		plan.Counts.Incoming++
+
+		existing, ok := byCode[code]
+		if !ok {
+			plan.New = append(plan.New, m)
+			continue
+		}
+
+		changed := false
+		switch {
+		case existing.SupplierCost == nil:
+			plan.Counts.CostAdded++
+			plan.PriceChanges = append(plan.PriceChanges, PriceChange{
+				MaterialID: existing.ID, SupplierCode: existing.SupplierCode, Name: existing.Name, NewCost: m.PricePerM2,
+			})
+			changed = true
+		case *existing.SupplierCost != m.PricePerM2:
+			delta := roundPercent((m.PricePerM2 - *existing.SupplierCost) / *existing.SupplierCost * 100)
+			if math.Abs(delta) < opts.ThresholdPercent {
+				plan.Counts.MinorPriceChanges++
+			} else {
+				plan.PriceChanges = append(plan.PriceChanges, PriceChange{
+					MaterialID: existing.ID, SupplierCode: existing.SupplierCode, Name: existing.Name,
+					OldCost: existing.SupplierCost, NewCost: m.PricePerM2, DeltaPercent: &delta,
+				})
+				plan.Counts.PriceChanged++
+			}
+			changed = true
+		}
+
+		if fields := attributeChanges(existing, m); len(fields) > 0 {
+			plan.AttributeChanges = append(plan.AttributeChanges, AttributeChange{
+				MaterialID: existing.ID, SupplierCode: existing.SupplierCode, Name: existing.Name, Changes: fields,
+			})
+			changed = true
+		}
+		if !changed {
+			plan.Counts.Unchanged++
+		}
+	}
+
+	for _, e := range current {
+		code := NormalizeCode(e.SupplierCode)
+		if !seen[code] && strings.HasPrefix(code, plan.ScopePrefix) {
+			plan.Removed = append(plan.Removed, e)
+		}
+	}
+
+	sort.SliceStable(plan.PriceChanges, func(i, j int) bool {
+		a, b := plan.PriceChanges[i].DeltaPercent, plan.PriceChanges[j].DeltaPercent
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return math.Abs(*a) > math.Abs(*b)
+	})
+
+	plan.Counts.New = len(plan.New)
+	plan.Counts.Removed = len(plan.Removed)
+	plan.Counts.AttributeChanged = len(plan.AttributeChanges)
+	plan.Counts.Duplicates = len(plan.Duplicates)
+	plan.Counts.Errors = len(plan.Errors)
+	return plan
+}
+
+func attributeChanges(e Existing, m supplier.Material) []FieldChange {
+	var changes []FieldChange
+	add := func(field, old, new string) {
+		if strings.TrimSpace(old) != strings.TrimSpace(new) {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	add("name", e.Name, m.Name)
+	add("category", e.Category, m.Category)
+	add("color", e.Color, m.Color)
+	if m.LightTransmission != nil {
+		add("lightTransmission", fmt.Sprint(e.LightTransmission), fmt.Sprint(*m.LightTransmission))
+	}
+	if m.ImageURL != "" {
+		add("imageUrl", e.ImageURL, m.ImageURL)
+	}
+	if m.SlatWidthMm != 0 {
+		add("slatWidthMm", fmt.Sprint(e.SlatWidthMm), fmt.Sprint(m.SlatWidthMm))
+	}
+	if m.FabricType != "" {
+		add("fabricType", e.FabricType, m.FabricType)
+	}
+	return changes
+}
+
+// Summary — короткий отчет для человека и Telegram. В каждом разделе
+// перечисляется не больше limit позиций.
+func (p Plan) Summary(limit int) string {
+	var b strings.Builder
+	title := "Импорт каталога"
+	if p.Supplier != "" {
+		title += " " + p.Supplier
+	}
+	if p.ParsedAt != "" {
+		title += " от " + p.ParsedAt
+	}
+	fmt.Fprintf(&b, "%s\n", title)
+	fmt.Fprintf(&b, "В файле: %d, без изменений: %d\n", p.Counts.Incoming, p.Counts.Unchanged)
+	fmt.Fprintf(&b, "Новые: %d, пропали: %d, цена изменилась: %d", p.Counts.New, p.Counts.Removed, p.Counts.PriceChanged)
+	if p.ThresholdPercent > 0 {
+		fmt.Fprintf(&b, " (порог %.1f%%, меньше порога: %d)", p.ThresholdPercent, p.Counts.MinorPriceChanges)
+	}
+	fmt.Fprintf(&b, ", изменились характеристики: %d\n", p.Counts.AttributeChanged)
+	if p.Counts.CostAdded > 0 {
+		fmt.Fprintf(&b, "Закупочная цена появится у %d материалов\n", p.Counts.CostAdded)
+	}
+	if p.Counts.Duplicates > 0 {
+		fmt.Fprintf(&b, "Повторяющиеся артикулы: %s\n", strings.Join(p.Duplicates, ", "))
+	}
+
+	section := func(name string, n int, line func(i int) string) {
+		if n == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", name)
+		for i := 0; i < n && i < limit; i++ {
+			fmt.Fprintf(&b, "  %s\n", line(i))
+		}
+		if n > limit {
+			fmt.Fprintf(&b, "  … и еще %d\n", n-limit)
+		}
+	}
+
+	var priced []PriceChange
+	for _, c := range p.PriceChanges {
+		if c.OldCost != nil {
+			priced = append(priced, c)
+		}
+	}
+	section("Изменение цен", len(priced), func(i int) string {
+		c := priced[i]
+		return fmt.Sprintf("%s %s: %.2f → %.2f ₽ (%+.1f%%)", c.SupplierCode, c.Name, *c.OldCost, c.NewCost, *c.DeltaPercent)
+	})
+	section("Новые", len(p.New), func(i int) string {
+		m := p.New[i]
+		return fmt.Sprintf("%s %s — %.2f ₽", m.SupplierCode, m.Name, m.PricePerM2)
+	})
+	section("Пропали у поставщика", len(p.Removed), func(i int) string {
+		return fmt.Sprintf("%s %s", p.Removed[i].SupplierCode, p.Removed[i].Name)
+	})
+	section("Изменились характеристики", len(p.AttributeChanges), func(i int) string {
+		c := p.AttributeChanges[i]
+		fields := make([]string, 0, len(c.Changes))
+		for _, f := range c.Changes {
+			fields = append(fields, fmt.Sprintf("%s: %q → %q", f.Field, f.Old, f.New))
+		}
+		return fmt.Sprintf("%s: %s", c.SupplierCode, strings.Join(fields, "; "))
+	})
+	section("Не разобрано", len(p.Errors), func(i int) string {
+		return fmt.Sprintf("%s: %s", p.Errors[i].URL, p.Errors[i].Error)
+	})
+	return strings.TrimRight(b.String(), "\n")
+}
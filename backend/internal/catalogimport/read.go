@@ -0,0 +1,317 @@
+package catalogimport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	xunicode "golang.org/x/text/encoding/unicode"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+// ReadJSON читает выгрузку парсера (supplier.Catalog). Записи без артикула
+// или с неположительной ценой переносятся в Errors.
+func ReadJSON(r io.Reader) (supplier.Catalog, error) {
+	var catalog supplier.Catalog
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return catalog, fmt.Errorf("invalid catalog JSON: %w", err)
+	}
+
+	valid := catalog.Materials[:0]
+	for i, m := range catalog.Materials {
+		switch {
+		case strings.TrimSpace(m.SupplierCode) == "":
+			catalog.Errors = append(catalog.Errors, supplier.ItemError{URL: fmt.Sprintf("materials[%d]", i), Error: "empty supplierCode"})
+		case m.PricePerM2 <= 0:
+			catalog.Errors = append(catalog.Errors, supplier.ItemError{URL: m.SupplierCode, Error: fmt.Sprintf("invalid pricePerM2 %v", m.PricePerM2)})
+		default:
+			valid = append(valid, m)
+		}
+	}
+	catalog.Materials = valid
+	return catalog, nil
+}
+
+// row — строка прайс-листа: ссылка для сообщений об ошибках и значения ячеек
+type row struct {
+	ref   string
+	cells []string
+}
+
+// ReadPriceList читает прайс-лист поставщика в CSV или XLSX (формат
+// определяется по содержимому) по настройкам колонок m. Строки с ошибками
+// не прерывают чтение — они попадают в Errors с номером строки. source
+// записывается в выгрузку как источник данных.
+func ReadPriceList(r io.Reader, source string, m Mapping) (supplier.Catalog, error) {
+	catalog := supplier.Catalog{Supplier: m.Supplier, Source: source, ParsedAt: time.Now().Format("2006-01-02 15:04:05")}
+
+	m, err := m.withDefaults()
+	if err != nil {
+		return catalog, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return catalog, err
+	}
+
+	var rows []row
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		_, rows, err = readXLSX(data, m.Sheet)
+	case bytes.HasPrefix(data, []byte{0xD0, 0xCF, 0x11, 0xE0}):
+		err = errors.New("xls format is not supported, save the file as xlsx or csv")
+	default:
+		rows, err = readCSVRows(data, m)
+	}
+	if err != nil {
+		return catalog, err
+	}
+
+	materials, rowErrors, err := m.materials(rows)
+	catalog.Materials = materials
+	catalog.Errors = rowErrors
+	return catalog, err
+}
+
+// decodeText приводит CSV к UTF-8. В режиме auto учитываются BOM UTF-8 и
+// UTF-16, а текст, не являющийся корректным UTF-8, считается Windows-1251.
+func decodeText(data []byte, encoding string) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return data[3:], nil
+	case encoding == "auto" && bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return xunicode.UTF16(xunicode.LittleEndian, xunicode.ExpectBOM).NewDecoder().Bytes(data)
+	case encoding == "auto" && bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return xunicode.UTF16(xunicode.BigEndian, xunicode.ExpectBOM).NewDecoder().Bytes(data)
+	}
+
+	switch encoding {
+	case "windows-1251":
+		return charmap.Windows1251.NewDecoder().Bytes(data)
+	case "utf-8":
+		if !utf8.Valid(data) {
+			return nil, errors.New("csv: file is not valid UTF-8")
+		}
+		return data, nil
+	default:
+		if utf8.Valid(data) {
+			return data, nil
+		}
+		return charmap.Windows1251.NewDecoder().Bytes(data)
+	}
+}
+
+// detectDelimiter выбирает самый частый из ; , и табуляции в первых строках.
+// При равенстве предпочитается точка с запятой: в русских прайсах запятая —
+// десятичный разделитель.
+func detectDelimiter(text []byte) rune {
+	lines := bytes.SplitN(text, []byte("\n"), 6)
+	if len(lines) > 5 {
+		lines = lines[:5]
+	}
+	best, bestCount := ';', 0
+	for _, d := range []rune{';', '\t', ','} {
+		count := 0
+		for _, line := range lines {
+			count += bytes.Count(line, []byte(string(d)))
+		}
+		if count > bestCount {
+			best, bestCount = d, count
+		}
+	}
+	return best
+}
+
+func readCSVRows(data []byte, m Mapping) ([]row, error) {
+	text, err := decodeText(data, m.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(bytes.NewReader(text))
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	if m.Delimiter != "" {
+		cr.Comma = []rune(m.Delimiter)[0]
+	} else {
+		cr.Comma = detectDelimiter(text)
+	}
+
+	var rows []row
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// FieldPos после ошибки чтения не определен: строка берется из ParseError
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				return nil, fmt.Errorf("csv line %d: %w", perr.StartLine, perr.Err)
+			}
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, row{ref: fmt.Sprintf("line %d", line), cells: record})
+	}
+	return rows, nil
+}
+
+var numberPattern = regexp.MustCompile(`[-+]?\d[\d\s\x{00A0}\x{202F}.,]*(?:[eE][-+]?\d+)?`)
+
+// parseNumber разбирает число из прайса: «1 234,50 ₽», «1.234,5», «1234.5 руб.»
+func parseNumber(s string) (float64, error) {
+	raw := numberPattern.FindString(s)
+	if raw == "" {
+		return 0, fmt.Errorf("no number in %q", s)
+	}
+	raw = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, raw)
+	raw = strings.TrimRight(raw, ".,")
+
+	lastComma, lastDot := strings.LastIndex(raw, ","), strings.LastIndex(raw, ".")
+	switch {
+	case lastComma >= 0 && lastDot >= 0:
+		if lastComma > lastDot {
+			raw = strings.ReplaceAll(raw, ".", "")
+			raw = strings.Replace(raw, ",", ".", 1)
+		} else {
+			raw = strings.ReplaceAll(raw, ",", "")
+		}
+	case lastComma >= 0:
+		if strings.Count(raw, ",") > 1 {
+			raw = strings.ReplaceAll(raw, ",", "")
+		} else {
+			raw = strings.Replace(raw, ",", ".", 1)
+		}
+	case strings.Count(raw, ".") > 1:
+		raw = strings.ReplaceAll(raw, ".", "")
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// materials находит строку заголовка и превращает следующие строки в материалы.
+// Строка с единственной заполненной ячейкой (без цены) считается
+// заголовком группы и задает категорию для следующих строк.
+func (m Mapping) materials(rows []row) ([]supplier.Material, []supplier.ItemError, error) {
+	header := -1
+	var columns map[string]int
+	for i := 0; i < len(rows) && i < m.HeaderSearchRows; i++ {
+		if cols, ok := m.matchHeader(rows[i].cells); ok {
+			header, columns = i, cols
+			break
+		}
+	}
+	if header < 0 {
+		return nil, nil, fmt.Errorf("header row not found in the first %d rows: need columns %s", m.HeaderSearchRows, strings.Join(requiredFields, ", "))
+	}
+
+	mapped := make(map[int]bool, len(columns))
+	for _, i := range columns {
+		mapped[i] = true
+	}
+	headers := rows[header].cells
+
+	var (
+		materials []supplier.Material
+		rowErrors []supplier.ItemError
+		group     string
+	)
+	for _, r := range rows[header+1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(r.cells) {
+				return strings.TrimSpace(r.cells[i])
+			}
+			return ""
+		}
+
+		filled := 0
+		lastFilled := ""
+		for _, cell := range r.cells {
+			if v := strings.TrimSpace(cell); v != "" {
+				filled++
+				lastFilled = v
+			}
+		}
+		if filled == 0 {
+			continue
+		}
+		if filled == 1 && field(FieldPricePerM2) == "" {
+			group = lastFilled
+			continue
+		}
+
+		mat := supplier.Material{
+			Supplier:     m.Supplier,
+			SupplierCode: field(FieldSupplierCode),
+			Name:         field(FieldName),
+			Category:     field(FieldCategory),
+			Color:        field(FieldColor),
+			ImageURL:     field(FieldImageURL),
+			Description:  field(FieldDescription),
+		}
+		if mat.Category == "" {
+			mat.Category = group
+		}
+		if mat.Category == "" {
+			mat.Category = m.Category
+		}
+		if mat.SupplierCode != "" && m.CodePrefix != "" && !strings.HasPrefix(NormalizeCode(mat.SupplierCode), NormalizeCode(m.CodePrefix)) {
+			mat.SupplierCode = m.CodePrefix + mat.SupplierCode
+		}
+
+		var lineErrs []string
+		if mat.SupplierCode == "" {
+			lineErrs = append(lineErrs, "empty supplierCode")
+		}
+		if mat.Name == "" {
+			lineErrs = append(lineErrs, "empty name")
+		}
+		price, err := parseNumber(field(FieldPricePerM2))
+		if err != nil || price <= 0 {
+			lineErrs = append(lineErrs, fmt.Sprintf("invalid pricePerM2 %q", field(FieldPricePerM2)))
+		}
+		mat.PricePerM2 = math.Round(price*100) / 100
+		if v := field(FieldLightTransmission); v != "" {
+			lt, err := parseNumber(v)
+			if err != nil || lt < 0 || lt > 100 {
+				lineErrs = append(lineErrs, fmt.Sprintf("invalid lightTransmission %q", v))
+			}
+			n := int(math.Round(lt))
+			mat.LightTransmission = &n
+		}
+		if len(lineErrs) > 0 {
+			rowErrors = append(rowErrors, supplier.ItemError{URL: r.ref, Error: strings.Join(lineErrs, "; ")})
+			continue
+		}
+
+		for i, cell := range r.cells {
+			v := strings.TrimSpace(cell)
+			if mapped[i] || v == "" || i >= len(headers) || strings.TrimSpace(headers[i]) == "" {
+				continue
+			}
+			if mat.Attributes == nil {
+				mat.Attributes = make(map[string]string)
+			}
+			mat.Attributes[strings.TrimSpace(headers[i])] = v
+		}
+		materials = append(materials, mat)
+	}
+	return materials, rowErrors, nil
+}
@@ -0,0 +1,196 @@
+package catalogimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Минимальный разбор XLSX: значения ячеек одного листа как текст.
+// Формулы, стили и даты не вычисляются — в прайс-листах нужны только
+// артикулы, названия и числа.
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText — строка общей таблицы или inline-строка: простой текст
+// или набор фрагментов с форматированием
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	b.WriteString(t.T)
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readZipXML(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("xlsx: %s not found", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("xlsx: %s: %w", name, err)
+	}
+	return nil
+}
+
+// columnIndex переводит ссылку на ячейку (C12) в номер колонки с нуля
+func columnIndex(ref string) int {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+	}
+	return n - 1
+}
+
+// readXLSX читает лист sheet (пусто — первый) и возвращает его название и строки
+func readXLSX(data []byte, sheet string) (string, []row, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, fmt.Errorf("xlsx: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err := readZipXML(files, "xl/workbook.xml", &workbook); err != nil {
+		return "", nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", nil, fmt.Errorf("xlsx: no sheets")
+	}
+	ws := workbook.Sheets[0]
+	if sheet != "" {
+		found := false
+		for _, s := range workbook.Sheets {
+			if strings.EqualFold(strings.TrimSpace(s.Name), strings.TrimSpace(sheet)) {
+				ws, found = s, true
+				break
+			}
+		}
+		if !found {
+			return "", nil, fmt.Errorf("xlsx: sheet %q not found", sheet)
+		}
+	}
+
+	var rels xlsxRelationships
+	if err := readZipXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", nil, err
+	}
+	target := ""
+	for _, rel := range rels.Items {
+		if rel.ID == ws.RID {
+			target = rel.Target
+			break
+		}
+	}
+	if target == "" {
+		return "", nil, fmt.Errorf("xlsx: sheet %q has no relationship", ws.Name)
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := readZipXML(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var worksheet xlsxWorksheet
+	if err := readZipXML(files, target, &worksheet); err != nil {
+		return "", nil, err
+	}
+
+	rows := make([]row, 0, len(worksheet.Rows))
+	for i, r := range worksheet.Rows {
+		number := r.R
+		if number == 0 {
+			number = i + 1
+		}
+		var cells []string
+		for j, c := range r.Cells {
+			col := j
+			if c.Ref != "" {
+				col = columnIndex(c.Ref)
+			}
+			if col < 0 {
+				continue
+			}
+
+			value := c.Value
+			switch c.Type {
+			case "s":
+				idx, err := strconv.Atoi(strings.TrimSpace(c.Value))
+				if err != nil || idx < 0 || idx >= len(shared.Items) {
+					return "", nil, fmt.Errorf("xlsx: row %d: invalid shared string %q", number, c.Value)
+				}
+				value = shared.Items[idx].String()
+			case "inlineStr":
+				value = c.Inline.String()
+			}
+
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			cells[col] = value
+		}
+		rows = append(rows, row{ref: fmt.Sprintf("%s row %d", ws.Name, number), cells: cells})
+	}
+	return ws.Name, rows, nil
+}
@@ -0,0 +1,231 @@
+// Package httpcache — HTTP-транспорт с записью ответов на диск для парсеров
+// поставщиков: запись фикстур, воспроизведение без сети и обычный режим
+// с дисковым кешем и условными запросами (ETag / If-Modified-Since).
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mode — режим работы транспорта
+type Mode string
+
+const (
+	// ModeNormal ходит в сеть, кеширует успешные ответы и перепроверяет их
+	// условными запросами
+	ModeNormal Mode = "normal"
+	// ModeRecord ходит в сеть и сохраняет каждый ответ вместе с заголовками
+	ModeRecord Mode = "record"
+	// ModeReplay отвечает только из сохраненных ответов, без сети
+	ModeReplay Mode = "replay"
+)
+
+// ErrNotRecorded возвращается в режиме replay для незаписанного URL
+var ErrNotRecorded = errors.New("httpcache: response not recorded")
+
+// ParseMode разбирает режим из флага командной строки
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeNormal, ModeRecord, ModeReplay:
+		return m, nil
+	case "":
+		return ModeNormal, nil
+	default:
+		return "", fmt.Errorf("httpcache: unknown mode %q (normal, record, replay)", s)
+	}
+}
+
+// Transport реализует http.RoundTripper. Кешируются только GET-запросы.
+type Transport struct {
+	Mode Mode
+	Dir  string
+	// MaxAge — сколько ответ из кеша отдается без перепроверки в режиме
+	// normal; 0 — перепроверять всегда
+	MaxAge time.Duration
+	// Base — транспорт для сетевых запросов, по умолчанию http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// entry — метаданные сохраненного ответа; тело лежит рядом в файле .body
+type entry struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	SavedAt    time.Time   `json:"savedAt"`
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// key — имя файлов записи: хеш метода и URL
+func key(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+	return hex.EncodeToString(sum[:12])
+}
+
+func (t *Transport) paths(req *http.Request) (meta, body string) {
+	k := key(req)
+	return filepath.Join(t.Dir, k+".json"), filepath.Join(t.Dir, k+".body")
+}
+
+func (t *Transport) load(req *http.Request) (*entry, []byte, error) {
+	metaPath, bodyPath := t.paths(req)
+	raw, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var e entry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, nil, fmt.Errorf("httpcache: %s: %w", metaPath, err)
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &e, body, nil
+}
+
+func (t *Transport) save(req *http.Request, e entry, body []byte) error {
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return err
+	}
+	metaPath, bodyPath := t.paths(req)
+	raw, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(bodyPath, body, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, raw, 0o644)
+}
+
+func response(req *http.Request, e *entry, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// fetch выполняет сетевой запрос и читает тело целиком
+func (t *Transport) fetch(req *http.Request) (*entry, []byte, error) {
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &entry{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		SavedAt:    time.Now(),
+	}, body, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		if t.Mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, req.URL)
+		}
+		return t.base().RoundTrip(req)
+	}
+
+	switch t.Mode {
+	case ModeReplay:
+		e, body, err := t.load(req)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotRecorded, req.URL)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return response(req, e, body), nil
+
+	case ModeRecord:
+		e, body, err := t.fetch(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.save(req, *e, body); err != nil {
+			return nil, err
+		}
+		return response(req, e, body), nil
+
+	default:
+		return t.revalidate(req)
+	}
+}
+
+// revalidate — обычный режим: свежий кеш отдается сразу, устаревший
+// перепроверяется условным запросом, 304 продлевает запись.
+func (t *Transport) revalidate(req *http.Request) (*http.Response, error) {
+	cached, cachedBody, err := t.load(req)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if cached != nil && t.MaxAge > 0 && time.Since(cached.SavedAt) < t.MaxAge {
+		return response(req, cached, cachedBody), nil
+	}
+
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	e, body, err := t.fetch(req)
+	if err != nil {
+		return nil, err
+	}
+	if e.StatusCode == http.StatusNotModified && cached != nil {
+		for k, v := range e.Header {
+			if k == "Etag" || k == "Last-Modified" || k == "Cache-Control" || k == "Expires" || k == "Date" {
+				cached.Header[k] = v
+			}
+		}
+		cached.SavedAt = e.SavedAt
+		if err := t.save(req, *cached, cachedBody); err != nil {
+			return nil, err
+		}
+		return response(req, cached, cachedBody), nil
+	}
+	if e.StatusCode == http.StatusOK {
+		if err := t.save(req, *e, body); err != nil {
+			return nil, err
+		}
+	}
+	return response(req, e, body), nil
+}
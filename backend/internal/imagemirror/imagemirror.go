@@ -0,0 +1,335 @@
+// Package imagemirror копирует изображения поставщика к себе: скачивает,
+// проверяет, что это действительно картинка, и сохраняет под именем по хешу
+// содержимого. Одинаковые картинки по разным адресам хранятся один раз.
+//
+// Рядом с файлами лежит index.json — соответствие адреса источника локальному
+// файлу, чтобы повторный запуск не скачивал уже сохраненное.
+package imagemirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	indexFile       = "index.json"
+	defaultMaxBytes = 10 << 20
+	maxDimension    = 12000
+)
+
+// Status — как получен локальный файл
+type Status string
+
+const (
+	// StatusLocal — адрес уже указывает на наше хранилище
+	StatusLocal Status = "local"
+	// StatusCached — адрес уже скачивался, файл на месте
+	StatusCached Status = "cached"
+	// StatusReused — скачано, но такой файл уже был под другим адресом
+	StatusReused Status = "reused"
+	// StatusDownloaded — скачан и сохранен новый файл
+	StatusDownloaded Status = "downloaded"
+)
+
+var (
+	ErrNotImage       = errors.New("not an image")
+	ErrTooLarge       = errors.New("image is too large")
+	ErrMissingLocal   = errors.New("local file is missing")
+	ErrUnsupportedURL = errors.New("unsupported URL")
+)
+
+// Entry — запись индекса
+type Entry struct {
+	Source      string    `json:"source"`
+	URL         string    `json:"url"`
+	Hash        string    `json:"hash"`
+	ContentType string    `json:"contentType"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	Size        int64     `json:"size"`
+	FetchedAt   time.Time `json:"fetchedAt"`
+}
+
+// Result — итог обработки одного адреса
+type Result struct {
+	Status Status `json:"status"`
+	Entry
+}
+
+// Mirror — локальное хранилище изображений. Безопасен для одновременного
+// использования; загрузки выполняются по одной.
+type Mirror struct {
+	dir       string
+	urlPrefix string
+
+	// Client — HTTP-клиент для загрузки; по умолчанию с таймаутом 30 с
+	Client *http.Client
+	// MaxBytes — максимальный размер файла
+	MaxBytes int64
+	// Delay — минимальная пауза между запросами к источнику
+	Delay time.Duration
+
+	mu      sync.Mutex
+	index   map[string]Entry
+	lastGet time.Time
+}
+
+// Open открывает хранилище в каталоге dir; файлы доступны по адресам
+// urlPrefix/<xx>/<hash>.<ext>
+func Open(dir, urlPrefix string) (*Mirror, error) {
+	m := &Mirror{
+		dir:       dir,
+		urlPrefix: strings.TrimRight(urlPrefix, "/"),
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		MaxBytes:  defaultMaxBytes,
+		index:     make(map[string]Entry),
+	}
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("imagemirror: %s: %w", indexFile, err)
+	}
+	for _, e := range entries {
+		m.index[e.Source] = e
+	}
+	return m, nil
+}
+
+// IsLocal — адрес указывает на наше хранилище
+func (m *Mirror) IsLocal(rawURL string) bool {
+	return strings.HasPrefix(rawURL, m.urlPrefix+"/")
+}
+
+// localPath — путь к файлу по локальному адресу
+func (m *Mirror) localPath(localURL string) string {
+	rel := strings.TrimPrefix(localURL, m.urlPrefix+"/")
+	return filepath.Join(m.dir, filepath.FromSlash(path.Clean("/"+rel)))
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+// Lookup — локальный адрес уже скопированного изображения
+func (m *Mirror) Lookup(source string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.index[source]
+	return e.URL, ok
+}
+
+// Fetch возвращает локальную копию изображения по адресу source, при
+// необходимости скачивая его
+func (m *Mirror) Fetch(ctx context.Context, source string) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.IsLocal(source) {
+		if !exists(m.localPath(source)) {
+			return Result{}, ErrMissingLocal
+		}
+		return Result{Status: StatusLocal, Entry: Entry{Source: source, URL: source}}, nil
+	}
+	if e, ok := m.index[source]; ok && exists(m.localPath(e.URL)) {
+		return Result{Status: StatusCached, Entry: e}, nil
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Result{}, ErrUnsupportedURL
+	}
+
+	data, err := m.download(ctx, source)
+	if err != nil {
+		return Result{}, err
+	}
+	e, err := verify(data)
+	if err != nil {
+		return Result{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	e.Source = source
+	e.Hash = hex.EncodeToString(sum[:])
+	e.Size = int64(len(data))
+	e.FetchedAt = time.Now()
+	e.URL = fmt.Sprintf("%s/%s/%s%s", m.urlPrefix, e.Hash[:2], e.Hash, extension(e.ContentType))
+
+	status := StatusReused
+	if p := m.localPath(e.URL); !exists(p) {
+		if err := writeAtomic(p, data); err != nil {
+			return Result{}, err
+		}
+		status = StatusDownloaded
+	}
+	m.index[source] = e
+	return Result{Status: status, Entry: e}, nil
+}
+
+// Put сохраняет загруженное изображение, например фотографию с телефона.
+// В индекс оно не попадает: источника у него нет.
+func (m *Mirror) Put(data []byte) (Entry, error) {
+	if int64(len(data)) > m.MaxBytes {
+		return Entry{}, ErrTooLarge
+	}
+	e, err := verify(data)
+	if err != nil {
+		return Entry{}, err
+	}
+	sum := sha256.Sum256(data)
+	e.Hash = hex.EncodeToString(sum[:])
+	e.Size = int64(len(data))
+	e.FetchedAt = time.Now()
+	e.URL = fmt.Sprintf("%s/%s/%s%s", m.urlPrefix, e.Hash[:2], e.Hash, extension(e.ContentType))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p := m.localPath(e.URL); !exists(p) {
+		if err := writeAtomic(p, data); err != nil {
+			return Entry{}, err
+		}
+	}
+	return e, nil
+}
+
+func (m *Mirror) download(ctx context.Context, source string) ([]byte, error) {
+	if wait := m.Delay - time.Since(m.lastGet); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	m.lastGet = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/*")
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, m.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > m.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// verify проверяет, что данные — изображение JPEG, PNG, GIF или WebP
+// разумного размера. Сайты часто отдают вместо картинки HTML-страницу с 200.
+func verify(data []byte) (Entry, error) {
+	contentType := http.DetectContentType(data)
+	var e Entry
+	e.ContentType = contentType
+
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return e, fmt.Errorf("%w: %v", ErrNotImage, err)
+		}
+		e.Width, e.Height = cfg.Width, cfg.Height
+	case "image/webp":
+		// Размеры WebP без внешних библиотек не читаем, проверяем заголовок
+		if len(data) < 16 || !bytes.Equal(data[8:12], []byte("WEBP")) {
+			return e, ErrNotImage
+		}
+		return e, nil
+	default:
+		return e, fmt.Errorf("%w: %s", ErrNotImage, contentType)
+	}
+
+	if e.Width <= 0 || e.Height <= 0 || e.Width > maxDimension || e.Height > maxDimension {
+		return e, fmt.Errorf("%w: %dx%d", ErrNotImage, e.Width, e.Height)
+	}
+	return e, nil
+}
+
+func extension(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
+
+func writeAtomic(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Save записывает индекс на диск
+func (m *Mirror) Save() error {
+	m.mu.Lock()
+	entries := make([]Entry, 0, len(m.index))
+	for _, e := range m.index {
+		entries = append(entries, e)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Source < entries[j].Source })
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(filepath.Join(m.dir, indexFile), data)
+}
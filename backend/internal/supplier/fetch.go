@@ -0,0 +1,140 @@
+package supplier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/httpcache"
+)
+
+// Fetcher загружает страницу по URL
+type Fetcher interface {
+	Fetch(ctx context.Context, pageURL string) ([]byte, error)
+}
+
+// rateLimiter пропускает сетевые запросы не чаще одного раза в delay.
+// Стоит под кешем, поэтому ответы из кеша и фикстур не ждут.
+type rateLimiter struct {
+	base  http.RoundTripper
+	delay time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if pause := time.Until(l.last.Add(l.delay)); pause > 0 {
+		select {
+		case <-time.After(pause):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	l.last = time.Now()
+	return nil
+}
+
+func (l *rateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := l.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return l.base.RoundTrip(req)
+}
+
+// HTTPOptions — настройки сетевой загрузки
+type HTTPOptions struct {
+	// Delay — минимальная пауза между сетевыми запросами
+	Delay    time.Duration
+	Mode     httpcache.Mode
+	CacheDir string
+	MaxAge   time.Duration
+}
+
+// HTTPFetcher ходит на сайт через дисковый кеш httpcache
+type HTTPFetcher struct {
+	client *http.Client
+}
+
+func NewHTTPFetcher(opts HTTPOptions) *HTTPFetcher {
+	return &HTTPFetcher{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &httpcache.Transport{
+				Mode:   opts.Mode,
+				Dir:    opts.CacheDir,
+				MaxAge: opts.MaxAge,
+				Base:   &rateLimiter{base: http.DefaultTransport, delay: opts.Delay},
+			},
+		},
+	}
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, pageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "JaluxiCatalogBot/1.0 (+https://piter-jaluzi.ru)")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+}
+
+// FixtureFetcher читает сохраненные страницы из каталога вместо сайта.
+// Имя файла строится из пути и параметров URL, см. FixtureName.
+type FixtureFetcher struct {
+	Dir string
+}
+
+var fixtureUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// FixtureName: /catalog/rulonnaya-komplektatsiya/?PAGEN_1=2 →
+// catalog_rulonnaya-komplektatsiya_PAGEN_1_2.html
+func FixtureName(pageURL string) (string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	name := strings.Trim(u.Path, "/")
+	if u.RawQuery != "" {
+		name += "_" + u.RawQuery
+	}
+	name = strings.Trim(fixtureUnsafe.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		name = "index"
+	}
+	return name + ".html", nil
+}
+
+func (f FixtureFetcher) Fetch(_ context.Context, pageURL string) ([]byte, error) {
+	name, err := FixtureName(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(f.Dir, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no fixture %s", name)
+	}
+	return data, err
+}
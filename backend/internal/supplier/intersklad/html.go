@@ -0,0 +1,251 @@
+package intersklad
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	errNoCode = errors.New("supplier code not found")
+	errNoName = errors.New("name not found")
+)
+
+// CategoryPage — страница списка товаров категории
+type CategoryPage struct {
+	ProductURLs []string
+	NextURL     string
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// findAll возвращает все элементы поддерева, для которых match вернул true
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && match(n) {
+			found = append(found, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return found
+}
+
+func findFirst(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if found := findAll(n, match); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+func byItemprop(prop string) func(*html.Node) bool {
+	return func(n *html.Node) bool { return attr(n, "itemprop") == prop }
+}
+
+func byClass(class string) func(*html.Node) bool {
+	return func(n *html.Node) bool { return hasClass(n, class) }
+}
+
+// text собирает текст элемента, схлопывая пробелы
+func text(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var buf strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+			buf.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// itempropValue — значение микроразметки: content, затем текст
+func itempropValue(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	if v := strings.TrimSpace(attr(n, "content")); v != "" {
+		return v
+	}
+	return text(n)
+}
+
+func resolveURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// parseCategoryPage извлекает ссылки на карточки товаров и следующую страницу
+func parseCategoryPage(body []byte, pageURL string) (CategoryPage, error) {
+	var page CategoryPage
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return page, err
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return page, err
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range findAll(doc, byClass("product-item")) {
+		link := findFirst(item, func(n *html.Node) bool { return n.DataAtom == atom.A && attr(n, "href") != "" })
+		if link == nil {
+			continue
+		}
+		if u := resolveURL(base, attr(link, "href")); u != "" && !seen[u] {
+			seen[u] = true
+			page.ProductURLs = append(page.ProductURLs, u)
+		}
+	}
+
+	next := findFirst(doc, func(n *html.Node) bool {
+		return (n.DataAtom == atom.A || n.DataAtom == atom.Link) && attr(n, "rel") == "next" ||
+			n.DataAtom == atom.A && hasClass(n, "modern-page-next")
+	})
+	if next != nil {
+		page.NextURL = resolveURL(base, attr(next, "href"))
+	}
+	return page, nil
+}
+
+// parseProduct разбирает карточку товара в сыром виде. Цена остается
+// текстом и разбирается при нормализации.
+func parseProduct(body []byte, pageURL string) (supplier.Item, error) {
+	item := supplier.Item{SourceURL: pageURL}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return item, err
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return item, err
+	}
+
+	product := findFirst(doc, func(n *html.Node) bool {
+		return strings.HasSuffix(attr(n, "itemtype"), "schema.org/Product")
+	})
+	if product == nil {
+		product = doc
+	}
+
+	item.Name = itempropValue(findFirst(product, byItemprop("name")))
+	if item.Name == "" {
+		item.Name = text(findFirst(doc, func(n *html.Node) bool { return n.DataAtom == atom.H1 }))
+	}
+	if item.Name == "" {
+		return item, errNoName
+	}
+
+	item.Code = itempropValue(findFirst(product, byItemprop("sku")))
+	if item.Code == "" {
+		item.Code = strings.TrimSpace(strings.TrimPrefix(text(findFirst(product, byClass("product-article"))), "Артикул:"))
+	}
+	if item.Code == "" {
+		return item, errNoCode
+	}
+
+	priceNode := findFirst(product, byItemprop("price"))
+	if priceNode == nil {
+		priceNode = findFirst(product, byClass("product-price"))
+	}
+	item.PriceText = itempropValue(priceNode)
+
+	for _, img := range findAll(product, byItemprop("image")) {
+		ref := attr(img, "src")
+		if ref == "" {
+			ref = attr(img, "href")
+		}
+		if ref == "" {
+			ref = attr(img, "content")
+		}
+		if u := resolveURL(base, ref); u != "" && !contains(item.Images, u) {
+			item.Images = append(item.Images, u)
+		}
+	}
+
+	item.Description = itempropValue(findFirst(product, byItemprop("description")))
+	item.Attributes = parseAttributes(product)
+	return item, nil
+}
+
+// parseAttributes читает характеристики из таблицы или списка .product-props
+func parseAttributes(n *html.Node) map[string]string {
+	attrs := make(map[string]string)
+	props := findFirst(n, byClass("product-props"))
+	if props == nil {
+		return attrs
+	}
+	for _, row := range findAll(props, func(n *html.Node) bool { return n.DataAtom == atom.Tr }) {
+		cells := findAll(row, func(n *html.Node) bool { return n.DataAtom == atom.Th || n.DataAtom == atom.Td })
+		if len(cells) >= 2 {
+			addAttribute(attrs, text(cells[0]), text(cells[1]))
+		}
+	}
+	for _, dt := range findAll(props, func(n *html.Node) bool { return n.DataAtom == atom.Dt }) {
+		dd := dt.NextSibling
+		for dd != nil && dd.Type != html.ElementNode {
+			dd = dd.NextSibling
+		}
+		if dd != nil && dd.DataAtom == atom.Dd {
+			addAttribute(attrs, text(dt), text(dd))
+		}
+	}
+	return attrs
+}
+
+func addAttribute(attrs map[string]string, key, value string) {
+	key = strings.TrimSuffix(strings.TrimSpace(key), ":")
+	if key != "" && value != "" {
+		attrs[key] = value
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
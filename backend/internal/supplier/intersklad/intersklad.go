@@ -0,0 +1,176 @@
+// Package intersklad — адаптер оптового поставщика intersklad.ru: обходит
+// категории каталога с пагинацией и разбирает карточки товаров.
+package intersklad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+const (
+	Name    = "intersklad"
+	baseURL = "https://www.intersklad.ru"
+)
+
+var errNoPrice = errors.New("price not found")
+
+// categories — разделы каталога, из которых берем материалы
+var categories = []supplier.Category{
+	{ID: "gorizontalnaya-komplektatsiya", Name: "Горизонтальные жалюзи", URL: baseURL + "/catalog/gorizontalnaya-komplektatsiya/"},
+	{ID: "vertikalnaya-komplektatsiya", Name: "Вертикальные жалюзи", URL: baseURL + "/catalog/vertikalnaya-komplektatsiya/"},
+	{ID: "rulonnaya-komplektatsiya", Name: "Рулонные шторы", URL: baseURL + "/catalog/rulonnaya-komplektatsiya/"},
+}
+
+func init() {
+	supplier.Register(Name, func(opts supplier.Options) supplier.Supplier {
+		return &Intersklad{fetch: opts.Fetcher, maxPages: opts.MaxPages}
+	})
+}
+
+// Intersklad реализует supplier.Supplier
+type Intersklad struct {
+	fetch    supplier.Fetcher
+	maxPages int
+}
+
+func (s *Intersklad) Name() string { return Name }
+
+func (s *Intersklad) Source() string { return baseURL + "/" }
+
+func (s *Intersklad) Categories(context.Context) ([]supplier.Category, error) {
+	return append([]supplier.Category(nil), categories...), nil
+}
+
+// FetchItems обходит все страницы категории и разбирает карточки товаров
+func (s *Intersklad) FetchItems(ctx context.Context, category supplier.Category) ([]supplier.Item, []supplier.ItemError, error) {
+	var (
+		items  []supplier.Item
+		failed []supplier.ItemError
+	)
+	visited := make(map[string]bool)
+	pageURL := category.URL
+	for page := 1; pageURL != "" && page <= s.maxPages; page++ {
+		if visited[pageURL] {
+			break
+		}
+		visited[pageURL] = true
+
+		// Сбой на следующих страницах не отменяет уже собранные товары
+		body, err := s.fetch.Fetch(ctx, pageURL)
+		if err != nil {
+			if page == 1 {
+				return nil, failed, err
+			}
+			failed = append(failed, supplier.ItemError{URL: pageURL, Error: err.Error()})
+			break
+		}
+		listing, err := parseCategoryPage(body, pageURL)
+		if err != nil {
+			failed = append(failed, supplier.ItemError{URL: pageURL, Error: err.Error()})
+			break
+		}
+
+		for _, productURL := range listing.ProductURLs {
+			body, err := s.fetch.Fetch(ctx, productURL)
+			if err != nil {
+				failed = append(failed, supplier.ItemError{URL: productURL, Error: err.Error()})
+				continue
+			}
+			item, err := parseProduct(body, productURL)
+			if err != nil {
+				failed = append(failed, supplier.ItemError{URL: productURL, Error: err.Error()})
+				continue
+			}
+			item.Category = category
+			items = append(items, item)
+		}
+		pageURL = listing.NextURL
+	}
+	return items, failed, nil
+}
+
+// Normalize приводит товар к общей записи. Цену не угадываем: если ее нет
+// или она не разбирается, возвращается ошибка.
+func (s *Intersklad) Normalize(item supplier.Item) (supplier.Material, error) {
+	m := supplier.Material{
+		SupplierCode: supplierCode(item.Code),
+		Name:         item.Name,
+		Category:     item.Category.Name,
+		Images:       item.Images,
+		Description:  item.Description,
+		Attributes:   item.Attributes,
+		SourceURL:    item.SourceURL,
+	}
+	price, err := parsePrice(item.PriceText)
+	if err != nil {
+		return m, err
+	}
+	m.PricePerM2 = price
+	if len(m.Images) > 0 {
+		m.ImageURL = m.Images[0]
+	}
+
+	// Цвет, ширина ламели и тип ткани приводятся к словарю характеристик
+	// при импорте; здесь остается то, что указал поставщик. Без указанной
+	// светопропускаемости поле остается пустым: ее подставит словарь по типу
+	// ткани, а иначе импорт не тронет значение в каталоге.
+	m.Color = item.Attributes["Цвет"]
+	if v, ok := item.Attributes["Светопропускаемость"]; ok {
+		if lt, err := parsePercent(v); err == nil {
+			m.LightTransmission = &lt
+		}
+	}
+	return m, nil
+}
+
+// supplierCode приводит артикул поставщика к виду INT-<артикул>
+func supplierCode(code string) string {
+	code = strings.ToUpper(strings.Join(strings.Fields(code), "-"))
+	if strings.HasPrefix(code, "INT-") {
+		return code
+	}
+	return "INT-" + code
+}
+
+var numberPattern = regexp.MustCompile(`\d[\d\s\x{00a0}]*(?:[.,]\d+)?`)
+
+// parsePrice парсит цену из текста вида "1 250,50 руб./м²"
+func parsePrice(priceText string) (float64, error) {
+	match := numberPattern.FindString(priceText)
+	if match == "" {
+		return 0, fmt.Errorf("%w in %q", errNoPrice, priceText)
+	}
+	match = strings.Map(func(r rune) rune {
+		switch {
+		case r == ',':
+			return '.'
+		case unicode.IsSpace(r):
+			return -1
+		}
+		return r
+	}, match)
+	price, err := strconv.ParseFloat(match, 64)
+	if err != nil || price <= 0 {
+		return 0, fmt.Errorf("invalid price %q", priceText)
+	}
+	return price, nil
+}
+
+func parsePercent(s string) (int, error) {
+	match := numberPattern.FindString(s)
+	if match == "" {
+		return 0, fmt.Errorf("no number in %q", s)
+	}
+	v, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(match), ",", "."), 64)
+	if err != nil || v < 0 || v > 100 {
+		return 0, fmt.Errorf("invalid percent %q", s)
+	}
+	return int(v + 0.5), nil
+}
@@ -0,0 +1,238 @@
+package intersklad
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/supplier"
+)
+
+func newFixtureSupplier(maxPages int) *Intersklad {
+	return &Intersklad{fetch: supplier.FixtureFetcher{Dir: "testdata"}, maxPages: maxPages}
+}
+
+func readFixture(t *testing.T, pageURL string) []byte {
+	t.Helper()
+	name, err := supplier.FixtureName(pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestParseCategoryPage(t *testing.T) {
+	tests := []struct {
+		url      string
+		products []string
+		next     string
+	}{
+		{
+			url: baseURL + "/catalog/gorizontalnaya-komplektatsiya/",
+			products: []string{
+				baseURL + "/catalog/gorizontalnaya-komplektatsiya/alyuminievye-25-belyy/",
+				baseURL + "/catalog/gorizontalnaya-komplektatsiya/alyuminievye-25-korichnevyy/",
+			},
+			next: baseURL + "/catalog/gorizontalnaya-komplektatsiya/?PAGEN_1=2",
+		},
+		{
+			url: baseURL + "/catalog/gorizontalnaya-komplektatsiya/?PAGEN_1=2",
+			products: []string{
+				baseURL + "/catalog/gorizontalnaya-komplektatsiya/derevyannye-50-dub/",
+				baseURL + "/catalog/gorizontalnaya-komplektatsiya/alyuminievye-16-serebro/",
+			},
+		},
+		{
+			url:      baseURL + "/catalog/vertikalnaya-komplektatsiya/",
+			products: []string{baseURL + "/catalog/vertikalnaya-komplektatsiya/tkan-89-bezhevyy/"},
+		},
+		{
+			url:      baseURL + "/catalog/rulonnaya-komplektatsiya/",
+			products: []string{baseURL + "/catalog/rulonnaya-komplektatsiya/blackout-seryy/"},
+		},
+	}
+	for _, tt := range tests {
+		page, err := parseCategoryPage(readFixture(t, tt.url), tt.url)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.url, err)
+		}
+		if !reflect.DeepEqual(page.ProductURLs, tt.products) {
+			t.Errorf("%s: products = %v, want %v", tt.url, page.ProductURLs, tt.products)
+		}
+		if page.NextURL != tt.next {
+			t.Errorf("%s: next = %q, want %q", tt.url, page.NextURL, tt.next)
+		}
+	}
+}
+
+func TestParseProduct(t *testing.T) {
+	pageURL := baseURL + "/catalog/gorizontalnaya-komplektatsiya/alyuminievye-25-belyy/"
+	item, err := parseProduct(readFixture(t, pageURL), pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := supplier.Item{
+		SourceURL: pageURL,
+		Code:      "HOR-ALU-25-WHITE",
+		Name:      "Алюминиевые горизонтальные жалюзи 25мм, белый",
+		PriceText: "450",
+		Images: []string{
+			baseURL + "/upload/iblock/123/horizontal_white.jpg",
+			baseURL + "/upload/iblock/123/horizontal_white_2.jpg",
+		},
+		Description: "Классические алюминиевые жалюзи 25мм для офисов и домов",
+		Attributes: map[string]string{
+			"Цвет":                "Белый",
+			"Ширина ламели":       "25 мм",
+			"Материал":            "Алюминий",
+			"Светопропускаемость": "70%",
+		},
+	}
+	if !reflect.DeepEqual(item, want) {
+		t.Errorf("item = %+v, want %+v", item, want)
+	}
+}
+
+func TestFetchItemsFollowsPagination(t *testing.T) {
+	s := newFixtureSupplier(50)
+	items, failed, err := s.FetchItems(context.Background(), categories[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("failed = %v, want none", failed)
+	}
+	var codes []string
+	for _, item := range items {
+		codes = append(codes, item.Code)
+		if item.Category != categories[0] {
+			t.Errorf("%s: category = %v", item.Code, item.Category)
+		}
+	}
+	want := []string{"HOR-ALU-25-WHITE", "HOR-ALU-25-BROWN", "HOR-WOOD-50-OAK", "HOR-ALU-16-SILVER"}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("codes = %v, want %v", codes, want)
+	}
+
+	// Ограничение страниц останавливает обход до PAGEN_1=2
+	items, _, err = newFixtureSupplier(1).FetchItems(context.Background(), categories[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Errorf("maxPages=1: %d items, want 2", len(items))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	s := newFixtureSupplier(50)
+	var all []supplier.Item
+	for _, category := range categories {
+		items, _, err := s.FetchItems(context.Background(), category)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, items...)
+	}
+
+	got := make(map[string]supplier.Material)
+	var priceErrors []string
+	for _, item := range all {
+		m, err := s.Normalize(item)
+		if errors.Is(err, errNoPrice) {
+			priceErrors = append(priceErrors, m.SupplierCode)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", item.Code, err)
+		}
+		got[m.SupplierCode] = m
+	}
+
+	// «Цена по запросу» — ошибка, а не нулевая цена
+	if want := []string{"INT-HOR-ALU-16-SILVER"}; !reflect.DeepEqual(priceErrors, want) {
+		t.Errorf("price errors = %v, want %v", priceErrors, want)
+	}
+
+	tests := []struct {
+		code     string
+		category string
+		price    float64
+		color    string
+		light    int
+	}{
+		{"INT-HOR-ALU-25-WHITE", "Горизонтальные жалюзи", 450, "Белый", 70},
+		{"INT-HOR-WOOD-50-OAK", "Горизонтальные жалюзи", 1200, "Дуб", 40},
+		{"INT-ROLLER-BLACKOUT-GREY", "Рулонные шторы", 850, "Серый", 0},
+	}
+	for _, tt := range tests {
+		m, ok := got[tt.code]
+		if !ok {
+			t.Errorf("%s: not normalized", tt.code)
+			continue
+		}
+		if m.LightTransmission == nil {
+			t.Errorf("%s: lightTransmission not set", tt.code)
+			continue
+		}
+		if m.Category != tt.category || m.PricePerM2 != tt.price || m.Color != tt.color || *m.LightTransmission != tt.light {
+			t.Errorf("%s: got %s %v %s %d, want %s %v %s %d", tt.code,
+				m.Category, m.PricePerM2, m.Color, *m.LightTransmission,
+				tt.category, tt.price, tt.color, tt.light)
+		}
+		if m.ImageURL == "" || m.ImageURL != m.Images[0] {
+			t.Errorf("%s: imageURL = %q", tt.code, m.ImageURL)
+		}
+	}
+}
+
+func TestNormalizeWithoutLightTransmission(t *testing.T) {
+	s := newFixtureSupplier(50)
+	for _, attrs := range []map[string]string{
+		{"Цвет": "Белый"},
+		{"Цвет": "Белый", "Светопропускаемость": "высокая"},
+	} {
+		m, err := s.Normalize(supplier.Item{
+			Category:   categories[1],
+			Code:       "VERT-TEST",
+			Name:       "Вертикальные жалюзи",
+			PriceText:  "500",
+			Attributes: attrs,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Значение не придумывается по категории, чтобы импорт не затер каталог
+		if m.LightTransmission != nil {
+			t.Errorf("%v: lightTransmission = %d, want nil", attrs, *m.LightTransmission)
+		}
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		text  string
+		price float64
+		ok    bool
+	}{
+		{"450", 450, true},
+		{"1 200,00 руб./м²", 1200, true},
+		{"1 250,50 руб./м²", 1250.5, true},
+		{"Цена по запросу", 0, false},
+		{"", 0, false},
+		{"0 руб.", 0, false},
+	}
+	for _, tt := range tests {
+		price, err := parsePrice(tt.text)
+		if (err == nil) != tt.ok || price != tt.price {
+			t.Errorf("parsePrice(%q) = %v, %v", tt.text, price, err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package supplier — общий интерфейс источников материалов (оптовых
+// поставщиков) и единый формат выгрузки, который читает импорт каталога.
+//
+// Адаптер поставщика регистрируется в init() своего пакета:
+//
+//	import _ "github.com/ezhigval/piter-jaluzi/backend/internal/supplier/intersklad"
+//
+//	s, err := supplier.New("intersklad", supplier.Options{Fetcher: f})
+package supplier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Category — раздел каталога поставщика
+type Category struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// Item — товар в том виде, в каком его отдал поставщик, до нормализации.
+// Цена остается текстом: разбирать ее — задача Normalize.
+type Item struct {
+	Category    Category          `json:"category"`
+	SourceURL   string            `json:"sourceUrl,omitempty"`
+	Code        string            `json:"code"`
+	Name        string            `json:"name"`
+	PriceText   string            `json:"priceText"`
+	Images      []string          `json:"images,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+// Material — нормализованная запись материала, общая для всех поставщиков.
+// PricePerM2 — закупочная цена поставщика за м². ColorFamily, SlatWidthMm
+// и FabricType заполняет словарь характеристик (internal/attributes).
+// LightTransmission nil — поставщик ее не указал (0 — полное затемнение).
+type Material struct {
+	Supplier          string            `json:"supplier,omitempty"`
+	SupplierCode      string            `json:"supplierCode"`
+	Name              string            `json:"name"`
+	Category          string            `json:"category"`
+	Color             string            `json:"color"`
+	ColorFamily       string            `json:"colorFamily,omitempty"`
+	SlatWidthMm       int               `json:"slatWidthMm,omitempty"`
+	FabricType        string            `json:"fabricType,omitempty"`
+	LightTransmission *int              `json:"lightTransmission,omitempty"`
+	PricePerM2        float64           `json:"pricePerM2"`
+	ImageURL          string            `json:"imageUrl"`
+	Images            []string          `json:"images,omitempty"`
+	Description       string            `json:"description"`
+	Attributes        map[string]string `json:"attributes,omitempty"`
+	SourceURL         string            `json:"sourceUrl,omitempty"`
+}
+
+// ItemError — страница или товар, которые не удалось загрузить или разобрать
+type ItemError struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// Catalog — результат выгрузки поставщика. Формат совместим со старым
+// intersklad_materials.json.
+type Catalog struct {
+	Supplier  string      `json:"supplier,omitempty"`
+	Materials []Material  `json:"materials"`
+	Errors    []ItemError `json:"errors,omitempty"`
+	Source    string      `json:"source"`
+	ParsedAt  string      `json:"parsedAt"`
+}
+
+// Supplier — адаптер поставщика
+type Supplier interface {
+	// Name — имя в реестре, оно же значение поля supplier в выгрузке
+	Name() string
+	// Source — адрес сайта или файла поставщика для выгрузки
+	Source() string
+	Categories(ctx context.Context) ([]Category, error)
+	// FetchItems загружает товары категории. Ошибки отдельных товаров
+	// возвращаются списком, error — только если категорию не загрузить вовсе.
+	FetchItems(ctx context.Context, category Category) ([]Item, []ItemError, error)
+	Normalize(item Item) (Material, error)
+}
+
+// Options — общие настройки адаптеров
+type Options struct {
+	Fetcher  Fetcher
+	MaxPages int
+}
+
+// Factory создает адаптер с заданными настройками
+type Factory func(opts Options) Supplier
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register добавляет адаптер в реестр. Повторная регистрация имени — ошибка программы.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, dup := registry[name]; dup {
+		panic("supplier: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New создает адаптер по имени
+func New(name string, opts Options) (Supplier, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("supplier: unknown supplier %q (available: %v)", name, Names())
+	}
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = 50
+	}
+	return factory(opts), nil
+}
+
+// Names — зарегистрированные поставщики по алфавиту
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Scrape обходит все категории поставщика и собирает нормализованный каталог.
+// Товары, которые не удалось нормализовать, попадают в Errors.
+func Scrape(ctx context.Context, s Supplier) (Catalog, error) {
+	catalog := Catalog{
+		Supplier: s.Name(),
+		Source:   s.Source(),
+		ParsedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	categories, err := s.Categories(ctx)
+	if err != nil {
+		return catalog, err
+	}
+	for _, category := range categories {
+		items, itemErrors, err := s.FetchItems(ctx, category)
+		catalog.Errors = append(catalog.Errors, itemErrors...)
+		if err != nil {
+			catalog.Errors = append(catalog.Errors, ItemError{URL: category.URL, Error: err.Error()})
+			continue
+		}
+		for _, item := range items {
+			material, err := s.Normalize(item)
+			if err != nil {
+				catalog.Errors = append(catalog.Errors, ItemError{URL: item.SourceURL, Error: err.Error()})
+				continue
+			}
+			material.Supplier = s.Name()
+			catalog.Materials = append(catalog.Materials, material)
+		}
+	}
+	return catalog, nil
+}
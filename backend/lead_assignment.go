@@ -0,0 +1,503 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// AssignmentMode — как выбирается менеджер для новой заявки
+type AssignmentMode string
+
+const (
+	// AssignRoundRobin — по очереди: тот, кому дольше всех не назначали
+	AssignRoundRobin AssignmentMode = "round_robin"
+	// AssignByLoad — тот, у кого меньше всего заявок в работе
+	AssignByLoad AssignmentMode = "load"
+)
+
+// leadAssignmentLock — ключ advisory-блокировки, чтобы одновременные
+// заявки не достались одному менеджеру при очереди
+const leadAssignmentLock = 4401
+
+var errManagerNotFound = errors.New("manager not found")
+
+type Manager struct {
+	ID             int64      `json:"id"`
+	Name           string     `json:"name" validate:"required,max=100"`
+	Phone          string     `json:"phone,omitempty" validate:"max=20"`
+	Email          string     `json:"email,omitempty" validate:"omitempty,email,max=200"`
+	TelegramChatID *int64     `json:"telegramChatId,omitempty"`
+	IsActive       bool       `json:"isActive"`
+	OffDuty        bool       `json:"offDuty"`
+	LastAssignedAt *time.Time `json:"lastAssignedAt,omitempty"`
+	// OpenLeads — заявки в работе (до установки, кроме потерянных)
+	OpenLeads int `json:"openLeads"`
+}
+
+// LeadSettings — распределение заявок и срок первого ответа. Срок
+// считается в рабочих часах: с WorkdayStart до WorkdayEnd по дням WorkDays
+// (1 — понедельник, 7 — воскресенье) в часовом поясе Timezone.
+type LeadSettings struct {
+	AssignmentMode     AssignmentMode `json:"assignmentMode" validate:"oneof=round_robin load"`
+	FirstResponseHours float64        `json:"firstResponseHours" validate:"gt=0,lte=240"`
+	WorkdayStart       int            `json:"workdayStart" validate:"gte=0,lte=23"`
+	WorkdayEnd         int            `json:"workdayEnd" validate:"gte=1,lte=24,gtfield=WorkdayStart"`
+	WorkDays           []int          `json:"workDays" validate:"required,min=1,dive,gte=1,lte=7"`
+	Timezone           string         `json:"timezone" validate:"required,max=50"`
+}
+
+var defaultLeadSettings = LeadSettings{
+	AssignmentMode:     AssignRoundRobin,
+	FirstResponseHours: 2,
+	WorkdayStart:       9,
+	WorkdayEnd:         19,
+	WorkDays:           []int{1, 2, 3, 4, 5},
+	Timezone:           "Europe/Moscow",
+}
+
+func (s LeadSettings) location() *time.Location {
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		return loc
+	}
+	return time.Local
+}
+
+func (s LeadSettings) isWorkday(d time.Weekday) bool {
+	return hasWeekday(s.WorkDays, d)
+}
+
+// hasWeekday проверяет, входит ли день в список дней недели
+// (1 — понедельник, 7 — воскресенье)
+func hasWeekday(days []int, d time.Weekday) bool {
+	iso := int(d)
+	if iso == 0 {
+		iso = 7
+	}
+	for _, wd := range days {
+		if wd == iso {
+			return true
+		}
+	}
+	return false
+}
+
+// addWorkingHours — момент, когда от from пройдет hours рабочих часов.
+// Заявка, пришедшая ночью или в выходной, начинает отсчет с начала
+// следующего рабочего дня.
+func (s LeadSettings) addWorkingHours(from time.Time, hours float64) time.Time {
+	if len(s.WorkDays) == 0 || s.WorkdayEnd <= s.WorkdayStart {
+		return from.Add(time.Duration(hours * float64(time.Hour)))
+	}
+	loc := s.location()
+	remaining := time.Duration(hours * float64(time.Hour))
+	t := from.In(loc)
+	// Больше двух лет без рабочих часов не бывает; предел защищает от ошибок настроек
+	for i := 0; i < 730; i++ {
+		y, m, d := t.Date()
+		dayStart := time.Date(y, m, d, s.WorkdayStart, 0, 0, 0, loc)
+		dayEnd := time.Date(y, m, d, s.WorkdayEnd, 0, 0, 0, loc)
+		nextDay := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		if !s.isWorkday(t.Weekday()) || !t.Before(dayEnd) {
+			t = nextDay
+			continue
+		}
+		if t.Before(dayStart) {
+			t = dayStart
+		}
+		available := dayEnd.Sub(t)
+		if remaining <= available {
+			return t.Add(remaining)
+		}
+		remaining -= available
+		t = nextDay
+	}
+	return t
+}
+
+func (s *DatabaseStore) getLeadSettings() (LeadSettings, error) {
+	var (
+		settings LeadSettings
+		days     pq.Int64Array
+	)
+	err := s.db.QueryRow(`
+		SELECT assignment_mode, first_response_hours, workday_start, workday_end, work_days, timezone
+		FROM lead_settings
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&settings.AssignmentMode, &settings.FirstResponseHours, &settings.WorkdayStart,
+		&settings.WorkdayEnd, &days, &settings.Timezone)
+	if err == sql.ErrNoRows {
+		return defaultLeadSettings, nil
+	}
+	for _, d := range days {
+		settings.WorkDays = append(settings.WorkDays, int(d))
+	}
+	return settings, err
+}
+
+func (s *DatabaseStore) updateLeadSettings(settings LeadSettings) error {
+	days := make(pq.Int64Array, 0, len(settings.WorkDays))
+	for _, d := range settings.WorkDays {
+		days = append(days, int64(d))
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO lead_settings (assignment_mode, first_response_hours, workday_start, workday_end, work_days, timezone)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, settings.AssignmentMode, settings.FirstResponseHours, settings.WorkdayStart, settings.WorkdayEnd, days, settings.Timezone)
+	return err
+}
+
+// pickManagerTx выбирает менеджера для новой заявки среди активных и не
+// отмеченных как отсутствующие. nil — назначить некого.
+func pickManagerTx(tx *sql.Tx, mode AssignmentMode) (*int64, error) {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", leadAssignmentLock); err != nil {
+		return nil, err
+	}
+
+	order := "m.last_assigned_at NULLS FIRST, m.id"
+	if mode == AssignByLoad {
+		order = `(SELECT COUNT(*) FROM leads l
+		          WHERE l.manager_id = m.id AND l.status NOT IN ('installed', 'closed', 'lost')),
+		         ` + order
+	}
+	var id int64
+	err := tx.QueryRow(`
+		SELECT m.id FROM managers m
+		WHERE m.is_active AND NOT m.off_duty
+		ORDER BY ` + order + `
+		LIMIT 1
+	`).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE managers SET last_assigned_at = NOW() WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// Managers
+const managerColumns = `m.id, m.name, m.phone, m.email, m.telegram_chat_id, m.is_active, m.off_duty, m.last_assigned_at,
+	(SELECT COUNT(*) FROM leads l WHERE l.manager_id = m.id AND l.status NOT IN ('installed', 'closed', 'lost'))`
+
+func scanManager(row interface{ Scan(...any) error }) (Manager, error) {
+	var m Manager
+	err := row.Scan(&m.ID, &m.Name, &m.Phone, &m.Email, &m.TelegramChatID, &m.IsActive, &m.OffDuty,
+		&m.LastAssignedAt, &m.OpenLeads)
+	return m, err
+}
+
+func (s *DatabaseStore) getManagers() ([]Manager, error) {
+	rows, err := s.db.Query("SELECT " + managerColumns + " FROM managers m ORDER BY m.name, m.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	managers := []Manager{}
+	for rows.Next() {
+		m, err := scanManager(rows)
+		if err != nil {
+			return nil, err
+		}
+		managers = append(managers, m)
+	}
+	return managers, rows.Err()
+}
+
+func (s *DatabaseStore) findManager(id int64) (*Manager, error) {
+	m, err := scanManager(s.db.QueryRow("SELECT "+managerColumns+" FROM managers m WHERE m.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *DatabaseStore) addManager(m Manager) (Manager, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO managers (name, phone, email, telegram_chat_id, is_active, off_duty)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, m.Name, m.Phone, m.Email, m.TelegramChatID, m.IsActive, m.OffDuty).Scan(&m.ID)
+	return m, err
+}
+
+func (s *DatabaseStore) updateManager(m Manager) (*Manager, error) {
+	res, err := s.db.Exec(`
+		UPDATE managers
+		SET name = $2, phone = $3, email = $4, telegram_chat_id = $5, is_active = $6, off_duty = $7, updated_at = NOW()
+		WHERE id = $1
+	`, m.ID, m.Name, m.Phone, m.Email, m.TelegramChatID, m.IsActive, m.OffDuty)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return s.findManager(m.ID)
+}
+
+func (s *DatabaseStore) deleteManager(id int64) error {
+	_, err := s.db.Exec("DELETE FROM managers WHERE id = $1", id)
+	return err
+}
+
+// assignLead назначает заявке менеджера вручную; managerID nil снимает
+// назначение. Возвращает nil, если заявки нет.
+func (s *DatabaseStore) assignLead(leadID int64, managerID *int64) (*Lead, error) {
+	lead, err := scanLead(s.db.QueryRow(`
+		UPDATE leads
+		SET manager_id = $2, assigned_at = CASE WHEN $2::BIGINT IS NULL THEN NULL ELSE NOW() END, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+leadColumns, leadID, managerID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lead, nil
+}
+
+// escalateOverdueLeads отмечает заявки без первого ответа после срока
+// и возвращает их. Каждая заявка эскалируется один раз.
+func (s *DatabaseStore) escalateOverdueLeads() ([]Lead, error) {
+	rows, err := s.db.Query(`
+		UPDATE leads SET escalated_at = NOW()
+		WHERE first_response_at IS NULL AND escalated_at IS NULL AND sla_due_at < NOW()
+		RETURNING ` + leadColumns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leads := []Lead{}
+	for rows.Next() {
+		l, err := scanLead(rows)
+		if err != nil {
+			return nil, err
+		}
+		leads = append(leads, l)
+	}
+	return leads, rows.Err()
+}
+
+// notifyManager отправляет заявку в личный чат назначенного менеджера
+func (a *App) notifyManager(managerID *int64, text string) error {
+	if managerID == nil || a.Config.TelegramBotToken == "" {
+		return nil
+	}
+	manager, err := a.Storage.findManager(*managerID)
+	if err != nil || manager == nil || manager.TelegramChatID == nil {
+		return err
+	}
+	return sendTelegramMessage(a.Config.TelegramBotToken, *manager.TelegramChatID, text)
+}
+
+// runLeadEscalation периодически ищет заявки с просроченным первым ответом
+// и сообщает о них подписчикам бота и назначенному менеджеру. Заодно
+// чистит старые отправки форм, чтобы не делать этого в запросе
+func (a *App) runLeadEscalation(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := a.Storage.purgeFormSubmissions(); err != nil {
+			log.Printf("form submissions purge: %v", err)
+		}
+		leads, err := a.Storage.escalateOverdueLeads()
+		if err != nil {
+			log.Printf("lead escalation: %v", err)
+			continue
+		}
+		for _, lead := range leads {
+			text := "Нет ответа по заявке в срок\n" + leadMessage(lead)
+			if err := a.notifyTelegram(text); err != nil && !errors.Is(err, errTelegramDisabled) {
+				log.Printf("lead %d: escalation notification failed: %v", lead.ID, err)
+			}
+			if err := a.notifyManager(lead.ManagerID, text); err != nil {
+				log.Printf("lead %d: manager notification failed: %v", lead.ID, err)
+			}
+		}
+	}
+}
+
+// Handlers
+func (a *App) handleGetManagers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		managers, err := a.Storage.getManagers()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, managers)
+	}
+}
+
+func (a *App) handleCreateManager() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := Manager{IsActive: true}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(m); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		created, err := a.Storage.addManager(m)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func (a *App) handleUpdateManager() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid manager ID"})
+			return
+		}
+
+		var m Manager
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(m); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		m.ID = id
+		updated, err := a.Storage.updateManager(m)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if updated == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "manager not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func (a *App) handleDeleteManager() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid manager ID"})
+			return
+		}
+
+		if err := a.Storage.deleteManager(id); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+func (a *App) handleGetLeadSettings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings, err := a.Storage.getLeadSettings()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+	}
+}
+
+// handleUpdateLeadSettings сохраняет настройки. Новый срок действует для
+// заявок, пришедших после изменения.
+func (a *App) handleUpdateLeadSettings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var settings LeadSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(settings); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid timezone"})
+			return
+		}
+
+		if err := a.Storage.updateLeadSettings(settings); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+	}
+}
+
+func (a *App) handleAssignLead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid lead ID"})
+			return
+		}
+
+		var in struct {
+			ManagerID *int64 `json:"managerId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if in.ManagerID != nil {
+			manager, err := a.Storage.findManager(*in.ManagerID)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+				return
+			}
+			if manager == nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": errManagerNotFound.Error()})
+				return
+			}
+		}
+
+		lead, err := a.Storage.assignLead(id, in.ManagerID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if lead == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "lead not found"})
+			return
+		}
+		if err := a.notifyManager(lead.ManagerID, fmt.Sprintf("Вам передана заявка\n%s", leadMessage(*lead))); err != nil {
+			log.Printf("lead %d: manager notification failed: %v", lead.ID, err)
+		}
+		writeJSON(w, http.StatusOK, lead.withDerived(time.Now()))
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// LeadAttribution — откуда пришел посетитель. Фронтенд запоминает метки
+// первого визита и передает их вместе с заявкой.
+// Слишком длинные значения обрезаются, а не отклоняются: из-за меток
+// заявка не должна теряться.
+type LeadAttribution struct {
+	UTMSource   string `json:"utmSource,omitempty"`
+	UTMMedium   string `json:"utmMedium,omitempty"`
+	UTMCampaign string `json:"utmCampaign,omitempty"`
+	UTMContent  string `json:"utmContent,omitempty"`
+	UTMTerm     string `json:"utmTerm,omitempty"`
+	Referrer    string `json:"referrer,omitempty"`
+	LandingPage string `json:"landingPage,omitempty"`
+	// FirstVisitAt — время первого визита; ClientID — идентификатор посетителя
+	// в счетчике (например, Яндекс Метрики)
+	FirstVisitAt *time.Time `json:"firstVisitAt,omitempty"`
+	ClientID     string     `json:"clientId,omitempty"`
+}
+
+func (at LeadAttribution) trimmed() LeadAttribution {
+	limits := []struct {
+		field *string
+		max   int
+	}{
+		{&at.UTMSource, 200}, {&at.UTMMedium, 200}, {&at.UTMCampaign, 200}, {&at.UTMContent, 200},
+		{&at.UTMTerm, 200}, {&at.Referrer, 500}, {&at.LandingPage, 500}, {&at.ClientID, 100},
+	}
+	for _, l := range limits {
+		v := strings.TrimSpace(*l.field)
+		if runes := []rune(v); len(runes) > l.max {
+			v = string(runes[:l.max])
+		}
+		*l.field = v
+	}
+	return at
+}
+
+// channel — источник для отчетов: utm_source, иначе домен внешнего
+// referrer, иначе direct. Переходы внутри сайта считаются прямыми.
+func (at LeadAttribution) channel() string {
+	if at.UTMSource != "" {
+		return strings.ToLower(at.UTMSource)
+	}
+	ref := hostOf(at.Referrer)
+	if ref == "" || ref == hostOf(at.LandingPage) {
+		return "direct"
+	}
+	return ref
+}
+
+func hostOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// AttributionRow — строка отчета по источнику (и кампании)
+type AttributionRow struct {
+	Channel  string  `json:"channel"`
+	Campaign string  `json:"campaign,omitempty"`
+	Leads    int     `json:"leads"`
+	Orders   int     `json:"orders"`
+	Revenue  float64 `json:"revenue"`
+	// Converted — заявки, по которым есть хотя бы один заказ
+	Converted int `json:"converted"`
+	// Conversion — процент заявок с заказом
+	Conversion float64 `json:"conversion"`
+}
+
+type AttributionReport struct {
+	From  time.Time        `json:"from"`
+	To    time.Time        `json:"to"`
+	Rows  []AttributionRow `json:"rows"`
+	Total AttributionRow   `json:"total"`
+}
+
+func (r AttributionRow) withConversion() AttributionRow {
+	if r.Leads > 0 {
+		r.Conversion = roundTo(float64(r.Converted)*100/float64(r.Leads), 1)
+	}
+	return r
+}
+
+// getAttributionReport считает заявки, заказы и выручку по каналам за период
+// создания заявки. Заказы и выручка — по заказам этих заявок, кроме
+// отмененных. byCampaign добавляет разбивку по utm_campaign.
+func (s *DatabaseStore) getAttributionReport(from, to time.Time, byCampaign bool) ([]AttributionRow, error) {
+	campaign := "''"
+	if byCampaign {
+		campaign = "l.utm_campaign"
+	}
+	rows, err := s.db.Query(`
+		SELECT l.channel, `+campaign+` AS campaign,
+		       COUNT(*),
+		       COALESCE(SUM(o.orders), 0),
+		       COALESCE(SUM(o.revenue), 0),
+		       COUNT(*) FILTER (WHERE o.orders > 0)
+		FROM leads l
+		LEFT JOIN (
+		    SELECT lead_id, COUNT(*) AS orders, SUM(total) AS revenue
+		    FROM orders
+		    WHERE production_status <> 'cancelled'
+		    GROUP BY lead_id
+		) o ON o.lead_id = l.id
+		WHERE l.created_at >= $1 AND l.created_at < $2
+		GROUP BY 1, 2
+		ORDER BY 3 DESC, 1, 2
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []AttributionRow{}
+	for rows.Next() {
+		var r AttributionRow
+		if err := rows.Scan(&r.Channel, &r.Campaign, &r.Leads, &r.Orders, &r.Revenue, &r.Converted); err != nil {
+			return nil, err
+		}
+		r.Revenue = roundTo(r.Revenue, 2)
+		report = append(report, r.withConversion())
+	}
+	return report, rows.Err()
+}
+
+// Handlers
+
+// handleAttributionReport — GET /api/leads/attribution?from=&to=&groupBy=source|campaign.
+// Период по умолчанию — последние 30 дней, to включительно.
+func (a *App) handleAttributionReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		to := time.Now()
+		if v := q.Get("to"); v != "" {
+			d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid to date"})
+				return
+			}
+			to = d.AddDate(0, 0, 1)
+		}
+		from := to.AddDate(0, 0, -30)
+		if v := q.Get("from"); v != "" {
+			d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid from date"})
+				return
+			}
+			from = d
+		}
+		if !from.Before(to) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from must be before to"})
+			return
+		}
+
+		var byCampaign bool
+		switch q.Get("groupBy") {
+		case "", "campaign":
+			byCampaign = true
+		case "source":
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid groupBy"})
+			return
+		}
+
+		rows, err := a.Storage.getAttributionReport(from, to, byCampaign)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		report := AttributionReport{From: from, To: to, Rows: rows, Total: AttributionRow{Channel: "total"}}
+		for _, row := range rows {
+			report.Total.Leads += row.Leads
+			report.Total.Orders += row.Orders
+			report.Total.Revenue += row.Revenue
+			report.Total.Converted += row.Converted
+		}
+		report.Total.Revenue = roundTo(report.Total.Revenue, 2)
+		report.Total = report.Total.withConversion()
+		writeJSON(w, http.StatusOK, report)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// LeadStatus — этап работы с заявкой
+type LeadStatus string
+
+const (
+	LeadStatusNew                  LeadStatus = "new"
+	LeadStatusContacted            LeadStatus = "contacted"
+	LeadStatusMeasurementScheduled LeadStatus = "measurement_scheduled"
+	LeadStatusMeasured             LeadStatus = "measured"
+	LeadStatusQuoted               LeadStatus = "quoted"
+	LeadStatusOrdered              LeadStatus = "ordered"
+	LeadStatusInstalled            LeadStatus = "installed"
+	LeadStatusClosed               LeadStatus = "closed"
+	LeadStatusLost                 LeadStatus = "lost"
+)
+
+// leadTransitions — разрешенные переходы. Замер можно перенести (вернуться
+// к contacted) или повторить после расчета; потерянную заявку — вернуть
+// в работу. Закрытая заявка окончательна.
+var leadTransitions = map[LeadStatus][]LeadStatus{
+	LeadStatusNew:                  {LeadStatusContacted, LeadStatusMeasurementScheduled, LeadStatusLost},
+	LeadStatusContacted:            {LeadStatusMeasurementScheduled, LeadStatusQuoted, LeadStatusLost},
+	LeadStatusMeasurementScheduled: {LeadStatusMeasured, LeadStatusContacted, LeadStatusLost},
+	LeadStatusMeasured:             {LeadStatusQuoted, LeadStatusLost},
+	LeadStatusQuoted:               {LeadStatusOrdered, LeadStatusMeasurementScheduled, LeadStatusLost},
+	LeadStatusOrdered:              {LeadStatusInstalled, LeadStatusLost},
+	LeadStatusInstalled:            {LeadStatusClosed},
+	LeadStatusClosed:               {},
+	LeadStatusLost:                 {LeadStatusContacted},
+}
+
+var (
+	errInvalidTransition = errors.New("status transition is not allowed")
+	errLostReason        = errors.New("reason is required for lost leads")
+)
+
+func (s LeadStatus) valid() bool {
+	_, ok := leadTransitions[s]
+	return ok
+}
+
+func canTransition(from, to LeadStatus) bool {
+	for _, next := range leadTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// LeadTransition — запись истории статусов. From пуст у записи о создании.
+type LeadTransition struct {
+	ID        int64      `json:"id"`
+	LeadID    int64      `json:"leadId"`
+	From      LeadStatus `json:"from,omitempty"`
+	To        LeadStatus `json:"to"`
+	Actor     string     `json:"actor"`
+	Comment   string     `json:"comment,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type LeadStatusRequest struct {
+	Status  LeadStatus `json:"status" validate:"required"`
+	Actor   string     `json:"actor" validate:"max=100"`
+	Comment string     `json:"comment" validate:"max=2000"`
+	// Reason обязателен для перехода в lost
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type LeadTimelineResponse struct {
+	Lead     Lead             `json:"lead"`
+	Timeline []LeadTransition `json:"timeline"`
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func recordLeadTransition(q execer, t LeadTransition) error {
+	var from any
+	if t.From != "" {
+		from = t.From
+	}
+	_, err := q.Exec(`
+		INSERT INTO lead_status_history (lead_id, from_status, to_status, actor, comment, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, t.LeadID, from, t.To, t.Actor, t.Comment, t.Reason)
+	return err
+}
+
+// transitionLeadTx переводит заявку в новый статус и пишет историю.
+// Возвращает nil, если заявки нет, и errInvalidTransition, если переход
+// из текущего статуса не разрешен.
+func transitionLeadTx(tx *sql.Tx, t LeadTransition) (*Lead, error) {
+	var current LeadStatus
+	err := tx.QueryRow("SELECT status FROM leads WHERE id = $1 FOR UPDATE", t.LeadID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !canTransition(current, t.To) {
+		return nil, fmt.Errorf("%w: %s → %s", errInvalidTransition, current, t.To)
+	}
+	if t.To == LeadStatusLost && strings.TrimSpace(t.Reason) == "" {
+		return nil, errLostReason
+	}
+
+	// Причина потери хранится, пока заявка не вернется в работу
+	lostReason := ""
+	if t.To == LeadStatusLost {
+		lostReason = t.Reason
+	}
+	lead, err := scanLead(tx.QueryRow(`
+		UPDATE leads
+		SET status = $2, lost_reason = $3, first_response_at = COALESCE(first_response_at, NOW()), updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+leadColumns, t.LeadID, t.To, lostReason))
+	if err != nil {
+		return nil, err
+	}
+
+	t.From = current
+	if err := recordLeadTransition(tx, t); err != nil {
+		return nil, err
+	}
+	return &lead, nil
+}
+
+func (s *DatabaseStore) changeLeadStatus(t LeadTransition) (*Lead, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	lead, err := transitionLeadTx(tx, t)
+	if err != nil || lead == nil {
+		return lead, err
+	}
+	return lead, tx.Commit()
+}
+
+func (s *DatabaseStore) getLeadTimeline(leadID int64) ([]LeadTransition, error) {
+	rows, err := s.db.Query(`
+		SELECT id, lead_id, COALESCE(from_status, ''), to_status, actor, comment, reason, created_at
+		FROM lead_status_history
+		WHERE lead_id = $1
+		ORDER BY created_at, id
+	`, leadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timeline := []LeadTransition{}
+	for rows.Next() {
+		var t LeadTransition
+		if err := rows.Scan(&t.ID, &t.LeadID, &t.From, &t.To, &t.Actor, &t.Comment, &t.Reason, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		timeline = append(timeline, t)
+	}
+	return timeline, rows.Err()
+}
+
+// Handlers
+func (a *App) handleChangeLeadStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid lead ID"})
+			return
+		}
+
+		var in LeadStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil || !in.Status.valid() {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		actor := strings.TrimSpace(in.Actor)
+		if actor == "" {
+			actor = "admin"
+		}
+
+		lead, err := a.Storage.changeLeadStatus(LeadTransition{
+			LeadID:  id,
+			To:      in.Status,
+			Actor:   actor,
+			Comment: strings.TrimSpace(in.Comment),
+			Reason:  strings.TrimSpace(in.Reason),
+		})
+		switch {
+		case errors.Is(err, errInvalidTransition):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		case errors.Is(err, errLostReason):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		case lead == nil:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "lead not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, lead.withDerived(time.Now()))
+	}
+}
+
+func (a *App) handleLeadTimeline() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid lead ID"})
+			return
+		}
+
+		lead, err := a.Storage.findLead(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if lead == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "lead not found"})
+			return
+		}
+		timeline, err := a.Storage.getLeadTimeline(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, LeadTimelineResponse{Lead: lead.withDerived(time.Now()), Timeline: timeline})
+	}
+}
@@ -0,0 +1,700 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// LeadKind — форма, из которой пришла заявка
+type LeadKind string
+
+const (
+	LeadKindRequest LeadKind = "request"
+	LeadKindMeasure LeadKind = "measure"
+	LeadKindRepair  LeadKind = "repair"
+	LeadKindQuote   LeadKind = "quote"
+)
+
+var leadKindTitles = map[LeadKind]string{
+	LeadKindRequest: "Заявка",
+	LeadKindMeasure: "Вызов замерщика",
+	LeadKindRepair:  "Заявка на ремонт",
+	LeadKindQuote:   "Заявка по расчету",
+}
+
+var (
+	errInvalidPhone  = errors.New("invalid phone")
+	errQuoteNotFound = errors.New("quote not found")
+)
+
+// LeadRequest — заявка с сайта. Фронтенд присылает комментарий в поле
+// comment, старые формы — в message.
+type LeadRequest struct {
+	Kind        LeadKind         `json:"kind" validate:"omitempty,oneof=request measure repair quote"`
+	Name        string           `json:"name" validate:"required,max=200"`
+	Phone       string           `json:"phone" validate:"required,max=30"`
+	Email       string           `json:"email" validate:"omitempty,email,max=200"`
+	Address     *CustomerAddress `json:"address,omitempty"`
+	ProductType ProductType      `json:"productType" validate:"omitempty,oneof=horizontal vertical roller"`
+	WidthMm     int              `json:"widthMm" validate:"omitempty,gt=0,lte=4000"`
+	HeightMm    int              `json:"heightMm" validate:"omitempty,gt=0,lte=3000"`
+	MaterialID  int64            `json:"materialId" validate:"omitempty,gt=0"`
+	Category    string           `json:"category" validate:"max=100"`
+	QuoteToken  string           `json:"quoteToken" validate:"max=64"`
+	Message     string           `json:"message" validate:"max=2000"`
+	Comment     string           `json:"comment" validate:"max=2000"`
+	PageURL     string           `json:"pageUrl" validate:"max=500"`
+	Source      string           `json:"source" validate:"max=50"`
+	Attribution *LeadAttribution `json:"attribution,omitempty"`
+	// Repair — изделие и неисправности из формы ремонта. Стоимость сервер
+	// считает сам по каталогу услуг, присланная формой не используется
+	Repair *LeadRepairRequest `json:"repair,omitempty"`
+	// Website — скрытое поле-ловушка, люди его не заполняют
+	Website string `json:"website,omitempty"`
+	// FormToken — токен из GET /api/forms/token
+	FormToken string `json:"formToken,omitempty"`
+}
+
+// LeadRepairRequest — данные формы ремонта в заявке
+type LeadRepairRequest struct {
+	RepairEstimateRequest
+	EstimatedPrice float64 `json:"estimatedPrice,omitempty"`
+}
+
+type Lead struct {
+	ID          int64       `json:"id"`
+	Kind        LeadKind    `json:"kind"`
+	Name        string      `json:"name"`
+	Phone       string      `json:"phone"`
+	Email       string      `json:"email,omitempty"`
+	Address     string      `json:"address,omitempty"`
+	Zone        string      `json:"zone,omitempty"`
+	OutOfArea   bool        `json:"outOfArea"`
+	ProductType ProductType `json:"productType,omitempty"`
+	WidthMm     *int        `json:"widthMm,omitempty"`
+	HeightMm    *int        `json:"heightMm,omitempty"`
+	MaterialID  *int64      `json:"materialId,omitempty"`
+	Category    string      `json:"category,omitempty"`
+	QuoteToken  string      `json:"quoteToken,omitempty"`
+	Message     string      `json:"message,omitempty"`
+	PageURL     string      `json:"pageUrl,omitempty"`
+	Source      string      `json:"source"`
+	// Channel — источник для отчетов, см. LeadAttribution.channel
+	Channel     string          `json:"channel"`
+	Attribution LeadAttribution `json:"attribution"`
+	// TotalPrice — ориентировочная стоимость, посчитанная сервером по
+	// материалу и размерам или по неисправностям, либо взятая из сохраненного расчета
+	TotalPrice *float64 `json:"totalPrice,omitempty"`
+	// Repair — расчет ремонта по неисправностям из формы
+	Repair     *RepairEstimateResponse `json:"repair,omitempty"`
+	Status     LeadStatus              `json:"status"`
+	LostReason string                  `json:"lostReason,omitempty"`
+	CustomerID *int64                  `json:"customerId,omitempty"`
+	ManagerID  *int64                  `json:"managerId,omitempty"`
+	AssignedAt *time.Time              `json:"assignedAt,omitempty"`
+	// FirstResponseAt — первая смена статуса; SLADueAt — срок для нее
+	FirstResponseAt *time.Time `json:"firstResponseAt,omitempty"`
+	SLADueAt        *time.Time `json:"slaDueAt,omitempty"`
+	EscalatedAt     *time.Time `json:"escalatedAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	// NextStatuses — куда заявку можно перевести из текущего статуса
+	NextStatuses []LeadStatus `json:"nextStatuses"`
+	// Overdue — срок первого ответа прошел, а ответа не было
+	Overdue bool `json:"overdue"`
+}
+
+// withDerived заполняет вычисляемые поля, которые не хранятся в базе.
+func (l Lead) withDerived(now time.Time) Lead {
+	l.NextStatuses = append([]LeadStatus{}, leadTransitions[l.Status]...)
+	l.Overdue = l.FirstResponseAt == nil && l.SLADueAt != nil && l.SLADueAt.Before(now)
+	return l
+}
+
+// LeadFilter — отбор заявок в админке; пустые поля не ограничивают
+type LeadFilter struct {
+	Statuses []string
+	Kind     string
+	Phone    string
+	From, To *time.Time
+	// Overdue — только заявки с просроченным первым ответом
+	Overdue   bool
+	ManagerID *int64
+	Channel   string
+	Limit     int
+	Offset    int
+}
+
+// normalizePhone приводит российский номер к виду +7XXXXXXXXXX.
+// Понимает 8 и +7 в начале, скобки, пробелы и дефисы.
+func normalizePhone(raw string) (string, error) {
+	var digits []byte
+	for _, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = append(digits, byte(r))
+		case strings.ContainsRune(" +-().", r):
+		default:
+			return "", errInvalidPhone
+		}
+	}
+	switch {
+	case len(digits) == 11 && (digits[0] == '7' || digits[0] == '8'):
+		digits = digits[1:]
+	case len(digits) == 10:
+	default:
+		return "", errInvalidPhone
+	}
+	// Коды российских номеров начинаются с 3, 4, 8 или 9
+	if !strings.ContainsRune("3489", rune(digits[0])) {
+		return "", errInvalidPhone
+	}
+	return "+7" + string(digits), nil
+}
+
+// formatPhone — номер для чтения: +7 (812) 123-45-67
+func formatPhone(phone string) string {
+	if len(phone) != 12 || !strings.HasPrefix(phone, "+7") {
+		return phone
+	}
+	d := phone[2:]
+	return fmt.Sprintf("+7 (%s) %s-%s-%s", d[:3], d[3:6], d[6:8], d[8:])
+}
+
+// buildLead проверяет заявку и дополняет ее данными сервера: зоной выезда
+// по адресу, итогом сохраненного расчета, расчетом ремонта или оценкой по
+// материалу и размерам
+func (a *App) buildLead(req LeadRequest) (Lead, error) {
+	phone, err := normalizePhone(req.Phone)
+	if err != nil {
+		return Lead{}, err
+	}
+	lead := Lead{
+		Kind:        req.Kind,
+		Name:        strings.TrimSpace(req.Name),
+		Phone:       phone,
+		Email:       strings.TrimSpace(req.Email),
+		ProductType: req.ProductType,
+		Category:    strings.TrimSpace(req.Category),
+		QuoteToken:  req.QuoteToken,
+		PageURL:     req.PageURL,
+		Source:      strings.TrimSpace(req.Source),
+		Status:      LeadStatusNew,
+	}
+	var messages []string
+	for _, m := range []string{req.Message, req.Comment} {
+		if m = strings.TrimSpace(m); m != "" {
+			messages = append(messages, m)
+		}
+	}
+	lead.Message = strings.Join(messages, "\n")
+	if lead.Source == "" {
+		lead.Source = "website"
+	}
+	if req.Attribution != nil {
+		lead.Attribution = req.Attribution.trimmed()
+	}
+	lead.Channel = lead.Attribution.channel()
+	if req.WidthMm > 0 && req.HeightMm > 0 {
+		lead.WidthMm, lead.HeightMm = &req.WidthMm, &req.HeightMm
+	}
+
+	zone, err := a.resolveAddress(req.Address)
+	if err != nil {
+		return Lead{}, err
+	}
+	if req.Address != nil {
+		lead.Address = strings.TrimSpace(req.Address.Address)
+	}
+	var surcharges float64
+	if zone.Zone != nil {
+		lead.Zone, lead.OutOfArea = zone.Zone.Name, zone.OutOfArea
+		for _, s := range zone.Surcharges {
+			surcharges += s.Amount
+		}
+	}
+
+	if req.QuoteToken != "" {
+		quote, err := a.Storage.findQuoteByToken(req.QuoteToken)
+		if err != nil {
+			return Lead{}, err
+		}
+		if quote == nil {
+			return Lead{}, errQuoteNotFound
+		}
+		total := quote.Total
+		lead.TotalPrice = &total
+		if lead.Zone == "" {
+			lead.Zone, lead.OutOfArea = quote.Zone, quote.OutOfArea
+		}
+		if lead.Kind == "" {
+			lead.Kind = LeadKindQuote
+		}
+	}
+
+	if req.Repair != nil {
+		services, err := a.Storage.getRepairServices(true)
+		if err != nil {
+			return Lead{}, err
+		}
+		estimate, err := priceRepair(services, req.Repair.RepairEstimateRequest)
+		if err != nil {
+			return Lead{}, err
+		}
+		lead.Repair = &estimate
+		if lead.ProductType == "" {
+			lead.ProductType = estimate.ProductType
+		}
+		if lead.TotalPrice == nil {
+			total := estimate.Price
+			lead.TotalPrice = &total
+		}
+		if lead.Kind == "" {
+			lead.Kind = LeadKindRepair
+		}
+	}
+
+	if req.MaterialID > 0 {
+		material, err := a.Storage.findMaterial(req.MaterialID)
+		if err != nil {
+			return Lead{}, err
+		}
+		if material == nil {
+			return Lead{}, fmt.Errorf("%w: %d", errMaterialNotFound, req.MaterialID)
+		}
+		lead.MaterialID = &material.ID
+		if lead.Category == "" {
+			lead.Category = material.Category
+		}
+		if lead.TotalPrice == nil && lead.WidthMm != nil {
+			config, err := a.Storage.getPricingConfig()
+			if err != nil {
+				return Lead{}, err
+			}
+			total := roundTo(priceWindow(*material, config, req.WidthMm, req.HeightMm).Price+surcharges, 2)
+			lead.TotalPrice = &total
+		}
+	}
+
+	if lead.Kind == "" {
+		lead.Kind = LeadKindRequest
+	}
+	return lead, nil
+}
+
+// leadMessage — уведомление о новой заявке для Telegram
+func leadMessage(lead Lead) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s №%d\n", leadKindTitles[lead.Kind], lead.ID)
+	fmt.Fprintf(&b, "Имя: %s\nТелефон: %s\n", lead.Name, formatPhone(lead.Phone))
+	if lead.Email != "" {
+		fmt.Fprintf(&b, "Email: %s\n", lead.Email)
+	}
+	if lead.Address != "" {
+		fmt.Fprintf(&b, "Адрес: %s", lead.Address)
+		if lead.Zone != "" {
+			fmt.Fprintf(&b, " (%s)", lead.Zone)
+		}
+		if lead.OutOfArea {
+			b.WriteString(", вне зоны обслуживания")
+		}
+		b.WriteString("\n")
+	}
+	if lead.WidthMm != nil && lead.HeightMm != nil {
+		fmt.Fprintf(&b, "Размер: %d×%d мм\n", *lead.WidthMm, *lead.HeightMm)
+	}
+	if lead.Category != "" {
+		fmt.Fprintf(&b, "Категория: %s\n", lead.Category)
+	}
+	if lead.Repair != nil {
+		fmt.Fprintf(&b, "Ремонт: %s\n", productTypeTitle(lead.Repair.ProductType))
+		for _, line := range lead.Repair.Lines {
+			fmt.Fprintf(&b, "- %s × %d: %.0f ₽\n", line.Name, line.Quantity, line.Price)
+		}
+	}
+	if lead.TotalPrice != nil {
+		fmt.Fprintf(&b, "Стоимость: %.0f ₽\n", *lead.TotalPrice)
+	}
+	if lead.Message != "" {
+		fmt.Fprintf(&b, "Комментарий: %s\n", lead.Message)
+	}
+	if lead.PageURL != "" {
+		fmt.Fprintf(&b, "Страница: %s\n", lead.PageURL)
+	}
+	if lead.Channel != "" && lead.Channel != "direct" {
+		fmt.Fprintf(&b, "Источник: %s", lead.Channel)
+		if lead.Attribution.UTMCampaign != "" {
+			fmt.Fprintf(&b, " / %s", lead.Attribution.UTMCampaign)
+		}
+		b.WriteString("\n")
+	}
+	if lead.SLADueAt != nil {
+		fmt.Fprintf(&b, "Ответить до: %s\n", lead.SLADueAt.In(time.Local).Format("02.01.2006 15:04"))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// notifyLead отправляет уведомление в фоне, чтобы не задерживать ответ
+// посетителю. Если бот не настроен, заявка просто сохраняется.
+func (a *App) notifyLead(lead Lead) {
+	text := leadMessage(lead)
+	go func() {
+		if err := a.notifyTelegram(text); err != nil && !errors.Is(err, errTelegramDisabled) {
+			log.Printf("lead %d: telegram notification failed: %v", lead.ID, err)
+		}
+		if err := a.notifyManager(lead.ManagerID, text); err != nil {
+			log.Printf("lead %d: manager notification failed: %v", lead.ID, err)
+		}
+	}()
+}
+
+// Leads
+const leadColumns = `id, kind, name, phone, email, address, zone, out_of_area, product_type, width_mm, height_mm,
+	material_id, category, quote_token, message, page_url, source, total_price, repair, status, lost_reason,
+	channel, utm_source, utm_medium, utm_campaign, utm_content, utm_term, referrer, landing_page, first_visit_at, client_id,
+	customer_id, manager_id, assigned_at, first_response_at, sla_due_at, escalated_at, created_at, updated_at`
+
+func scanLead(row interface{ Scan(...any) error }) (Lead, error) {
+	var (
+		l      Lead
+		repair []byte
+	)
+	err := row.Scan(&l.ID, &l.Kind, &l.Name, &l.Phone, &l.Email, &l.Address, &l.Zone, &l.OutOfArea,
+		&l.ProductType, &l.WidthMm, &l.HeightMm, &l.MaterialID, &l.Category, &l.QuoteToken, &l.Message,
+		&l.PageURL, &l.Source, &l.TotalPrice, &repair, &l.Status, &l.LostReason,
+		&l.Channel, &l.Attribution.UTMSource, &l.Attribution.UTMMedium, &l.Attribution.UTMCampaign,
+		&l.Attribution.UTMContent, &l.Attribution.UTMTerm, &l.Attribution.Referrer, &l.Attribution.LandingPage,
+		&l.Attribution.FirstVisitAt, &l.Attribution.ClientID,
+		&l.CustomerID, &l.ManagerID, &l.AssignedAt, &l.FirstResponseAt, &l.SLADueAt, &l.EscalatedAt, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil || repair == nil {
+		return l, err
+	}
+	err = json.Unmarshal(repair, &l.Repair)
+	return l, err
+}
+
+// addLead сохраняет заявку, привязывает ее (и сохраненный расчет) к клиенту
+// по телефону, назначает менеджера и срок первого ответа по текущим
+// настройкам и пишет первую запись истории статусов
+func (s *DatabaseStore) addLead(l Lead) (Lead, error) {
+	settings, err := s.getLeadSettings()
+	if err != nil {
+		return l, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return l, err
+	}
+	defer tx.Rollback()
+
+	if l, err = addLeadTx(tx, settings, l); err != nil {
+		return l, err
+	}
+	return l, tx.Commit()
+}
+
+// addLeadTx — addLead внутри чужой транзакции, например вместе с записью
+// на замер
+func addLeadTx(tx *sql.Tx, settings LeadSettings, l Lead) (Lead, error) {
+	now := time.Now()
+	due := settings.addWorkingHours(now, settings.FirstResponseHours)
+	l.SLADueAt = &due
+	var err error
+	if l.ManagerID, err = pickManagerTx(tx, settings.AssignmentMode); err != nil {
+		return l, err
+	}
+	if l.ManagerID != nil {
+		l.AssignedAt = &now
+	}
+	customerID, err := upsertCustomerTx(tx, l.Phone, l.Name, l.Email, l.Address)
+	if err != nil {
+		return l, err
+	}
+	l.CustomerID = &customerID
+	var repair []byte
+	if l.Repair != nil {
+		if repair, err = json.Marshal(l.Repair); err != nil {
+			return l, err
+		}
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO leads (kind, name, phone, email, address, zone, out_of_area, product_type, width_mm, height_mm,
+		                   material_id, category, quote_token, message, page_url, source, total_price, status,
+		                   customer_id, manager_id, assigned_at, sla_due_at,
+		                   channel, utm_source, utm_medium, utm_campaign, utm_content, utm_term, referrer, landing_page,
+		                   first_visit_at, client_id, repair)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21,
+		        $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33)
+		RETURNING id, created_at, updated_at
+	`, l.Kind, l.Name, l.Phone, l.Email, l.Address, l.Zone, l.OutOfArea, l.ProductType, l.WidthMm, l.HeightMm,
+		l.MaterialID, l.Category, l.QuoteToken, l.Message, l.PageURL, l.Source, l.TotalPrice, l.Status,
+		l.CustomerID, l.ManagerID, l.AssignedAt, l.SLADueAt,
+		l.Channel, l.Attribution.UTMSource, l.Attribution.UTMMedium, l.Attribution.UTMCampaign, l.Attribution.UTMContent,
+		l.Attribution.UTMTerm, l.Attribution.Referrer, l.Attribution.LandingPage, l.Attribution.FirstVisitAt,
+		l.Attribution.ClientID, repair,
+	).Scan(&l.ID, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		return l, err
+	}
+	if err := recordLeadTransition(tx, LeadTransition{LeadID: l.ID, To: l.Status, Actor: l.Source}); err != nil {
+		return l, err
+	}
+	if l.QuoteToken != "" {
+		_, err := tx.Exec("UPDATE quotes SET customer_id = $1 WHERE token = $2 AND customer_id IS NULL", customerID, l.QuoteToken)
+		if err != nil {
+			return l, err
+		}
+	}
+	return l, nil
+}
+
+func (s *DatabaseStore) findLead(id int64) (*Lead, error) {
+	l, err := scanLead(s.db.QueryRow("SELECT "+leadColumns+" FROM leads WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (s *DatabaseStore) getLeads(f LeadFilter) ([]Lead, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if len(f.Statuses) > 0 {
+		add("status = ANY($%d)", pq.Array(f.Statuses))
+	}
+	if f.Kind != "" {
+		add("kind = $%d", f.Kind)
+	}
+	if f.Phone != "" {
+		add("phone = $%d", f.Phone)
+	}
+	if f.From != nil {
+		add("created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("created_at < $%d", *f.To)
+	}
+	if f.ManagerID != nil {
+		add("manager_id = $%d", *f.ManagerID)
+	}
+	if f.Channel != "" {
+		add("channel = $%d", f.Channel)
+	}
+	if f.Overdue {
+		where = append(where, "first_response_at IS NULL AND sla_due_at < NOW()")
+	}
+
+	query := "SELECT " + leadColumns + " FROM leads"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leads := []Lead{}
+	for rows.Next() {
+		l, err := scanLead(rows)
+		if err != nil {
+			return nil, err
+		}
+		leads = append(leads, l)
+	}
+	return leads, rows.Err()
+}
+
+// parseLeadFilter читает параметры списка заявок: status (несколько через
+// запятую), kind, phone, from и to (YYYY-MM-DD, to включительно), managerId,
+// channel, overdue=true, limit, offset
+func parseLeadFilter(q map[string][]string) (LeadFilter, error) {
+	get := func(key string) string {
+		if v := q[key]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	f := LeadFilter{Kind: get("kind"), Channel: strings.ToLower(get("channel")), Limit: 50}
+
+	for _, s := range strings.Split(get("status"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			if !LeadStatus(s).valid() {
+				return f, errors.New("invalid status")
+			}
+			f.Statuses = append(f.Statuses, s)
+		}
+	}
+	if v := get("phone"); v != "" {
+		phone, err := normalizePhone(v)
+		if err != nil {
+			return f, errors.New("invalid phone")
+		}
+		f.Phone = phone
+	}
+	if v := get("from"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("invalid from date")
+		}
+		f.From = &d
+	}
+	if v := get("to"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("invalid to date")
+		}
+		d = d.AddDate(0, 0, 1)
+		f.To = &d
+	}
+	if v := get("managerId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, errors.New("invalid managerId")
+		}
+		f.ManagerID = &id
+	}
+	if v := get("overdue"); v != "" {
+		overdue, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, errors.New("invalid overdue")
+		}
+		f.Overdue = overdue
+	}
+	if v := get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			return f, errors.New("invalid limit")
+		}
+		f.Limit = n
+	}
+	if v := get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, errors.New("invalid offset")
+		}
+		f.Offset = n
+	}
+	return f, nil
+}
+
+// Handlers
+func (a *App) handleCreateLead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in LeadRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil || strings.TrimSpace(in.Name) == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		lead, err := a.buildLead(in)
+		switch {
+		case errors.Is(err, errInvalidPhone):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid phone"})
+			return
+		case errors.Is(err, errQuoteNotFound), errors.Is(err, errMaterialNotFound),
+			errors.Is(err, errUnknownRepairService), errors.Is(err, errRepairNotAvailable):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+
+		sub := FormSubmission{
+			Form:      formLead,
+			IP:        a.clientIP(r),
+			Phone:     lead.Phone,
+			Text:      strings.Join([]string{in.Name, in.Email, lead.Message}, "\n"),
+			Honeypot:  in.Website,
+			FormToken: in.FormToken,
+		}
+		reasons, err := a.screenSubmission(sub)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if len(reasons) > 0 {
+			a.quarantineSubmission(w, sub, reasons, in)
+			return
+		}
+
+		created, err := a.Storage.addLead(lead)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		a.notifyLead(created)
+		writeJSON(w, http.StatusCreated, map[string]any{"id": created.ID, "status": created.Status})
+	}
+}
+
+func (a *App) handleListLeads() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLeadFilter(r.URL.Query())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		leads, err := a.Storage.getLeads(filter)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		now := time.Now()
+		for i := range leads {
+			leads[i] = leads[i].withDerived(now)
+		}
+		writeJSON(w, http.StatusOK, leads)
+	}
+}
+
+func (a *App) handleGetLead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid lead ID"})
+			return
+		}
+
+		lead, err := a.Storage.findLead(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if lead == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "lead not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, lead.withDerived(time.Now()))
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLeadMessageIncludesRepair(t *testing.T) {
+	services := []RepairService{
+		{Code: "call_out", Name: "Выезд мастера", Unit: "выезд", IsCallOut: true, Prices: map[ProductType]float64{ProductTypeVertical: 500}},
+		{Code: "slat", Name: "Замена ламели", Unit: "шт", Prices: map[ProductType]float64{ProductTypeVertical: 150}},
+	}
+	estimate, err := priceRepair(services, RepairEstimateRequest{
+		ProductType: ProductTypeVertical,
+		Defects:     []RepairDefect{{ServiceCode: "slat", Quantity: 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := estimate.Price
+	text := leadMessage(Lead{ID: 5, Kind: LeadKindRepair, Name: "Анна", Phone: "+79991234567", Repair: &estimate, TotalPrice: &total})
+
+	for _, want := range []string{
+		"Заявка на ремонт №5",
+		"Ремонт: Вертикальные жалюзи",
+		"- Выезд мастера × 1: 500 ₽",
+		"- Замена ламели × 3: 450 ₽",
+		"Стоимость: 950 ₽",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("message has no %q:\n%s", want, text)
+		}
+	}
+}
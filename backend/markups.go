@@ -0,0 +1,240 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CategoryMarkup — наценка категории: розница = закупка × (1 + Markup),
+// округленная до RoundTo. Markup 1.0 означает +100%.
+type CategoryMarkup struct {
+	Category  string    `json:"category"`
+	Markup    float64   `json:"markup" validate:"gte=0,lte=10"`
+	RoundTo   float64   `json:"roundTo" validate:"gt=0,lte=1000"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type MarginRow struct {
+	MaterialID     int64    `json:"materialId"`
+	SupplierCode   string   `json:"supplierCode"`
+	Name           string   `json:"name"`
+	Category       string   `json:"category"`
+	SupplierCost   *float64 `json:"supplierCost,omitempty"`
+	RetailPrice    float64  `json:"retailPrice"`
+	Margin         *float64 `json:"margin,omitempty"`
+	MarginPercent  *float64 `json:"marginPercent,omitempty"`
+	CategoryMarkup *float64 `json:"categoryMarkup,omitempty"`
+	PriceOverride  bool     `json:"priceOverride"`
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func retailFromCost(cost float64, markup CategoryMarkup) float64 {
+	price := cost * (1 + markup.Markup)
+	return roundTo(math.Round(price/markup.RoundTo)*markup.RoundTo, 2)
+}
+
+// deriveRetailPrice пересчитывает розничную цену материала из закупочной.
+// Цены, выставленные вручную, и материалы без закупочной цены или без
+// наценки для категории не трогаются.
+func deriveRetailPrice(q queryRower, material *Material) error {
+	if material.PriceOverride || material.SupplierCost == nil {
+		return nil
+	}
+	var markup CategoryMarkup
+	err := q.QueryRow(`SELECT markup, round_to FROM category_markups WHERE category = $1`, material.Category).
+		Scan(&markup.Markup, &markup.RoundTo)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	material.PricePerM2 = retailFromCost(*material.SupplierCost, markup)
+	return nil
+}
+
+func (s *DatabaseStore) getCategoryMarkups() ([]CategoryMarkup, error) {
+	rows, err := s.db.Query(`SELECT category, markup, round_to, updated_at FROM category_markups ORDER BY category`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	markups := []CategoryMarkup{}
+	for rows.Next() {
+		var m CategoryMarkup
+		if err := rows.Scan(&m.Category, &m.Markup, &m.RoundTo, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		markups = append(markups, m)
+	}
+	return markups, rows.Err()
+}
+
+// setCategoryMarkup сохраняет наценку и сразу пересчитывает розничные цены
+// материалов категории, у которых есть закупочная цена и нет ручной цены.
+func (s *DatabaseStore) setCategoryMarkup(markup CategoryMarkup) (CategoryMarkup, int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return markup, 0, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO category_markups (category, markup, round_to)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (category) DO UPDATE SET markup = $2, round_to = $3, updated_at = NOW()
+		RETURNING updated_at
+	`, markup.Category, markup.Markup, markup.RoundTo).Scan(&markup.UpdatedAt)
+	if err != nil {
+		return markup, 0, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, supplier_cost, price_per_m2
+		FROM materials
+		WHERE category = $1 AND supplier_cost IS NOT NULL AND NOT price_override
+		FOR UPDATE
+	`, markup.Category)
+	if err != nil {
+		return markup, 0, err
+	}
+	type repriced struct {
+		id             int64
+		cost, oldPrice float64
+	}
+	var items []repriced
+	for rows.Next() {
+		var it repriced
+		if err := rows.Scan(&it.id, &it.cost, &it.oldPrice); err != nil {
+			rows.Close()
+			return markup, 0, err
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return markup, 0, err
+	}
+
+	count := 0
+	for _, it := range items {
+		newPrice := retailFromCost(it.cost, markup)
+		if newPrice == roundTo(it.oldPrice, 2) {
+			continue
+		}
+		_, err := tx.Exec(`UPDATE materials SET price_per_m2 = $2, updated_at = NOW() WHERE id = $1`, it.id, newPrice)
+		if err != nil {
+			return markup, 0, err
+		}
+		if err := recordPriceChange(tx, it.id, it.oldPrice, newPrice, PriceSourceMarkup, ""); err != nil {
+			return markup, 0, err
+		}
+		count++
+	}
+	return markup, count, tx.Commit()
+}
+
+// marginReport строит маржу по каждому материалу. Материалы с наименьшей
+// маржой идут первыми, без закупочной цены — в конце.
+func marginReport(materials []Material, markups []CategoryMarkup) []MarginRow {
+	byCategory := make(map[string]float64, len(markups))
+	for _, m := range markups {
+		byCategory[m.Category] = m.Markup
+	}
+
+	report := make([]MarginRow, 0, len(materials))
+	for _, m := range materials {
+		row := MarginRow{
+			MaterialID:    m.ID,
+			SupplierCode:  m.SupplierCode,
+			Name:          m.Name,
+			Category:      m.Category,
+			SupplierCost:  m.SupplierCost,
+			RetailPrice:   m.PricePerM2,
+			PriceOverride: m.PriceOverride,
+		}
+		if markup, ok := byCategory[m.Category]; ok {
+			row.CategoryMarkup = &markup
+		}
+		if m.SupplierCost != nil && m.PricePerM2 > 0 {
+			margin := roundTo(m.PricePerM2-*m.SupplierCost, 2)
+			percent := roundTo(margin/m.PricePerM2*100, 2)
+			row.Margin = &margin
+			row.MarginPercent = &percent
+		}
+		report = append(report, row)
+	}
+
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i].MarginPercent, report[j].MarginPercent
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return *a < *b
+	})
+	return report
+}
+
+// Handlers
+func (a *App) handleGetCategoryMarkups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		markups, err := a.Storage.getCategoryMarkups()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, markups)
+	}
+}
+
+func (a *App) handleUpdateCategoryMarkup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var markup CategoryMarkup
+		if err := json.NewDecoder(r.Body).Decode(&markup); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		markup.Category = chi.URLParam(r, "category")
+		if markup.RoundTo == 0 {
+			markup.RoundTo = 10
+		}
+		if err := a.Validate.Struct(markup); err != nil || markup.Category == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		saved, repriced, err := a.Storage.setCategoryMarkup(markup)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"markup": saved, "repriced": repriced})
+	}
+}
+
+func (a *App) handleMarginReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		materials, err := a.Storage.getMaterials()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		markups, err := a.Storage.getCategoryMarkups()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, marginReport(materials, markups))
+	}
+}
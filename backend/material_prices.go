@@ -0,0 +1,361 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+var (
+	errEmptyPriceFilter = errors.New("at least one filter is required")
+	errNonPositivePrice = errors.New("price must stay positive")
+)
+
+// PriceSource — откуда пришло изменение цены материала.
+type PriceSource string
+
+const (
+	PriceSourceAdmin  PriceSource = "admin"
+	PriceSourceBulk   PriceSource = "bulk"
+	PriceSourceImport PriceSource = "import"
+	PriceSourceMarkup PriceSource = "markup"
+)
+
+type MaterialFilter struct {
+	Category           string  `json:"category,omitempty" validate:"max=100"`
+	SupplierCodePrefix string  `json:"supplierCodePrefix,omitempty" validate:"max=100"`
+	Color              string  `json:"color,omitempty" validate:"max=100"`
+	IDs                []int64 `json:"ids,omitempty" validate:"max=1000"`
+}
+
+func (f MaterialFilter) isEmpty() bool {
+	return f.Category == "" && f.SupplierCodePrefix == "" && f.Color == "" && len(f.IDs) == 0
+}
+
+// BulkPriceRequest — массовое изменение цены: на процент (8 = +8%) или на
+// сумму в рублях за м², с округлением до шага RoundTo (по умолчанию 1 ₽).
+type BulkPriceRequest struct {
+	Filter  MaterialFilter `json:"filter"`
+	Mode    string         `json:"mode" validate:"required,oneof=percent absolute"`
+	Value   float64        `json:"value" validate:"required"`
+	RoundTo float64        `json:"roundTo,omitempty" validate:"omitempty,gt=0,lte=1000"`
+	DryRun  bool           `json:"dryRun"`
+	Note    string         `json:"note,omitempty" validate:"max=500"`
+}
+
+type BulkPriceChange struct {
+	MaterialID   int64   `json:"materialId"`
+	SupplierCode string  `json:"supplierCode"`
+	Name         string  `json:"name"`
+	OldPrice     float64 `json:"oldPrice"`
+	NewPrice     float64 `json:"newPrice"`
+	Delta        float64 `json:"delta"`
+	// SetsOverride — у материала есть закупочная цена, и изменение выставит
+	// ему priceOverride, иначе пересчет по наценке вернул бы старую цену
+	SetsOverride bool `json:"setsOverride,omitempty"`
+}
+
+type BulkPriceResponse struct {
+	DryRun   bool              `json:"dryRun"`
+	Affected int               `json:"affected"`
+	Changes  []BulkPriceChange `json:"changes"`
+}
+
+// adjustPrice применяет изменение к одной цене и округляет результат.
+func adjustPrice(price float64, in BulkPriceRequest) float64 {
+	switch in.Mode {
+	case "percent":
+		price *= 1 + in.Value/100
+	case "absolute":
+		price += in.Value
+	}
+	step := in.RoundTo
+	if step == 0 {
+		step = 1
+	}
+	return roundTo(math.Round(price/step)*step, 2)
+}
+
+type PriceHistoryEntry struct {
+	ID        int64       `json:"id"`
+	OldPrice  float64     `json:"oldPrice"`
+	NewPrice  float64     `json:"newPrice"`
+	Source    PriceSource `json:"source"`
+	Note      string      `json:"note,omitempty"`
+	ChangedAt time.Time   `json:"changedAt"`
+}
+
+// PriceChangeSummary — итог изменений цены материала за период: цена до
+// первого изменения и после последнего.
+type PriceChangeSummary struct {
+	MaterialID    int64     `json:"materialId"`
+	SupplierCode  string    `json:"supplierCode"`
+	Name          string    `json:"name"`
+	Category      string    `json:"category"`
+	OldPrice      float64   `json:"oldPrice"`
+	NewPrice      float64   `json:"newPrice"`
+	Delta         float64   `json:"delta"`
+	DeltaPercent  float64   `json:"deltaPercent"`
+	Changes       int       `json:"changes"`
+	LastChangedAt time.Time `json:"lastChangedAt"`
+}
+
+func recordPriceChange(tx *sql.Tx, materialID int64, oldPrice, newPrice float64, source PriceSource, note string) error {
+	_, err := tx.Exec(`
+		INSERT INTO material_price_history (material_id, old_price, new_price, source, note)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+	`, materialID, oldPrice, newPrice, source, note)
+	return err
+}
+
+// bulkUpdatePrices выбирает материалы по фильтру и меняет цены в одной
+// транзакции. При dryRun транзакция откатывается, но расчет возвращается.
+// Материалам с закупочной ценой новая цена закрепляется через price_override.
+func (s *DatabaseStore) bulkUpdatePrices(in BulkPriceRequest) (BulkPriceResponse, error) {
+	resp := BulkPriceResponse{DryRun: in.DryRun, Changes: []BulkPriceChange{}}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return resp, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, supplier_code, name, price_per_m2, supplier_cost IS NOT NULL AND NOT price_override
+		FROM materials
+		WHERE ($1 = '' OR category = $1)
+		  AND ($2 = '' OR left(supplier_code, length($2)) = $2)
+		  AND ($3 = '' OR lower(color) = lower($3))
+		  AND (COALESCE(cardinality($4::bigint[]), 0) = 0 OR id = ANY($4::bigint[]))
+		ORDER BY id
+		FOR UPDATE
+	`, in.Filter.Category, in.Filter.SupplierCodePrefix, in.Filter.Color, pq.Array(in.Filter.IDs))
+	if err != nil {
+		return resp, err
+	}
+	for rows.Next() {
+		var c BulkPriceChange
+		if err := rows.Scan(&c.MaterialID, &c.SupplierCode, &c.Name, &c.OldPrice, &c.SetsOverride); err != nil {
+			rows.Close()
+			return resp, err
+		}
+		c.NewPrice = adjustPrice(c.OldPrice, in)
+		c.Delta = roundTo(c.NewPrice-c.OldPrice, 2)
+		c.SetsOverride = c.SetsOverride && c.Delta != 0
+		resp.Changes = append(resp.Changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return resp, err
+	}
+
+	for _, c := range resp.Changes {
+		if c.NewPrice <= 0 {
+			return resp, fmt.Errorf("%w: %s", errNonPositivePrice, c.SupplierCode)
+		}
+		if c.Delta != 0 {
+			resp.Affected++
+		}
+	}
+	if in.DryRun {
+		return resp, nil
+	}
+
+	for _, c := range resp.Changes {
+		if c.Delta == 0 {
+			continue
+		}
+		_, err := tx.Exec(`
+			UPDATE materials SET price_per_m2 = $2, price_override = price_override OR $3, updated_at = NOW()
+			WHERE id = $1
+		`, c.MaterialID, c.NewPrice, c.SetsOverride)
+		if err != nil {
+			return resp, err
+		}
+		if err := recordPriceChange(tx, c.MaterialID, c.OldPrice, c.NewPrice, PriceSourceBulk, in.Note); err != nil {
+			return resp, err
+		}
+	}
+	return resp, tx.Commit()
+}
+
+func (s *DatabaseStore) getPriceHistory(materialID int64) ([]PriceHistoryEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, old_price, new_price, source, COALESCE(note, ''), changed_at
+		FROM material_price_history
+		WHERE material_id = $1
+		ORDER BY changed_at DESC, id DESC
+	`, materialID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []PriceHistoryEntry{}
+	for rows.Next() {
+		var e PriceHistoryEntry
+		if err := rows.Scan(&e.ID, &e.OldPrice, &e.NewPrice, &e.Source, &e.Note, &e.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, e)
+	}
+	return history, rows.Err()
+}
+
+// getPriceChanges возвращает материалы с наибольшим относительным изменением
+// цены за период [from, to).
+func (s *DatabaseStore) getPriceChanges(from, to time.Time, limit int) ([]PriceChangeSummary, error) {
+	rows, err := s.db.Query(`
+		WITH period AS (
+			SELECT id, material_id, old_price, new_price, changed_at
+			FROM material_price_history
+			WHERE changed_at >= $1 AND changed_at < $2
+		), period_start AS (
+			SELECT DISTINCT ON (material_id) material_id, old_price
+			FROM period ORDER BY material_id, changed_at, id
+		), period_end AS (
+			SELECT DISTINCT ON (material_id) material_id, new_price, changed_at
+			FROM period ORDER BY material_id, changed_at DESC, id DESC
+		), period_count AS (
+			SELECT material_id, COUNT(*) AS changes FROM period GROUP BY material_id
+		)
+		SELECT m.id, m.supplier_code, m.name, m.category, ps.old_price, pe.new_price, pc.changes, pe.changed_at
+		FROM period_start ps
+		JOIN period_end pe USING (material_id)
+		JOIN period_count pc USING (material_id)
+		JOIN materials m ON m.id = ps.material_id
+		ORDER BY abs(pe.new_price - ps.old_price) / NULLIF(ps.old_price, 0) DESC NULLS LAST, m.id
+		LIMIT $3
+	`, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []PriceChangeSummary{}
+	for rows.Next() {
+		var c PriceChangeSummary
+		err := rows.Scan(&c.MaterialID, &c.SupplierCode, &c.Name, &c.Category, &c.OldPrice, &c.NewPrice, &c.Changes, &c.LastChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		c.Delta = roundTo(c.NewPrice-c.OldPrice, 2)
+		if c.OldPrice > 0 {
+			c.DeltaPercent = roundTo(c.Delta/c.OldPrice*100, 2)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (a *App) handleBulkUpdatePrices() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in BulkPriceRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		if in.Filter.isEmpty() {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": errEmptyPriceFilter.Error()})
+			return
+		}
+
+		resp, err := a.Storage.bulkUpdatePrices(in)
+		if errors.Is(err, errNonPositivePrice) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (a *App) handleMaterialPriceHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid material ID"})
+			return
+		}
+
+		material, err := a.Storage.findMaterial(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if material == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "material not found"})
+			return
+		}
+
+		history, err := a.Storage.getPriceHistory(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"material": material, "history": history})
+	}
+}
+
+// handlePriceChangesReport — отчет о крупнейших изменениях цен. Период задается
+// параметрами from и to (YYYY-MM-DD, to включительно), по умолчанию последние 30 дней.
+func (a *App) handlePriceChangesReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		to := time.Now()
+		if v := q.Get("to"); v != "" {
+			d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid to date"})
+				return
+			}
+			to = d.AddDate(0, 0, 1)
+		}
+		from := to.AddDate(0, 0, -30)
+		if v := q.Get("from"); v != "" {
+			d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid from date"})
+				return
+			}
+			from = d
+		}
+		if !from.Before(to) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from must be before to"})
+			return
+		}
+
+		limit := 20
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 200 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+
+		changes, err := a.Storage.getPriceChanges(from, to, limit)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"from": from, "to": to, "changes": changes})
+	}
+}
@@ -0,0 +1,1099 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ezhigval/piter-jaluzi/backend/internal/imagemirror"
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// measurementPhotoPrefix — адреса фотографий с замеров
+const measurementPhotoPrefix = "/media/measurements"
+
+const (
+	// maxWindowPhotos — сколько фотографий можно приложить к одному окну
+	maxWindowPhotos = 20
+	// maxPhotoUpload — предел размера одного запроса с фотографиями
+	maxPhotoUpload = 50 << 20
+)
+
+// SheetStatus — состояние листа замера
+type SheetStatus string
+
+const (
+	SheetDraft     SheetStatus = "draft"
+	SheetCompleted SheetStatus = "completed"
+)
+
+// MountingType — куда крепится изделие
+type MountingType string
+
+const (
+	MountOpening MountingType = "opening"
+	MountSash    MountingType = "sash"
+	MountWall    MountingType = "wall"
+	MountCeiling MountingType = "ceiling"
+)
+
+var (
+	errSheetNoTarget    = errors.New("leadId, orderId or bookingId is required")
+	errSheetEmpty       = errors.New("measurement sheet has no windows")
+	errOrderNotFound    = errors.New("order not found")
+	errBookingNotFound  = errors.New("booking not found")
+	errBookingLead      = errors.New("booking belongs to another lead")
+	errTooManyPhotos    = errors.New("too many photos")
+	errWindowIncomplete = errors.New("window is not ready for pricing")
+	errSheetOrdered     = errors.New("measurement sheet already has an order")
+	errOrderLead        = errors.New("order belongs to another lead")
+)
+
+// SheetWindow — замер одного окна. Ширина меряется сверху, посередине и
+// снизу, высота — слева, посередине и справа.
+type SheetWindow struct {
+	ID          int64       `json:"id"`
+	Position    int         `json:"position"`
+	Room        string      `json:"room,omitempty" validate:"max=100"`
+	ProductType ProductType `json:"productType,omitempty" validate:"omitempty,oneof=horizontal vertical roller"`
+	MaterialID  *int64      `json:"materialId,omitempty" validate:"omitempty,gt=0"`
+
+	WidthTopMm     *int `json:"widthTopMm,omitempty" validate:"omitempty,gt=0,lte=10000"`
+	WidthMiddleMm  *int `json:"widthMiddleMm,omitempty" validate:"omitempty,gt=0,lte=10000"`
+	WidthBottomMm  *int `json:"widthBottomMm,omitempty" validate:"omitempty,gt=0,lte=10000"`
+	HeightLeftMm   *int `json:"heightLeftMm,omitempty" validate:"omitempty,gt=0,lte=10000"`
+	HeightMiddleMm *int `json:"heightMiddleMm,omitempty" validate:"omitempty,gt=0,lte=10000"`
+	HeightRightMm  *int `json:"heightRightMm,omitempty" validate:"omitempty,gt=0,lte=10000"`
+	DepthMm        *int `json:"depthMm,omitempty" validate:"omitempty,gt=0,lte=2000"`
+	// ProductWidthMm и ProductHeightMm — размер изделия, если замерщик задал его сам
+	ProductWidthMm  *int `json:"productWidthMm,omitempty" validate:"omitempty,gt=0,lte=10000"`
+	ProductHeightMm *int `json:"productHeightMm,omitempty" validate:"omitempty,gt=0,lte=10000"`
+
+	MountingType    MountingType      `json:"mountingType,omitempty" validate:"omitempty,oneof=opening sash wall ceiling"`
+	MountingSurface string            `json:"mountingSurface,omitempty" validate:"omitempty,oneof=concrete brick drywall wood pvc metal"`
+	ControlSide     string            `json:"controlSide,omitempty" validate:"omitempty,oneof=left right"`
+	Obstacles       string            `json:"obstacles,omitempty" validate:"max=1000"`
+	Quantity        int               `json:"quantity" validate:"omitempty,gt=0,lte=100"`
+	Options         map[string]string `json:"options" validate:"max=20"`
+	Notes           string            `json:"notes,omitempty" validate:"max=5000"`
+	// Photos добавляются и удаляются отдельными запросами
+	Photos []string `json:"photos"`
+
+	// OrderWidthMm и OrderHeightMm — размер, по которому изделие попадет в заказ
+	OrderWidthMm  *int `json:"orderWidthMm,omitempty"`
+	OrderHeightMm *int `json:"orderHeightMm,omitempty"`
+}
+
+// orderSize — размер изделия по замеру. Без заданного замерщиком размера
+// изделие в проем (и на створку) делается по самому узкому месту, а
+// на стену и потолок — по самому широкому, чтобы перекрыть проем.
+func (w SheetWindow) orderSize() (width, height *int) {
+	pick := func(override *int, values ...*int) *int {
+		if override != nil {
+			return override
+		}
+		var res *int
+		for _, v := range values {
+			if v == nil {
+				continue
+			}
+			widest := w.MountingType == MountWall || w.MountingType == MountCeiling
+			if res == nil || (widest && *v > *res) || (!widest && *v < *res) {
+				res = v
+			}
+		}
+		return res
+	}
+	return pick(w.ProductWidthMm, w.WidthTopMm, w.WidthMiddleMm, w.WidthBottomMm),
+		pick(w.ProductHeightMm, w.HeightLeftMm, w.HeightMiddleMm, w.HeightRightMm)
+}
+
+func (w SheetWindow) withDerived() SheetWindow {
+	w.OrderWidthMm, w.OrderHeightMm = w.orderSize()
+	return w
+}
+
+// orderItemRequest — изделие для заказа. Крепление, поверхность, сторона
+// управления и помещение уходят в опции, чтобы их видело производство.
+func (w SheetWindow) orderItemRequest() (OrderItemRequest, error) {
+	width, height := w.orderSize()
+	if w.ProductType == "" || w.MaterialID == nil || width == nil || height == nil {
+		return OrderItemRequest{}, fmt.Errorf("%w: window %d needs productType, materialId and sizes",
+			errWindowIncomplete, w.Position)
+	}
+	options := map[string]string{}
+	for k, v := range w.Options {
+		options[k] = v
+	}
+	for key, v := range map[string]string{
+		"room": w.Room, "mounting": string(w.MountingType), "surface": w.MountingSurface, "control": w.ControlSide,
+	} {
+		if _, ok := options[key]; !ok && v != "" {
+			options[key] = v
+		}
+	}
+	return OrderItemRequest{
+		ProductType: w.ProductType,
+		MaterialID:  *w.MaterialID,
+		WidthMm:     *width,
+		HeightMm:    *height,
+		Quantity:    w.Quantity,
+		Options:     options,
+	}, nil
+}
+
+type MeasurementSheet struct {
+	ID           int64         `json:"id"`
+	LeadID       *int64        `json:"leadId,omitempty"`
+	OrderID      *int64        `json:"orderId,omitempty"`
+	BookingID    *int64        `json:"bookingId,omitempty"`
+	MeasurerID   *int64        `json:"measurerId,omitempty"`
+	MeasurerName string        `json:"measurerName,omitempty"`
+	Status       SheetStatus   `json:"status"`
+	Note         string        `json:"note,omitempty"`
+	Windows      []SheetWindow `json:"windows"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+	CompletedAt  *time.Time    `json:"completedAt,omitempty"`
+}
+
+// MeasurementSheetRequest — новый лист. По bookingId лист получает заявку
+// и замерщика записи, по orderId без заявки — заявку заказа.
+type MeasurementSheetRequest struct {
+	LeadID     *int64 `json:"leadId,omitempty" validate:"omitempty,gt=0"`
+	OrderID    *int64 `json:"orderId,omitempty" validate:"omitempty,gt=0"`
+	BookingID  *int64 `json:"bookingId,omitempty" validate:"omitempty,gt=0"`
+	MeasurerID *int64 `json:"measurerId,omitempty" validate:"omitempty,gt=0"`
+	Note       string `json:"note,omitempty" validate:"max=5000"`
+}
+
+type MeasurementSheetUpdateRequest struct {
+	MeasurerID *int64 `json:"measurerId,omitempty" validate:"omitempty,gt=0"`
+	Note       string `json:"note" validate:"max=5000"`
+}
+
+// SheetOrderRequest — заказ по листу. Если лист уже связан с заказом,
+// его изделия заменяются, остальные поля не меняются.
+type SheetOrderRequest struct {
+	InstallationDate string `json:"installationDate,omitempty"`
+	Note             string `json:"note,omitempty" validate:"max=5000"`
+	Actor            string `json:"actor" validate:"max=100"`
+}
+
+type SheetEstimateResponse struct {
+	Items []OrderItem `json:"items"`
+	Total float64     `json:"total"`
+}
+
+// Sheets
+const sheetColumns = `s.id, s.lead_id, s.order_id, s.booking_id, s.measurer_id, COALESCE(ms.name, ''), s.status, s.note,
+	s.created_at, s.updated_at, s.completed_at`
+
+const sheetTables = `measurement_sheets s LEFT JOIN measurers ms ON ms.id = s.measurer_id`
+
+func scanSheet(row interface{ Scan(...any) error }) (MeasurementSheet, error) {
+	var s MeasurementSheet
+	err := row.Scan(&s.ID, &s.LeadID, &s.OrderID, &s.BookingID, &s.MeasurerID, &s.MeasurerName, &s.Status, &s.Note,
+		&s.CreatedAt, &s.UpdatedAt, &s.CompletedAt)
+	return s, err
+}
+
+const windowColumns = `id, position, room, product_type, material_id, width_top_mm, width_middle_mm, width_bottom_mm,
+	height_left_mm, height_middle_mm, height_right_mm, depth_mm, product_width_mm, product_height_mm,
+	mounting_type, mounting_surface, control_side, obstacles, quantity, options, notes, photos`
+
+func scanWindow(row interface{ Scan(...any) error }) (SheetWindow, error) {
+	var (
+		w       SheetWindow
+		options []byte
+		photos  pq.StringArray
+	)
+	err := row.Scan(&w.ID, &w.Position, &w.Room, &w.ProductType, &w.MaterialID, &w.WidthTopMm, &w.WidthMiddleMm,
+		&w.WidthBottomMm, &w.HeightLeftMm, &w.HeightMiddleMm, &w.HeightRightMm, &w.DepthMm, &w.ProductWidthMm,
+		&w.ProductHeightMm, &w.MountingType, &w.MountingSurface, &w.ControlSide, &w.Obstacles, &w.Quantity, &options,
+		&w.Notes, &photos)
+	if err != nil {
+		return w, err
+	}
+	w.Photos = []string(photos)
+	if w.Photos == nil {
+		w.Photos = []string{}
+	}
+	return w, json.Unmarshal(options, &w.Options)
+}
+
+func (s *DatabaseStore) getSheetWindows(sheetID int64) ([]SheetWindow, error) {
+	rows, err := s.db.Query("SELECT "+windowColumns+" FROM measurement_windows WHERE sheet_id = $1 ORDER BY position, id", sheetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []SheetWindow{}
+	for rows.Next() {
+		w, err := scanWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w.withDerived())
+	}
+	return windows, rows.Err()
+}
+
+// findSheet возвращает лист с окнами или nil
+func (s *DatabaseStore) findSheet(id int64) (*MeasurementSheet, error) {
+	sheet, err := scanSheet(s.db.QueryRow("SELECT "+sheetColumns+" FROM "+sheetTables+" WHERE s.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sheet.Windows, err = s.getSheetWindows(id); err != nil {
+		return nil, err
+	}
+	return &sheet, nil
+}
+
+// getSheets — листы заявки или заказа с окнами, новые первыми
+func (s *DatabaseStore) getSheets(leadID, orderID *int64) ([]MeasurementSheet, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if leadID != nil {
+		add("s.lead_id = $%d", *leadID)
+	}
+	if orderID != nil {
+		add("s.order_id = $%d", *orderID)
+	}
+	query := "SELECT " + sheetColumns + " FROM " + sheetTables
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := s.db.Query(query+" ORDER BY s.created_at DESC, s.id DESC LIMIT 100", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sheets := []MeasurementSheet{}
+	for rows.Next() {
+		sheet, err := scanSheet(rows)
+		if err != nil {
+			return nil, err
+		}
+		sheets = append(sheets, sheet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range sheets {
+		if sheets[i].Windows, err = s.getSheetWindows(sheets[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return sheets, nil
+}
+
+// addSheet создает лист, дополняя заявку и замерщика по записи и заказу
+func (s *DatabaseStore) addSheet(in MeasurementSheetRequest) (*MeasurementSheet, error) {
+	leadID, measurerID := in.LeadID, in.MeasurerID
+	if in.BookingID != nil {
+		b, err := s.findBooking("b.id = $1", *in.BookingID)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			return nil, errBookingNotFound
+		}
+		if leadID != nil && *leadID != b.LeadID {
+			return nil, errBookingLead
+		}
+		leadID = &b.LeadID
+		if measurerID == nil {
+			measurerID = &b.MeasurerID
+		}
+	}
+	if in.OrderID != nil {
+		var orderLead *int64
+		err := s.db.QueryRow("SELECT lead_id FROM orders WHERE id = $1", *in.OrderID).Scan(&orderLead)
+		if err == sql.ErrNoRows {
+			return nil, errOrderNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if leadID != nil && orderLead != nil && *leadID != *orderLead {
+			return nil, errOrderLead
+		}
+		if leadID == nil {
+			leadID = orderLead
+		}
+	}
+	if leadID == nil && in.OrderID == nil {
+		return nil, errSheetNoTarget
+	}
+	if leadID != nil {
+		var exists bool
+		if err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM leads WHERE id = $1)", *leadID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errLeadNotFound
+		}
+	}
+
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO measurement_sheets (lead_id, order_id, booking_id, measurer_id, note)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, leadID, in.OrderID, in.BookingID, measurerID, strings.TrimSpace(in.Note)).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return s.findSheet(id)
+}
+
+func (s *DatabaseStore) updateSheet(id int64, in MeasurementSheetUpdateRequest) (*MeasurementSheet, error) {
+	res, err := s.db.Exec(`
+		UPDATE measurement_sheets
+		SET note = $2, measurer_id = COALESCE($3, measurer_id), updated_at = NOW()
+		WHERE id = $1
+	`, id, strings.TrimSpace(in.Note), in.MeasurerID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return s.findSheet(id)
+}
+
+// completeSheet отмечает замер выполненным и переводит заявку в measured,
+// если это разрешено из ее текущего статуса. Возвращает nil, если листа нет.
+func (s *DatabaseStore) completeSheet(id int64, actor string) (*MeasurementSheet, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		leadID  *int64
+		windows int
+	)
+	err = tx.QueryRow(`
+		SELECT lead_id, (SELECT COUNT(*) FROM measurement_windows w WHERE w.sheet_id = s.id)
+		FROM measurement_sheets s WHERE id = $1 FOR UPDATE
+	`, id).Scan(&leadID, &windows)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if windows == 0 {
+		return nil, errSheetEmpty
+	}
+	_, err = tx.Exec(`
+		UPDATE measurement_sheets
+		SET status = 'completed', completed_at = COALESCE(completed_at, NOW()), updated_at = NOW()
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	comment := fmt.Sprintf("Лист замера №%d, окон: %d", id, windows)
+	if err := advanceLeadTx(tx, leadID, LeadStatusMeasured, actor, comment); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.findSheet(id)
+}
+
+// addSheetOrder создает заказ и привязывает его к листу в одной транзакции.
+// Если лист уже получил заказ параллельным запросом, возвращается
+// errSheetOrdered и новый заказ не сохраняется.
+func (s *DatabaseStore) addSheetOrder(id int64, o Order, actor string) (Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return o, err
+	}
+	defer tx.Rollback()
+
+	if o, err = addOrderTx(tx, o, actor); err != nil {
+		return o, err
+	}
+	res, err := tx.Exec(`
+		UPDATE measurement_sheets SET order_id = $2, updated_at = NOW()
+		WHERE id = $1 AND order_id IS NULL
+	`, id, o.ID)
+	if err != nil {
+		return o, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return o, errSheetOrdered
+	}
+	return o, tx.Commit()
+}
+
+// Windows
+func windowArgs(w SheetWindow) ([]any, error) {
+	options := w.Options
+	if options == nil {
+		options = map[string]string{}
+	}
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	quantity := w.Quantity
+	if quantity == 0 {
+		quantity = 1
+	}
+	return []any{strings.TrimSpace(w.Room), w.ProductType, w.MaterialID, w.WidthTopMm, w.WidthMiddleMm,
+		w.WidthBottomMm, w.HeightLeftMm, w.HeightMiddleMm, w.HeightRightMm, w.DepthMm, w.ProductWidthMm,
+		w.ProductHeightMm, w.MountingType, w.MountingSurface, w.ControlSide, strings.TrimSpace(w.Obstacles),
+		quantity, optionsJSON, strings.TrimSpace(w.Notes)}, nil
+}
+
+// addSheetWindow добавляет окно в конец листа. Возвращает nil, если листа нет.
+func (s *DatabaseStore) addSheetWindow(sheetID int64, w SheetWindow) (*SheetWindow, error) {
+	args, err := windowArgs(w)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var position int
+	err = tx.QueryRow(`
+		SELECT COALESCE((SELECT MAX(position) FROM measurement_windows WHERE sheet_id = s.id), 0) + 1
+		FROM measurement_sheets s WHERE id = $1 FOR UPDATE
+	`, sheetID).Scan(&position)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	created, err := scanWindow(tx.QueryRow(`
+		INSERT INTO measurement_windows (sheet_id, position, room, product_type, material_id, width_top_mm,
+		                                 width_middle_mm, width_bottom_mm, height_left_mm, height_middle_mm,
+		                                 height_right_mm, depth_mm, product_width_mm, product_height_mm,
+		                                 mounting_type, mounting_surface, control_side, obstacles, quantity,
+		                                 options, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		RETURNING `+windowColumns, append([]any{sheetID, position}, args...)...))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE measurement_sheets SET updated_at = NOW() WHERE id = $1", sheetID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	created = created.withDerived()
+	return &created, nil
+}
+
+// updateSheetWindow заменяет замеры окна целиком; фотографии сохраняются.
+// Возвращает nil, если окна в листе нет.
+func (s *DatabaseStore) updateSheetWindow(sheetID, windowID int64, w SheetWindow) (*SheetWindow, error) {
+	args, err := windowArgs(w)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := scanWindow(s.db.QueryRow(`
+		UPDATE measurement_windows
+		SET room = $3, product_type = $4, material_id = $5, width_top_mm = $6, width_middle_mm = $7,
+		    width_bottom_mm = $8, height_left_mm = $9, height_middle_mm = $10, height_right_mm = $11, depth_mm = $12,
+		    product_width_mm = $13, product_height_mm = $14, mounting_type = $15, mounting_surface = $16,
+		    control_side = $17, obstacles = $18, quantity = $19, options = $20, notes = $21, updated_at = NOW()
+		WHERE sheet_id = $1 AND id = $2
+		RETURNING `+windowColumns, append([]any{sheetID, windowID}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	updated = updated.withDerived()
+	return &updated, nil
+}
+
+// deleteSheetWindow удаляет окно и сдвигает нумерацию следующих
+func (s *DatabaseStore) deleteSheetWindow(sheetID, windowID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var position int
+	err = tx.QueryRow("DELETE FROM measurement_windows WHERE sheet_id = $1 AND id = $2 RETURNING position", sheetID, windowID).
+		Scan(&position)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE measurement_windows SET position = position - 1 WHERE sheet_id = $1 AND position > $2",
+		sheetID, position)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addWindowPhotos дописывает адреса фотографий к окну. Возвращает nil,
+// если окна в листе нет.
+func (s *DatabaseStore) addWindowPhotos(sheetID, windowID int64, urls []string) (*SheetWindow, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRow(`
+		SELECT COALESCE(array_length(photos, 1), 0) FROM measurement_windows
+		WHERE sheet_id = $1 AND id = $2 FOR UPDATE
+	`, sheetID, windowID).Scan(&count)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if count+len(urls) > maxWindowPhotos {
+		return nil, errTooManyPhotos
+	}
+	w, err := scanWindow(tx.QueryRow(`
+		UPDATE measurement_windows SET photos = photos || $3::TEXT[], updated_at = NOW()
+		WHERE sheet_id = $1 AND id = $2
+		RETURNING `+windowColumns, sheetID, windowID, pq.Array(urls)))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	w = w.withDerived()
+	return &w, nil
+}
+
+// removeWindowPhoto убирает фотографию из окна. Файл остается: такая же
+// фотография может быть приложена к другому окну.
+func (s *DatabaseStore) removeWindowPhoto(sheetID, windowID int64, url string) (*SheetWindow, error) {
+	w, err := scanWindow(s.db.QueryRow(`
+		UPDATE measurement_windows SET photos = array_remove(photos, $3), updated_at = NOW()
+		WHERE sheet_id = $1 AND id = $2
+		RETURNING `+windowColumns, sheetID, windowID, url))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	w = w.withDerived()
+	return &w, nil
+}
+
+// sheetItemRequests — изделия листа для заказа; цены по ним считает
+// priceOrderItems, как калькулятор
+func (a *App) sheetItemRequests(sheet MeasurementSheet) ([]OrderItemRequest, error) {
+	if len(sheet.Windows) == 0 {
+		return nil, errSheetEmpty
+	}
+	reqs := make([]OrderItemRequest, 0, len(sheet.Windows))
+	for _, w := range sheet.Windows {
+		req, err := w.orderItemRequest()
+		if err != nil {
+			return nil, err
+		}
+		if err := a.Validate.Struct(req); err != nil {
+			return nil, fmt.Errorf("%w: window %d size is out of range", errWindowIncomplete, w.Position)
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+func writeSheetError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errSheetNoTarget), errors.Is(err, errBookingLead), errors.Is(err, errOrderLead), errors.Is(err, errWindowIncomplete),
+		errors.Is(err, errMaterialNotFound), errors.Is(err, errTooManyPhotos):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, errLeadNotFound), errors.Is(err, errOrderNotFound), errors.Is(err, errBookingNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, errSheetEmpty), errors.Is(err, errSheetOrdered):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+	}
+}
+
+// sheetParams разбирает {id} и, если есть, {windowId} из пути
+func sheetParams(w http.ResponseWriter, r *http.Request) (sheetID, windowID int64, ok bool) {
+	sheetID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid sheet ID"})
+		return 0, 0, false
+	}
+	if v := chi.URLParam(r, "windowId"); v != "" {
+		if windowID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid window ID"})
+			return 0, 0, false
+		}
+	}
+	return sheetID, windowID, true
+}
+
+// Handlers
+func (a *App) handleCreateSheet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in MeasurementSheetRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		sheet, err := a.Storage.addSheet(in)
+		if err != nil {
+			writeSheetError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, sheet)
+	}
+}
+
+// handleListSheets — GET /api/measurement/sheets?leadId=&orderId=
+func (a *App) handleListSheets() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var leadID, orderID *int64
+		for key, dst := range map[string]**int64{"leadId": &leadID, "orderId": &orderID} {
+			if v := r.URL.Query().Get(key); v != "" {
+				id, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + key})
+					return
+				}
+				*dst = &id
+			}
+		}
+
+		sheets, err := a.Storage.getSheets(leadID, orderID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, sheets)
+	}
+}
+
+func (a *App) handleGetSheet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+		sheet, err := a.Storage.findSheet(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if sheet == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "sheet not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, sheet)
+	}
+}
+
+func (a *App) handleUpdateSheet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+
+		var in MeasurementSheetUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		sheet, err := a.Storage.updateSheet(id, in)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if sheet == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "sheet not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, sheet)
+	}
+}
+
+func (a *App) handleCompleteSheet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+
+		var in struct {
+			Actor string `json:"actor" validate:"max=100"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+				return
+			}
+		}
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		actor := strings.TrimSpace(in.Actor)
+		if actor == "" {
+			actor = "measurer"
+		}
+
+		sheet, err := a.Storage.completeSheet(id, actor)
+		if err != nil {
+			writeSheetError(w, err)
+			return
+		}
+		if sheet == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "sheet not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, sheet)
+	}
+}
+
+func (a *App) handleAddSheetWindow() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sheetID, _, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+
+		var in SheetWindow
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		created, err := a.Storage.addSheetWindow(sheetID, in)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if created == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "sheet not found"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func (a *App) handleUpdateSheetWindow() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sheetID, windowID, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+
+		var in SheetWindow
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		updated, err := a.Storage.updateSheetWindow(sheetID, windowID, in)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if updated == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "window not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func (a *App) handleDeleteSheetWindow() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sheetID, windowID, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+		if err := a.Storage.deleteSheetWindow(sheetID, windowID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// handleUploadWindowPhotos принимает фотографии окна из формы multipart
+// (поле photo, можно несколько файлов) и сохраняет их в /media/measurements
+func (a *App) handleUploadWindowPhotos() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sheetID, windowID, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxPhotoUpload)
+		if err := r.ParseMultipartForm(maxPhotoUpload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid multipart form"})
+			return
+		}
+		files := r.MultipartForm.File["photo"]
+		if len(files) == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "photo is required"})
+			return
+		}
+		if len(files) > maxWindowPhotos {
+			writeSheetError(w, errTooManyPhotos)
+			return
+		}
+
+		urls := make([]string, 0, len(files))
+		for _, fh := range files {
+			f, err := fh.Open()
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid multipart form"})
+				return
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid multipart form"})
+				return
+			}
+			entry, err := a.Photos.Put(data)
+			switch {
+			case errors.Is(err, imagemirror.ErrNotImage):
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported image: " + fh.Filename})
+				return
+			case errors.Is(err, imagemirror.ErrTooLarge):
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "image is too large: " + fh.Filename})
+				return
+			case err != nil:
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "storage error"})
+				return
+			}
+			urls = append(urls, entry.URL)
+		}
+
+		updated, err := a.Storage.addWindowPhotos(sheetID, windowID, urls)
+		if err != nil {
+			writeSheetError(w, err)
+			return
+		}
+		if updated == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "window not found"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, updated)
+	}
+}
+
+// handleDeleteWindowPhoto — DELETE .../photos?url=/media/measurements/...
+func (a *App) handleDeleteWindowPhoto() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sheetID, windowID, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+		url := r.URL.Query().Get("url")
+		if url == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url is required"})
+			return
+		}
+
+		updated, err := a.Storage.removeWindowPhoto(sheetID, windowID, url)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if updated == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "window not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+// handleSheetEstimate считает изделия листа по каталогу, ничего не сохраняя
+func (a *App) handleSheetEstimate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+		sheet, err := a.Storage.findSheet(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if sheet == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "sheet not found"})
+			return
+		}
+
+		reqs, err := a.sheetItemRequests(*sheet)
+		if err != nil {
+			writeSheetError(w, err)
+			return
+		}
+		items, err := a.priceOrderItems(reqs)
+		if err != nil {
+			writeSheetError(w, err)
+			return
+		}
+		resp := SheetEstimateResponse{Items: items}
+		for _, item := range items {
+			resp.Total += item.Price
+		}
+		resp.Total = roundTo(resp.Total, 2)
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// handleSheetOrder создает заказ по листу или, если лист уже связан с
+// заказом в статусе new, заменяет изделия этого заказа (и дату монтажа
+// с заметкой, если они переданы). Выезд и клиент — как при обычном создании заказа по заявке.
+func (a *App) handleSheetOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _, ok := sheetParams(w, r)
+		if !ok {
+			return
+		}
+
+		var in SheetOrderRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+				return
+			}
+		}
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		actor := strings.TrimSpace(in.Actor)
+		if actor == "" {
+			actor = "admin"
+		}
+
+		sheet, err := a.Storage.findSheet(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if sheet == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "sheet not found"})
+			return
+		}
+		reqs, err := a.sheetItemRequests(*sheet)
+		if err != nil {
+			writeSheetError(w, err)
+			return
+		}
+
+		if sheet.OrderID != nil {
+			items, err := a.priceOrderItems(reqs)
+			if err != nil {
+				writeOrderError(w, err)
+				return
+			}
+			var update OrderUpdateRequest
+			if in.InstallationDate != "" {
+				update.InstallationDate = &in.InstallationDate
+			}
+			if in.Note != "" {
+				update.Note = &in.Note
+			}
+			order, err := a.Storage.updateOrder(*sheet.OrderID, update, orderChange{
+				Items: items,
+				// Лист правят после замера; заказ в производстве он уже не меняет
+				ItemStatuses: []ProductionStatus{ProductionNew},
+			})
+			if err != nil {
+				writeOrderError(w, err)
+				return
+			}
+			if order == nil {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "order not found"})
+				return
+			}
+			writeJSON(w, http.StatusOK, order.withDerived())
+			return
+		}
+
+		order, err := a.buildOrder(OrderRequest{
+			LeadID:           sheet.LeadID,
+			InstallationDate: in.InstallationDate,
+			Note:             in.Note,
+			Items:            reqs,
+		})
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+		created, err := a.Storage.addSheetOrder(id, order, actor)
+		if err != nil {
+			writeSheetError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created.withDerived())
+	}
+}
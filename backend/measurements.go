@@ -0,0 +1,1343 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// BookingStatus — состояние записи на замер
+type BookingStatus string
+
+const (
+	BookingBooked    BookingStatus = "booked"
+	BookingCancelled BookingStatus = "cancelled"
+)
+
+// measurementBookingLock — ключ advisory-блокировки: запись, перенос и
+// проверка свободных замерщиков идут по одной, поэтому два клиента не
+// займут одно время
+const measurementBookingLock = 4402
+
+var (
+	errSlotUnavailable     = errors.New("slot is not available")
+	errSlotOutOfWindow     = errors.New("slot is outside the booking window")
+	errBookingCancelled    = errors.New("booking is cancelled")
+	errMeasurerHasBookings = errors.New("measurer has bookings")
+)
+
+// MeasurementSettings — сетка записи. Слоты идут через SlotMinutes плюс
+// TravelBufferMinutes на дорогу; клиент может записаться не раньше чем
+// за MinNoticeHours и не дальше чем на HorizonDays дней вперед.
+type MeasurementSettings struct {
+	SlotMinutes         int    `json:"slotMinutes" validate:"gte=15,lte=480"`
+	TravelBufferMinutes int    `json:"travelBufferMinutes" validate:"gte=0,lte=240"`
+	MinNoticeHours      int    `json:"minNoticeHours" validate:"gte=0,lte=168"`
+	HorizonDays         int    `json:"horizonDays" validate:"gte=1,lte=90"`
+	Timezone            string `json:"timezone" validate:"required,max=50"`
+}
+
+var defaultMeasurementSettings = MeasurementSettings{
+	SlotMinutes:         60,
+	TravelBufferMinutes: 30,
+	MinNoticeHours:      2,
+	HorizonDays:         14,
+	Timezone:            "Europe/Moscow",
+}
+
+func (s MeasurementSettings) location() *time.Location {
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		return loc
+	}
+	return time.Local
+}
+
+func (s MeasurementSettings) slot() time.Duration {
+	return time.Duration(s.SlotMinutes) * time.Minute
+}
+
+func (s MeasurementSettings) buffer() time.Duration {
+	return time.Duration(s.TravelBufferMinutes) * time.Minute
+}
+
+// window — интервал, в который клиент может записаться сам
+func (s MeasurementSettings) window(now time.Time) (earliest, latest time.Time) {
+	y, m, d := now.In(s.location()).Date()
+	earliest = now.Add(time.Duration(s.MinNoticeHours) * time.Hour)
+	latest = time.Date(y, m, d+s.HorizonDays+1, 0, 0, 0, 0, s.location())
+	return earliest, latest
+}
+
+type Measurer struct {
+	ID             int64  `json:"id"`
+	Name           string `json:"name" validate:"required,max=100"`
+	Phone          string `json:"phone,omitempty" validate:"max=20"`
+	TelegramChatID *int64 `json:"telegramChatId,omitempty"`
+	IsActive       bool   `json:"isActive"`
+	// WorkDays — рабочие дни (1 — понедельник, 7 — воскресенье), часы — с WorkStart до WorkEnd
+	WorkDays  []int `json:"workDays" validate:"required,min=1,dive,gte=1,lte=7"`
+	WorkStart int   `json:"workStart" validate:"gte=0,lte=23"`
+	WorkEnd   int   `json:"workEnd" validate:"gte=1,lte=24,gtfield=WorkStart"`
+	// DaysOff — выходные вне рабочей недели; меняются отдельными запросами
+	DaysOff []MeasurerDayOff `json:"daysOff"`
+}
+
+type MeasurerDayOff struct {
+	Day     string `json:"day"`
+	Comment string `json:"comment,omitempty" validate:"max=200"`
+}
+
+type MeasurementBooking struct {
+	ID           int64         `json:"id"`
+	Token        string        `json:"token"`
+	MeasurerID   int64         `json:"measurerId"`
+	MeasurerName string        `json:"measurerName"`
+	LeadID       int64         `json:"leadId"`
+	Name         string        `json:"name"`
+	Phone        string        `json:"phone"`
+	Address      string        `json:"address,omitempty"`
+	StartsAt     time.Time     `json:"startsAt"`
+	EndsAt       time.Time     `json:"endsAt"`
+	Status       BookingStatus `json:"status"`
+	CancelReason string        `json:"cancelReason,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+	// ManageURL — ссылка для клиента: просмотр, перенос и отмена
+	ManageURL string `json:"manageUrl"`
+}
+
+func (b MeasurementBooking) withDerived() MeasurementBooking {
+	b.ManageURL = "/api/measurement/bookings/" + b.Token
+	return b
+}
+
+// MeasurementSlot — время, на которое свободен хотя бы один замерщик
+type MeasurementSlot struct {
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	Available int       `json:"available"`
+}
+
+type MeasurementSlotsResponse struct {
+	SlotMinutes int               `json:"slotMinutes"`
+	Timezone    string            `json:"timezone"`
+	Slots       []MeasurementSlot `json:"slots"`
+}
+
+// MeasurementBookingRequest — форма записи на замер: данные заявки и
+// выбранный слот из GET /api/measurement/slots
+type MeasurementBookingRequest struct {
+	LeadRequest
+	StartsAt time.Time `json:"startsAt" validate:"required"`
+}
+
+// MeasurementRescheduleRequest — перенос записи. MeasurerID и Actor
+// учитываются только для администратора.
+type MeasurementRescheduleRequest struct {
+	StartsAt   time.Time `json:"startsAt" validate:"required"`
+	MeasurerID *int64    `json:"measurerId"`
+	Actor      string    `json:"actor" validate:"max=100"`
+}
+
+type MeasurementCancelRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+	Actor  string `json:"actor" validate:"max=100"`
+}
+
+// LeadMeasurementRequest — запись на замер по существующей заявке из админки
+type LeadMeasurementRequest struct {
+	StartsAt   time.Time `json:"startsAt" validate:"required"`
+	MeasurerID *int64    `json:"measurerId"`
+	Actor      string    `json:"actor" validate:"max=100"`
+}
+
+type BookingFilter struct {
+	Status     BookingStatus
+	MeasurerID *int64
+	LeadID     *int64
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// measurementCalendar — замерщики и их записи за период: по нему
+// считаются свободные слоты
+type measurementCalendar struct {
+	settings  MeasurementSettings
+	measurers []Measurer
+	bookings  []MeasurementBooking
+}
+
+// workingDay — рабочие часы замерщика в день day; ok=false, если он не работает
+func (c measurementCalendar) workingDay(m Measurer, day time.Time) (start, end time.Time, ok bool) {
+	loc := c.settings.location()
+	day = day.In(loc)
+	if !hasWeekday(m.WorkDays, day.Weekday()) {
+		return start, end, false
+	}
+	key := day.Format("2006-01-02")
+	for _, off := range m.DaysOff {
+		if off.Day == key {
+			return start, end, false
+		}
+	}
+	y, mo, d := day.Date()
+	return time.Date(y, mo, d, m.WorkStart, 0, 0, 0, loc), time.Date(y, mo, d, m.WorkEnd, 0, 0, 0, loc), true
+}
+
+func (c measurementCalendar) step() time.Duration {
+	return c.settings.slot() + c.settings.buffer()
+}
+
+// fits — попадает ли слот в сетку рабочего дня замерщика
+func (c measurementCalendar) fits(m Measurer, start time.Time) bool {
+	dayStart, dayEnd, ok := c.workingDay(m, start)
+	if !ok || start.Before(dayStart) || start.Add(c.settings.slot()).After(dayEnd) {
+		return false
+	}
+	return start.Sub(dayStart)%c.step() == 0
+}
+
+// busy — пересекается ли слот с записями замерщика с учетом дороги.
+// exclude — переносимая запись.
+func (c measurementCalendar) busy(measurerID int64, start time.Time, exclude int64) bool {
+	end := start.Add(c.settings.slot())
+	buffer := c.settings.buffer()
+	for _, b := range c.bookings {
+		if b.MeasurerID != measurerID || b.ID == exclude {
+			continue
+		}
+		if start.Before(b.EndsAt.Add(buffer)) && b.StartsAt.Before(end.Add(buffer)) {
+			return true
+		}
+	}
+	return false
+}
+
+// freeMeasurers — замерщики, свободные в слот start; первыми идут те,
+// у кого меньше записей в этот день
+func (c measurementCalendar) freeMeasurers(start time.Time, exclude int64) []Measurer {
+	loc := c.settings.location()
+	day := start.In(loc).Format("2006-01-02")
+	load := map[int64]int{}
+	for _, b := range c.bookings {
+		if b.ID != exclude && b.StartsAt.In(loc).Format("2006-01-02") == day {
+			load[b.MeasurerID]++
+		}
+	}
+
+	free := []Measurer{}
+	for _, m := range c.measurers {
+		if c.fits(m, start) && !c.busy(m.ID, start, exclude) {
+			free = append(free, m)
+		}
+	}
+	sort.SliceStable(free, func(i, j int) bool { return load[free[i].ID] < load[free[j].ID] })
+	return free
+}
+
+// slots — свободные слоты, начинающиеся с from до to и не раньше earliest
+func (c measurementCalendar) slots(from, to, earliest time.Time) []MeasurementSlot {
+	loc := c.settings.location()
+	available := map[int64]int{}
+	y, m, d := from.In(loc).Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, ms := range c.measurers {
+			dayStart, dayEnd, ok := c.workingDay(ms, day)
+			if !ok {
+				continue
+			}
+			for t := dayStart; !t.Add(c.settings.slot()).After(dayEnd); t = t.Add(c.step()) {
+				if t.Before(from) || t.Before(earliest) || !t.Before(to) || c.busy(ms.ID, t, 0) {
+					continue
+				}
+				available[t.Unix()]++
+			}
+		}
+	}
+
+	slots := make([]MeasurementSlot, 0, len(available))
+	for unix, n := range available {
+		start := time.Unix(unix, 0).In(loc)
+		slots = append(slots, MeasurementSlot{StartsAt: start, EndsAt: start.Add(c.settings.slot()), Available: n})
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].StartsAt.Before(slots[j].StartsAt) })
+	return slots
+}
+
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Settings
+func (s *DatabaseStore) getMeasurementSettings() (MeasurementSettings, error) {
+	var settings MeasurementSettings
+	err := s.db.QueryRow(`
+		SELECT slot_minutes, travel_buffer_minutes, min_notice_hours, horizon_days, timezone
+		FROM measurement_settings
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&settings.SlotMinutes, &settings.TravelBufferMinutes, &settings.MinNoticeHours,
+		&settings.HorizonDays, &settings.Timezone)
+	if err == sql.ErrNoRows {
+		return defaultMeasurementSettings, nil
+	}
+	return settings, err
+}
+
+func (s *DatabaseStore) updateMeasurementSettings(settings MeasurementSettings) error {
+	_, err := s.db.Exec(`
+		INSERT INTO measurement_settings (slot_minutes, travel_buffer_minutes, min_notice_hours, horizon_days, timezone)
+		VALUES ($1, $2, $3, $4, $5)
+	`, settings.SlotMinutes, settings.TravelBufferMinutes, settings.MinNoticeHours, settings.HorizonDays, settings.Timezone)
+	return err
+}
+
+// Measurers
+const measurerColumns = `id, name, phone, telegram_chat_id, is_active, work_days, work_start, work_end`
+
+func scanMeasurer(row interface{ Scan(...any) error }) (Measurer, error) {
+	var (
+		m    Measurer
+		days pq.Int64Array
+	)
+	err := row.Scan(&m.ID, &m.Name, &m.Phone, &m.TelegramChatID, &m.IsActive, &days, &m.WorkStart, &m.WorkEnd)
+	for _, d := range days {
+		m.WorkDays = append(m.WorkDays, int(d))
+	}
+	m.DaysOff = []MeasurerDayOff{}
+	return m, err
+}
+
+func workDaysArray(days []int) pq.Int64Array {
+	arr := make(pq.Int64Array, 0, len(days))
+	for _, d := range days {
+		arr = append(arr, int64(d))
+	}
+	return arr
+}
+
+// getMeasurers возвращает замерщиков с выходными начиная с дня since
+func getMeasurers(q querier, activeOnly bool, since time.Time) ([]Measurer, error) {
+	query := "SELECT " + measurerColumns + " FROM measurers"
+	if activeOnly {
+		query += " WHERE is_active"
+	}
+	rows, err := q.Query(query + " ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	measurers := []Measurer{}
+	index := map[int64]int{}
+	for rows.Next() {
+		m, err := scanMeasurer(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[m.ID] = len(measurers)
+		measurers = append(measurers, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	offRows, err := q.Query(`
+		SELECT measurer_id, day, comment FROM measurer_days_off
+		WHERE day >= $1::DATE
+		ORDER BY day
+	`, since.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer offRows.Close()
+	for offRows.Next() {
+		var (
+			id  int64
+			day time.Time
+			off MeasurerDayOff
+		)
+		if err := offRows.Scan(&id, &day, &off.Comment); err != nil {
+			return nil, err
+		}
+		if i, ok := index[id]; ok {
+			off.Day = day.Format("2006-01-02")
+			measurers[i].DaysOff = append(measurers[i].DaysOff, off)
+		}
+	}
+	return measurers, offRows.Err()
+}
+
+func (s *DatabaseStore) findMeasurer(id int64) (*Measurer, error) {
+	m, err := scanMeasurer(s.db.QueryRow("SELECT "+measurerColumns+" FROM measurers WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *DatabaseStore) addMeasurer(m Measurer) (Measurer, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO measurers (name, phone, telegram_chat_id, is_active, work_days, work_start, work_end)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, m.Name, m.Phone, m.TelegramChatID, m.IsActive, workDaysArray(m.WorkDays), m.WorkStart, m.WorkEnd).Scan(&m.ID)
+	m.DaysOff = []MeasurerDayOff{}
+	return m, err
+}
+
+// updateMeasurer меняет карточку и рабочую неделю. Уже сделанные записи
+// не переносятся, даже если выпали из нового графика.
+func (s *DatabaseStore) updateMeasurer(m Measurer) (*Measurer, error) {
+	res, err := s.db.Exec(`
+		UPDATE measurers
+		SET name = $2, phone = $3, telegram_chat_id = $4, is_active = $5, work_days = $6, work_start = $7,
+		    work_end = $8, updated_at = NOW()
+		WHERE id = $1
+	`, m.ID, m.Name, m.Phone, m.TelegramChatID, m.IsActive, workDaysArray(m.WorkDays), m.WorkStart, m.WorkEnd)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return s.findMeasurer(m.ID)
+}
+
+// deleteMeasurer удаляет замерщика без записей; с записями его можно
+// только отключить, чтобы не потерять историю
+func (s *DatabaseStore) deleteMeasurer(id int64) error {
+	var hasBookings bool
+	err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM measurement_bookings WHERE measurer_id = $1)", id).Scan(&hasBookings)
+	if err != nil {
+		return err
+	}
+	if hasBookings {
+		return errMeasurerHasBookings
+	}
+	_, err = s.db.Exec("DELETE FROM measurers WHERE id = $1", id)
+	return err
+}
+
+func (s *DatabaseStore) setMeasurerDayOff(id int64, day time.Time, comment string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO measurer_days_off (measurer_id, day, comment)
+		VALUES ($1, $2::DATE, $3)
+		ON CONFLICT (measurer_id, day) DO UPDATE SET comment = EXCLUDED.comment
+	`, id, day.Format("2006-01-02"), comment)
+	return err
+}
+
+func (s *DatabaseStore) deleteMeasurerDayOff(id int64, day time.Time) error {
+	_, err := s.db.Exec("DELETE FROM measurer_days_off WHERE measurer_id = $1 AND day = $2::DATE", id, day.Format("2006-01-02"))
+	return err
+}
+
+// Bookings
+const bookingColumns = `b.id, b.token, b.measurer_id, ms.name, b.lead_id, l.name, l.phone, l.address,
+	b.starts_at, b.ends_at, b.status, b.cancel_reason, b.created_at, b.updated_at`
+
+const bookingTables = `measurement_bookings b
+	JOIN measurers ms ON ms.id = b.measurer_id
+	JOIN leads l ON l.id = b.lead_id`
+
+func scanBooking(row interface{ Scan(...any) error }) (MeasurementBooking, error) {
+	var b MeasurementBooking
+	err := row.Scan(&b.ID, &b.Token, &b.MeasurerID, &b.MeasurerName, &b.LeadID, &b.Name, &b.Phone, &b.Address,
+		&b.StartsAt, &b.EndsAt, &b.Status, &b.CancelReason, &b.CreatedAt, &b.UpdatedAt)
+	return b, err
+}
+
+// loadMeasurementCalendar читает активных замерщиков и действующие записи
+// с запасом в сутки вокруг периода
+func loadMeasurementCalendar(q querier, settings MeasurementSettings, from, to time.Time) (measurementCalendar, error) {
+	cal := measurementCalendar{settings: settings}
+	var err error
+	if cal.measurers, err = getMeasurers(q, true, from.AddDate(0, 0, -1)); err != nil {
+		return cal, err
+	}
+
+	rows, err := q.Query(`
+		SELECT `+bookingColumns+` FROM `+bookingTables+`
+		WHERE b.status = 'booked' AND b.starts_at < $2 AND b.ends_at > $1
+	`, from.AddDate(0, 0, -1), to.AddDate(0, 0, 1))
+	if err != nil {
+		return cal, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		b, err := scanBooking(rows)
+		if err != nil {
+			return cal, err
+		}
+		cal.bookings = append(cal.bookings, b)
+	}
+	return cal, rows.Err()
+}
+
+func (s *DatabaseStore) getMeasurementSlots(settings MeasurementSettings, from, to, earliest time.Time) ([]MeasurementSlot, error) {
+	cal, err := loadMeasurementCalendar(s.db, settings, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return cal.slots(from, to, earliest), nil
+}
+
+// reserveSlotTx берет блокировку записи и выбирает замерщика, свободного
+// в слот start: указанного measurerID или наименее загруженного в этот день
+func reserveSlotTx(tx *sql.Tx, settings MeasurementSettings, start time.Time, measurerID *int64, exclude int64) (int64, error) {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", measurementBookingLock); err != nil {
+		return 0, err
+	}
+	cal, err := loadMeasurementCalendar(tx, settings, start, start.Add(settings.slot()))
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range cal.freeMeasurers(start, exclude) {
+		if measurerID == nil || m.ID == *measurerID {
+			return m.ID, nil
+		}
+	}
+	return 0, errSlotUnavailable
+}
+
+// insertBookingTx записывает заявку на слот и переводит ее в
+// measurement_scheduled, если это разрешено из текущего статуса
+func insertBookingTx(tx *sql.Tx, settings MeasurementSettings, leadID int64, start time.Time, measurerID *int64, actor string) (int64, error) {
+	mid, err := reserveSlotTx(tx, settings, start, measurerID, 0)
+	if err != nil {
+		return 0, err
+	}
+	token, err := newQuoteToken()
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = tx.QueryRow(`
+		INSERT INTO measurement_bookings (token, measurer_id, lead_id, starts_at, ends_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, token, mid, leadID, start, start.Add(settings.slot())).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	comment := "Замер " + start.In(settings.location()).Format("02.01.2006 15:04")
+	return id, advanceLeadTx(tx, &leadID, LeadStatusMeasurementScheduled, actor, comment)
+}
+
+// bookNewLeadMeasurement создает заявку и запись на замер в одной
+// транзакции: если слот заняли, заявка тоже не сохраняется
+func (s *DatabaseStore) bookNewLeadMeasurement(l Lead, start time.Time) (MeasurementBooking, Lead, error) {
+	leadSettings, err := s.getLeadSettings()
+	if err != nil {
+		return MeasurementBooking{}, l, err
+	}
+	settings, err := s.getMeasurementSettings()
+	if err != nil {
+		return MeasurementBooking{}, l, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return MeasurementBooking{}, l, err
+	}
+	defer tx.Rollback()
+
+	if l, err = addLeadTx(tx, leadSettings, l); err != nil {
+		return MeasurementBooking{}, l, err
+	}
+	id, err := insertBookingTx(tx, settings, l.ID, start, nil, l.Source)
+	if err != nil {
+		return MeasurementBooking{}, l, err
+	}
+	if err := tx.Commit(); err != nil {
+		return MeasurementBooking{}, l, err
+	}
+	b, err := s.findBooking("b.id = $1", id)
+	if err != nil || b == nil {
+		return MeasurementBooking{}, l, err
+	}
+	return *b, l, nil
+}
+
+// bookLeadMeasurement записывает на замер существующую заявку.
+// Возвращает errLeadNotFound, если заявки нет.
+func (s *DatabaseStore) bookLeadMeasurement(leadID int64, start time.Time, measurerID *int64, actor string) (*MeasurementBooking, error) {
+	settings, err := s.getMeasurementSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM leads WHERE id = $1)", leadID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errLeadNotFound
+	}
+	id, err := insertBookingTx(tx, settings, leadID, start, measurerID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.findBooking("b.id = $1", id)
+}
+
+// findBooking возвращает запись по условию cond с одним параметром или nil
+func (s *DatabaseStore) findBooking(cond string, arg any) (*MeasurementBooking, error) {
+	b, err := scanBooking(s.db.QueryRow("SELECT "+bookingColumns+" FROM "+bookingTables+" WHERE "+cond, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// rescheduleBooking переносит запись на слот start. Возвращает nil, если
+// записи нет.
+func (s *DatabaseStore) rescheduleBooking(token string, start time.Time, measurerID *int64) (*MeasurementBooking, error) {
+	settings, err := s.getMeasurementSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", measurementBookingLock); err != nil {
+		return nil, err
+	}
+	var (
+		id     int64
+		status BookingStatus
+	)
+	err = tx.QueryRow("SELECT id, status FROM measurement_bookings WHERE token = $1 FOR UPDATE", token).Scan(&id, &status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != BookingBooked {
+		return nil, errBookingCancelled
+	}
+	mid, err := reserveSlotTx(tx, settings, start, measurerID, id)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE measurement_bookings
+		SET measurer_id = $2, starts_at = $3, ends_at = $4, updated_at = NOW()
+		WHERE id = $1
+	`, id, mid, start, start.Add(settings.slot()))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.findBooking("b.id = $1", id)
+}
+
+// cancelBooking отменяет запись. Если других записей у заявки нет, она
+// возвращается в contacted: менеджер договорится о новом времени.
+func (s *DatabaseStore) cancelBooking(token, reason, actor string) (*MeasurementBooking, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		id, leadID int64
+		status     BookingStatus
+	)
+	err = tx.QueryRow("SELECT id, lead_id, status FROM measurement_bookings WHERE token = $1 FOR UPDATE", token).
+		Scan(&id, &leadID, &status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != BookingBooked {
+		return nil, errBookingCancelled
+	}
+	_, err = tx.Exec(`
+		UPDATE measurement_bookings SET status = 'cancelled', cancel_reason = $2, updated_at = NOW()
+		WHERE id = $1
+	`, id, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	var hasOther bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM measurement_bookings WHERE lead_id = $1 AND status = 'booked')
+	`, leadID).Scan(&hasOther)
+	if err != nil {
+		return nil, err
+	}
+	if !hasOther {
+		comment := "Замер отменен"
+		if reason != "" {
+			comment += ": " + reason
+		}
+		if err := advanceLeadTx(tx, &leadID, LeadStatusContacted, actor, comment); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.findBooking("b.id = $1", id)
+}
+
+func (s *DatabaseStore) getBookings(f BookingFilter) ([]MeasurementBooking, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Status != "" {
+		add("b.status = $%d", f.Status)
+	}
+	if f.MeasurerID != nil {
+		add("b.measurer_id = $%d", *f.MeasurerID)
+	}
+	if f.LeadID != nil {
+		add("b.lead_id = $%d", *f.LeadID)
+	}
+	if f.From != nil {
+		add("b.starts_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("b.starts_at < $%d", *f.To)
+	}
+
+	query := "SELECT " + bookingColumns + " FROM " + bookingTables
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" ORDER BY b.starts_at, b.id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []MeasurementBooking{}
+	for rows.Next() {
+		b, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, b.withDerived())
+	}
+	return bookings, rows.Err()
+}
+
+func parseBookingFilter(q map[string][]string) (BookingFilter, error) {
+	get := func(key string) string {
+		if v := q[key]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	f := BookingFilter{Limit: 50}
+
+	switch st := BookingStatus(get("status")); st {
+	case "", BookingBooked, BookingCancelled:
+		f.Status = st
+	default:
+		return f, errors.New("invalid status")
+	}
+	for key, dst := range map[string]**int64{"measurerId": &f.MeasurerID, "leadId": &f.LeadID} {
+		if v := get(key); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return f, errors.New("invalid " + key)
+			}
+			*dst = &id
+		}
+	}
+	if v := get("from"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("invalid from date")
+		}
+		f.From = &d
+	}
+	if v := get("to"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("invalid to date")
+		}
+		d = d.AddDate(0, 0, 1)
+		f.To = &d
+	}
+	if v := get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			return f, errors.New("invalid limit")
+		}
+		f.Limit = n
+	}
+	if v := get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, errors.New("invalid offset")
+		}
+		f.Offset = n
+	}
+	return f, nil
+}
+
+func bookingMessage(title string, b MeasurementBooking, loc *time.Location) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s, заявка №%d\n", title, b.LeadID)
+	fmt.Fprintf(&sb, "Время: %s–%s\n", b.StartsAt.In(loc).Format("02.01.2006 15:04"), b.EndsAt.In(loc).Format("15:04"))
+	fmt.Fprintf(&sb, "Замерщик: %s\n", b.MeasurerName)
+	fmt.Fprintf(&sb, "Клиент: %s, %s\n", b.Name, formatPhone(b.Phone))
+	if b.Address != "" {
+		fmt.Fprintf(&sb, "Адрес: %s\n", b.Address)
+	}
+	if b.CancelReason != "" {
+		fmt.Fprintf(&sb, "Причина: %s\n", b.CancelReason)
+	}
+	return sb.String()
+}
+
+// notifyBooking сообщает о записи подписчикам бота и замерщику
+func (a *App) notifyBooking(title string, b MeasurementBooking) {
+	settings, err := a.Storage.getMeasurementSettings()
+	if err != nil {
+		settings = defaultMeasurementSettings
+	}
+	text := bookingMessage(title, b, settings.location())
+	go func() {
+		if err := a.notifyTelegram(text); err != nil && !errors.Is(err, errTelegramDisabled) {
+			log.Printf("booking %d: telegram notification failed: %v", b.ID, err)
+		}
+		if a.Config.TelegramBotToken == "" {
+			return
+		}
+		m, err := a.Storage.findMeasurer(b.MeasurerID)
+		if err == nil && m != nil && m.TelegramChatID != nil {
+			err = sendTelegramMessage(a.Config.TelegramBotToken, *m.TelegramChatID, text)
+		}
+		if err != nil {
+			log.Printf("booking %d: measurer notification failed: %v", b.ID, err)
+		}
+	}()
+}
+
+func writeBookingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errSlotOutOfWindow), errors.Is(err, errLeadNotFound):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, errSlotUnavailable), errors.Is(err, errBookingCancelled):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+	}
+}
+
+// checkBookingWindow проверяет, что клиент может сам записаться на start
+func (a *App) checkBookingWindow(start time.Time) error {
+	settings, err := a.Storage.getMeasurementSettings()
+	if err != nil {
+		return err
+	}
+	earliest, latest := settings.window(time.Now())
+	if start.Before(earliest) || !start.Before(latest) {
+		return errSlotOutOfWindow
+	}
+	return nil
+}
+
+// Handlers
+
+// handleMeasurementSlots — GET /api/measurement/slots?from=&to=. Даты — в
+// часовом поясе настроек, to включительно; по умолчанию — весь горизонт записи.
+func (a *App) handleMeasurementSlots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings, err := a.Storage.getMeasurementSettings()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		loc := settings.location()
+		earliest, latest := settings.window(time.Now())
+
+		q := r.URL.Query()
+		from, to := earliest, latest
+		if v := q.Get("from"); v != "" {
+			d, err := time.ParseInLocation("2006-01-02", v, loc)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid from date"})
+				return
+			}
+			from = d
+		}
+		if v := q.Get("to"); v != "" {
+			d, err := time.ParseInLocation("2006-01-02", v, loc)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid to date"})
+				return
+			}
+			to = d.AddDate(0, 0, 1)
+		}
+		if to.After(latest) {
+			to = latest
+		}
+
+		slots := []MeasurementSlot{}
+		if from.Before(to) {
+			if slots, err = a.Storage.getMeasurementSlots(settings, from, to, earliest); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, MeasurementSlotsResponse{
+			SlotMinutes: settings.SlotMinutes,
+			Timezone:    settings.Timezone,
+			Slots:       slots,
+		})
+	}
+}
+
+// handleCreateMeasurementBooking — запись на замер с сайта: создает заявку
+// вида measure и бронирует слот. Подозрительная отправка уходит в карантин
+// обычной заявкой с желаемым временем в комментарии.
+func (a *App) handleCreateMeasurementBooking() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in MeasurementBookingRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil || strings.TrimSpace(in.Name) == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		in.Kind = LeadKindMeasure
+		if err := a.checkBookingWindow(in.StartsAt); err != nil {
+			writeBookingError(w, err)
+			return
+		}
+
+		lead, err := a.buildLead(in.LeadRequest)
+		switch {
+		case errors.Is(err, errInvalidPhone):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid phone"})
+			return
+		case errors.Is(err, errQuoteNotFound), errors.Is(err, errMaterialNotFound),
+			errors.Is(err, errUnknownRepairService), errors.Is(err, errRepairNotAvailable):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+
+		sub := FormSubmission{
+			Form:      formLead,
+			IP:        a.clientIP(r),
+			Phone:     lead.Phone,
+			Text:      strings.Join([]string{in.Name, in.Email, lead.Message}, "\n"),
+			Honeypot:  in.Website,
+			FormToken: in.FormToken,
+		}
+		reasons, err := a.screenSubmission(sub)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if len(reasons) > 0 {
+			payload := in.LeadRequest
+			payload.Comment = strings.TrimSpace(payload.Comment + "\nЖелаемое время замера: " +
+				in.StartsAt.Format("02.01.2006 15:04 -07:00"))
+			a.quarantineSubmission(w, sub, reasons, payload)
+			return
+		}
+
+		booking, created, err := a.Storage.bookNewLeadMeasurement(lead, in.StartsAt)
+		if err != nil {
+			writeBookingError(w, err)
+			return
+		}
+		a.notifyLead(created)
+		a.notifyBooking("Запись на замер", booking)
+		writeJSON(w, http.StatusCreated, booking.withDerived())
+	}
+}
+
+// handleGetMeasurementBooking — запись по токену из ссылки клиента
+func (a *App) handleGetMeasurementBooking() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := a.Storage.findBooking("b.token = $1", chi.URLParam(r, "token"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if b == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "booking not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, b.withDerived())
+	}
+}
+
+// handleRescheduleMeasurementBooking переносит запись по токену. Клиент
+// выбирает слот в пределах окна записи; администратор — любой слот сетки
+// и может назначить другого замерщика.
+func (a *App) handleRescheduleMeasurementBooking() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in MeasurementRescheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		if !a.isAdmin(r) {
+			in.MeasurerID = nil
+			if err := a.checkBookingWindow(in.StartsAt); err != nil {
+				writeBookingError(w, err)
+				return
+			}
+		}
+
+		b, err := a.Storage.rescheduleBooking(chi.URLParam(r, "token"), in.StartsAt, in.MeasurerID)
+		if err != nil {
+			writeBookingError(w, err)
+			return
+		}
+		if b == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "booking not found"})
+			return
+		}
+		a.notifyBooking("Замер перенесен", *b)
+		writeJSON(w, http.StatusOK, b.withDerived())
+	}
+}
+
+// handleCancelMeasurementBooking отменяет запись по токену
+func (a *App) handleCancelMeasurementBooking() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in MeasurementCancelRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		actor := "client"
+		if a.isAdmin(r) {
+			actor = strings.TrimSpace(in.Actor)
+			if actor == "" {
+				actor = "admin"
+			}
+		}
+
+		b, err := a.Storage.cancelBooking(chi.URLParam(r, "token"), strings.TrimSpace(in.Reason), actor)
+		if err != nil {
+			writeBookingError(w, err)
+			return
+		}
+		if b == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "booking not found"})
+			return
+		}
+		a.notifyBooking("Замер отменен", *b)
+		writeJSON(w, http.StatusOK, b.withDerived())
+	}
+}
+
+func (a *App) handleListMeasurementBookings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseBookingFilter(r.URL.Query())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		bookings, err := a.Storage.getBookings(filter)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, bookings)
+	}
+}
+
+// handleBookLeadMeasurement — запись на замер по заявке из админки, например
+// после звонка. Окно записи не проверяется, сетка слотов — да.
+func (a *App) handleBookLeadMeasurement() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid lead ID"})
+			return
+		}
+
+		var in LeadMeasurementRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		actor := strings.TrimSpace(in.Actor)
+		if actor == "" {
+			actor = "admin"
+		}
+
+		b, err := a.Storage.bookLeadMeasurement(id, in.StartsAt, in.MeasurerID, actor)
+		if errors.Is(err, errLeadNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "lead not found"})
+			return
+		}
+		if err != nil {
+			writeBookingError(w, err)
+			return
+		}
+		a.notifyBooking("Запись на замер", *b)
+		writeJSON(w, http.StatusCreated, b.withDerived())
+	}
+}
+
+func (a *App) handleGetMeasurementSettings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings, err := a.Storage.getMeasurementSettings()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+	}
+}
+
+// handleUpdateMeasurementSettings сохраняет настройки. Сделанные записи
+// остаются как есть; новая сетка действует для новых записей и переносов.
+func (a *App) handleUpdateMeasurementSettings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var settings MeasurementSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(settings); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid timezone"})
+			return
+		}
+
+		if err := a.Storage.updateMeasurementSettings(settings); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+	}
+}
+
+func (a *App) handleGetMeasurers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		measurers, err := getMeasurers(a.Storage.db, false, time.Now())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, measurers)
+	}
+}
+
+func (a *App) handleCreateMeasurer() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := Measurer{IsActive: true}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(m); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		created, err := a.Storage.addMeasurer(m)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func (a *App) handleUpdateMeasurer() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid measurer ID"})
+			return
+		}
+
+		var m Measurer
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(m); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		m.ID = id
+		updated, err := a.Storage.updateMeasurer(m)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if updated == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "measurer not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func (a *App) handleDeleteMeasurer() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid measurer ID"})
+			return
+		}
+
+		err = a.Storage.deleteMeasurer(id)
+		if errors.Is(err, errMeasurerHasBookings) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// measurerDayParams разбирает {id} и {day} из пути
+func measurerDayParams(w http.ResponseWriter, r *http.Request) (int64, time.Time, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid measurer ID"})
+		return 0, time.Time{}, false
+	}
+	day, err := time.Parse("2006-01-02", chi.URLParam(r, "day"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid day"})
+		return 0, time.Time{}, false
+	}
+	return id, day, true
+}
+
+// handleSetMeasurerDayOff — PUT /api/measurers/{id}/days-off/{day}. Записи
+// на этот день не отменяются: их нужно перенести вручную.
+func (a *App) handleSetMeasurerDayOff() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, day, ok := measurerDayParams(w, r)
+		if !ok {
+			return
+		}
+
+		var in MeasurerDayOff
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+				return
+			}
+		}
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		m, err := a.Storage.findMeasurer(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if m == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "measurer not found"})
+			return
+		}
+		if err := a.Storage.setMeasurerDayOff(id, day, strings.TrimSpace(in.Comment)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, MeasurerDayOff{Day: day.Format("2006-01-02"), Comment: strings.TrimSpace(in.Comment)})
+	}
+}
+
+func (a *App) handleDeleteMeasurerDayOff() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, day, ok := measurerDayParams(w, r)
+		if !ok {
+			return
+		}
+		if err := a.Storage.deleteMeasurerDayOff(id, day); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
@@ -0,0 +1,19 @@
+-- Saved quotes (коммерческие расчёты) with share tokens
+
+CREATE TABLE IF NOT EXISTS quotes (
+    id BIGSERIAL PRIMARY KEY,
+    token VARCHAR(32) NOT NULL UNIQUE,
+    request JSONB NOT NULL,
+    items JSONB NOT NULL,
+    pricing_config JSONB NOT NULL,
+    total DECIMAL(12,2) NOT NULL CHECK (total >= 0),
+    currency VARCHAR(8) NOT NULL DEFAULT '₽',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_quotes_created_at ON quotes(created_at DESC);
+
+-- Акции, действовавшие на момент расчета: коммерческое предложение печатает их,
+-- а не текущие
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS promotions JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,47 @@
+-- Repair services catalog with per-product-type pricing
+
+CREATE TABLE IF NOT EXISTS repair_services (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    unit VARCHAR(20) NOT NULL DEFAULT 'шт',
+    is_call_out BOOLEAN NOT NULL DEFAULT false,
+    is_active BOOLEAN DEFAULT true,
+    order_index INTEGER DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS repair_service_prices (
+    service_id BIGINT NOT NULL REFERENCES repair_services(id) ON DELETE CASCADE,
+    product_type VARCHAR(20) NOT NULL,
+    price DECIMAL(10,2) NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (service_id, product_type)
+);
+
+-- Стартовый каталог только для пустой таблицы: миграция выполняется при каждом
+-- запуске и не должна возвращать услуги, удаленные администратором.
+INSERT INTO repair_services (code, name, description, unit, is_call_out, order_index)
+SELECT * FROM (VALUES
+    ('call-out', 'Выезд мастера', 'Диагностика на месте, входит в любой ремонт', 'выезд', true, 0),
+    ('cord', 'Замена шнура', 'Замена подъемного или поворотного шнура', 'шт', false, 1),
+    ('slat', 'Замена ламели', 'Замена поврежденной ламели на аналогичную', 'шт', false, 2),
+    ('control', 'Замена механизма управления', 'Замена механизма поворота или подъема', 'шт', false, 3),
+    ('chain', 'Замена цепочки управления', 'Замена цепочки и фиксаторов', 'шт', false, 4)
+) AS v(code, name, description, unit, is_call_out, order_index)
+WHERE NOT EXISTS (SELECT 1 FROM repair_services)
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO repair_service_prices (service_id, product_type, price)
+SELECT s.id, p.product_type, p.price
+FROM repair_services s
+JOIN (VALUES
+    ('call-out', 'horizontal', 700.00), ('call-out', 'vertical', 700.00), ('call-out', 'roller', 700.00),
+    ('cord', 'horizontal', 450.00), ('cord', 'vertical', 500.00),
+    ('slat', 'horizontal', 150.00), ('slat', 'vertical', 250.00),
+    ('control', 'horizontal', 900.00), ('control', 'vertical', 1200.00), ('control', 'roller', 1100.00),
+    ('chain', 'vertical', 400.00), ('chain', 'roller', 400.00)
+) AS p(code, product_type, price) ON p.code = s.code
+WHERE NOT EXISTS (SELECT 1 FROM repair_service_prices)
+ON CONFLICT (service_id, product_type) DO NOTHING;
@@ -0,0 +1,35 @@
+-- Service zones with travel surcharges for measurement and installation
+
+CREATE TABLE IF NOT EXISTS service_zones (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    districts TEXT[] NOT NULL DEFAULT '{}',
+    postcode_prefixes TEXT[] NOT NULL DEFAULT '{}',
+    polygon JSONB,
+    measurement_fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (measurement_fee >= 0),
+    installation_fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (installation_fee >= 0),
+    out_of_area BOOLEAN NOT NULL DEFAULT false,
+    priority INTEGER NOT NULL DEFAULT 100,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_zones_active ON service_zones(priority) WHERE is_active = true;
+
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS zone VARCHAR(100);
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS out_of_area BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS surcharges JSONB NOT NULL DEFAULT '[]';
+
+-- Стартовые зоны только для пустой таблицы: миграция выполняется при каждом
+-- запуске и не должна возвращать зоны, удаленные администратором.
+INSERT INTO service_zones (name, districts, postcode_prefixes, measurement_fee, installation_fee, out_of_area, priority)
+SELECT * FROM (VALUES
+    ('Пригороды', ARRAY['Пушкин', 'Павловск', 'Всеволожск', 'Колпино', 'Петергоф', 'Ломоносов', 'Кронштадт', 'Сестрорецк', 'Зеленогорск', 'Мурино', 'Кудрово', 'Гатчина'], ARRAY['1966', '1985', '1984', '1977', '1886', '1883'], 500.00, 1000.00, false, 10),
+    ('Выборг и дальняя область', ARRAY['Выборг', 'Приозерск', 'Кингисепп', 'Луга', 'Тихвин', 'Кириши', 'Подпорожье'], ARRAY['1888', '1887', '1884', '1882', '1875', '1871'], 1500.00, 2500.00, true, 20),
+    ('Центр', ARRAY['Центральный район', 'Адмиралтейский район', 'Василеостровский район', 'Петроградский район'], ARRAY['191', '190', '199', '1971'], 0, 0, false, 30),
+    ('Санкт-Петербург', ARRAY['Санкт-Петербург', 'СПб'], ARRAY['19'], 0, 300.00, false, 40),
+    ('Ленинградская область', ARRAY['Ленинградская область', 'ЛО'], ARRAY['18'], 800.00, 1500.00, false, 50)
+) AS v(name, districts, postcode_prefixes, measurement_fee, installation_fee, out_of_area, priority)
+WHERE NOT EXISTS (SELECT 1 FROM service_zones)
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,14 @@
+-- Material price changes: admin edits, bulk adjustments and supplier imports
+
+CREATE TABLE IF NOT EXISTS material_price_history (
+    id BIGSERIAL PRIMARY KEY,
+    material_id BIGINT NOT NULL REFERENCES materials(id) ON DELETE CASCADE,
+    old_price DECIMAL(10,2) NOT NULL,
+    new_price DECIMAL(10,2) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    note TEXT,
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_material_price_history_material ON material_price_history(material_id, changed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_material_price_history_changed ON material_price_history(changed_at);
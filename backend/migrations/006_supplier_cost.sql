@@ -0,0 +1,17 @@
+-- Supplier cost separated from retail price, per-category markup policy
+
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS supplier_cost DECIMAL(10,2) CHECK (supplier_cost > 0);
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS price_override BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS category_markups (
+    category VARCHAR(100) PRIMARY KEY,
+    markup DECIMAL(6,4) NOT NULL CHECK (markup >= 0),
+    round_to DECIMAL(10,2) NOT NULL DEFAULT 10.00 CHECK (round_to > 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO category_markups (category, markup, round_to) VALUES
+('Горизонтальные жалюзи', 1.0000, 10.00),
+('Вертикальные жалюзи', 1.0000, 10.00),
+('Рулонные шторы', 1.0000, 10.00)
+ON CONFLICT (category) DO NOTHING;
@@ -0,0 +1,86 @@
+-- Attribute dictionary: colors, slat widths and fabric types with synonyms
+
+CREATE TABLE IF NOT EXISTS attribute_values (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('color', 'slat_width', 'fabric_type')),
+    code VARCHAR(50) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    hex VARCHAR(7) NOT NULL DEFAULT '' CHECK (hex = '' OR hex ~ '^#[0-9A-Fa-f]{6}$'),
+    family VARCHAR(50) NOT NULL DEFAULT '',
+    number DECIMAL(10,2) CHECK (number >= 0),
+    synonyms TEXT[] NOT NULL DEFAULT '{}',
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (kind, code)
+);
+
+CREATE INDEX IF NOT EXISTS idx_attribute_values_kind ON attribute_values(kind, sort_order);
+
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS color_family VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS slat_width_mm INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS fabric_type VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_materials_color_family ON materials(color_family);
+
+-- Словарь заполняется только для вида без значений: миграция выполняется при
+-- каждом запуске и не должна возвращать значения, удаленные администратором.
+
+-- Синоним со звездочкой на конце совпадает с началом слова: "бел*" — белый, белая, бело-серый.
+-- Цвет, у которого code совпадает с family, задает название и образец цветовой семьи.
+INSERT INTO attribute_values (kind, code, name, hex, family, synonyms, sort_order)
+SELECT * FROM (VALUES
+    ('color', 'white', 'Белый', '#FFFFFF', 'white', ARRAY['бел*', 'white'], 10),
+    ('color', 'milky', 'Молочный', '#FBF6EA', 'white', ARRAY['молочн*', 'слоновая кость', 'ivory', 'milk'], 11),
+    ('color', 'beige', 'Бежевый', '#D9C6A5', 'beige', ARRAY['бежев*', 'beige'], 20),
+    ('color', 'cream', 'Кремовый', '#F3E5C0', 'beige', ARRAY['кремов*', 'cream'], 21),
+    ('color', 'sand', 'Песочный', '#D8C08F', 'beige', ARRAY['песочн*', 'sand'], 22),
+    ('color', 'gray', 'Серый', '#9E9E9E', 'gray', ARRAY['серый', 'серая', 'серое', 'серые', 'серого', 'серой', 'серо', 'gray', 'grey'], 30),
+    ('color', 'graphite', 'Графит', '#4A4A4A', 'gray', ARRAY['графит*', 'антрацит*', 'graphite', 'anthracite'], 31),
+    ('color', 'black', 'Черный', '#1A1A1A', 'black', ARRAY['черн*', 'black'], 40),
+    ('color', 'brown', 'Коричневый', '#7B4B2A', 'brown', ARRAY['коричнев*', 'шоколад*', 'brown', 'chocolate'], 50),
+    ('color', 'wenge', 'Венге', '#4B3621', 'brown', ARRAY['венге', 'wenge'], 51),
+    ('color', 'walnut', 'Орех', '#8B5A2B', 'brown', ARRAY['орех*', 'walnut'], 52),
+    ('color', 'green', 'Зеленый', '#4CAF50', 'green', ARRAY['зелен*', 'green'], 60),
+    ('color', 'olive', 'Оливковый', '#808000', 'green', ARRAY['олив*', 'olive'], 61),
+    ('color', 'blue', 'Синий', '#1E4FA0', 'blue', ARRAY['синий', 'синяя', 'синее', 'синие', 'синего', 'сине', 'blue', 'navy'], 70),
+    ('color', 'light-blue', 'Голубой', '#8EC9EA', 'blue', ARRAY['голуб*', 'light blue', 'sky blue'], 71),
+    ('color', 'red', 'Красный', '#C62828', 'red', ARRAY['красн*', 'red'], 80),
+    ('color', 'burgundy', 'Бордовый', '#7B1E2B', 'red', ARRAY['бордов*', 'burgundy', 'bordeaux'], 81),
+    ('color', 'pink', 'Розовый', '#F4A6B7', 'pink', ARRAY['розов*', 'pink'], 90),
+    ('color', 'purple', 'Фиолетовый', '#7E57C2', 'purple', ARRAY['фиолет*', 'сирен*', 'лилов*', 'purple', 'lilac', 'violet'], 100),
+    ('color', 'yellow', 'Желтый', '#F4D03F', 'yellow', ARRAY['желт*', 'yellow'], 110),
+    ('color', 'orange', 'Оранжевый', '#F57C00', 'orange', ARRAY['оранж*', 'orange'], 120),
+    ('color', 'metallic', 'Металлик', '#B0B3B8', 'metallic', ARRAY['металлик', 'metallic'], 130),
+    ('color', 'silver', 'Серебристый', '#C0C0C0', 'metallic', ARRAY['серебр*', 'silver'], 131),
+    ('color', 'gold', 'Золотой', '#C9A227', 'metallic', ARRAY['золот*', 'gold', 'golden'], 132),
+    ('color', 'bronze', 'Бронзовый', '#8C6A3E', 'metallic', ARRAY['бронз*', 'bronze'], 133),
+    ('color', 'transparent', 'Прозрачный', '', 'transparent', ARRAY['прозрачн*', 'transparent', 'clear'], 140),
+    ('color', 'multicolor', 'Разноцветный', '', 'multicolor', ARRAY['разноцветн*', 'принт', 'print', 'multicolor'], 150)
+) AS v(kind, code, name, hex, family, synonyms, sort_order)
+WHERE NOT EXISTS (SELECT 1 FROM attribute_values WHERE kind = 'color')
+ON CONFLICT (kind, code) DO NOTHING;
+
+INSERT INTO attribute_values (kind, code, name, number, synonyms, sort_order)
+SELECT * FROM (VALUES
+    ('slat_width', '16', '16 мм', 16, ARRAY['16 mm'], 10),
+    ('slat_width', '25', '25 мм', 25, ARRAY['25 mm'], 20),
+    ('slat_width', '50', '50 мм', 50, ARRAY['50 mm'], 30),
+    ('slat_width', '89', '89 мм', 89, ARRAY['89 mm'], 40),
+    ('slat_width', '127', '127 мм', 127, ARRAY['127 mm'], 50)
+) AS v(kind, code, name, number, synonyms, sort_order)
+WHERE NOT EXISTS (SELECT 1 FROM attribute_values WHERE kind = 'slat_width')
+ON CONFLICT (kind, code) DO NOTHING;
+
+-- number — типичная светопропускаемость ткани, %
+INSERT INTO attribute_values (kind, code, name, number, synonyms, sort_order)
+SELECT * FROM (VALUES
+    ('fabric_type', 'blackout', 'Блэкаут', 0, ARRAY['блэкаут', 'блекаут', 'блэк аут', 'blackout', 'black out', 'светонепроницаем*'], 10),
+    ('fabric_type', 'dimout', 'Димаут', 10, ARRAY['димаут', 'dimout', 'dim out', 'затемняющ*'], 20),
+    ('fabric_type', 'zebra', 'Зебра (день-ночь)', 50, ARRAY['зебра', 'день ночь', 'zebra', 'day night'], 30),
+    ('fabric_type', 'screen', 'Скрин', 5, ARRAY['скрин', 'screen'], 40),
+    ('fabric_type', 'translucent', 'Полупрозрачная', 70, ARRAY['полупрозрачн*', 'светорассеивающ*', 'translucent'], 50),
+    ('fabric_type', 'transparent', 'Прозрачная', 90, ARRAY['прозрачн*', 'тюль', 'вуаль', 'transparent', 'voile'], 60)
+) AS v(kind, code, name, number, synonyms, sort_order)
+WHERE NOT EXISTS (SELECT 1 FROM attribute_values WHERE kind = 'fabric_type')
+ON CONFLICT (kind, code) DO NOTHING;
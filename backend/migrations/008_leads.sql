@@ -0,0 +1,33 @@
+-- Leads: requests from site forms, measurement calls and repair requests
+
+CREATE TABLE IF NOT EXISTS leads (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL DEFAULT 'request' CHECK (kind IN ('request', 'measure', 'repair', 'quote')),
+    name VARCHAR(200) NOT NULL,
+    -- Нормализованный номер: +7XXXXXXXXXX
+    phone VARCHAR(20) NOT NULL,
+    email VARCHAR(200) NOT NULL DEFAULT '',
+    address VARCHAR(500) NOT NULL DEFAULT '',
+    zone VARCHAR(100) NOT NULL DEFAULT '',
+    out_of_area BOOLEAN NOT NULL DEFAULT false,
+    product_type VARCHAR(20) NOT NULL DEFAULT '',
+    width_mm INTEGER CHECK (width_mm > 0),
+    height_mm INTEGER CHECK (height_mm > 0),
+    material_id BIGINT REFERENCES materials(id) ON DELETE SET NULL,
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    quote_token VARCHAR(64) NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    page_url VARCHAR(500) NOT NULL DEFAULT '',
+    source VARCHAR(50) NOT NULL DEFAULT 'website',
+    total_price DECIMAL(10,2) CHECK (total_price >= 0),
+    status VARCHAR(30) NOT NULL DEFAULT 'new',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_leads_created_at ON leads(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_leads_status ON leads(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_leads_phone ON leads(phone);
+
+-- Расчет ремонта по неисправностям из формы ремонта
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS repair JSONB;
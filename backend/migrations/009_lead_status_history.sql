@@ -0,0 +1,28 @@
+-- Lead status pipeline: allowed statuses and transition history
+
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS lost_reason VARCHAR(500) NOT NULL DEFAULT '';
+
+ALTER TABLE leads DROP CONSTRAINT IF EXISTS leads_status_check;
+ALTER TABLE leads ADD CONSTRAINT leads_status_check CHECK (status IN (
+    'new', 'contacted', 'measurement_scheduled', 'measured', 'quoted', 'ordered', 'installed', 'closed', 'lost'
+));
+
+CREATE TABLE IF NOT EXISTS lead_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    lead_id BIGINT NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    -- NULL — создание заявки
+    from_status VARCHAR(30),
+    to_status VARCHAR(30) NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    reason VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_lead_status_history_lead ON lead_status_history(lead_id, created_at);
+
+-- Заявки, созданные до появления истории, начинают ее с текущего статуса
+INSERT INTO lead_status_history (lead_id, from_status, to_status, actor, created_at)
+SELECT l.id, NULL, l.status, 'system', l.created_at
+FROM leads l
+WHERE NOT EXISTS (SELECT 1 FROM lead_status_history h WHERE h.lead_id = l.id);
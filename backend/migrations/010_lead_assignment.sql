@@ -0,0 +1,49 @@
+-- Lead assignment to managers and first-response SLA
+
+CREATE TABLE IF NOT EXISTS managers (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    email VARCHAR(200) NOT NULL DEFAULT '',
+    -- Личный чат с ботом для уведомлений о назначенных заявках
+    telegram_chat_id BIGINT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    off_duty BOOLEAN NOT NULL DEFAULT false,
+    last_assigned_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Настройки хранятся версиями, как pricing_config: действует последняя запись
+CREATE TABLE IF NOT EXISTS lead_settings (
+    id SERIAL PRIMARY KEY,
+    assignment_mode VARCHAR(20) NOT NULL DEFAULT 'round_robin' CHECK (assignment_mode IN ('round_robin', 'load')),
+    first_response_hours DECIMAL(5,2) NOT NULL DEFAULT 2 CHECK (first_response_hours > 0),
+    workday_start SMALLINT NOT NULL DEFAULT 9 CHECK (workday_start BETWEEN 0 AND 23),
+    workday_end SMALLINT NOT NULL DEFAULT 19 CHECK (workday_end BETWEEN 1 AND 24),
+    work_days INTEGER[] NOT NULL DEFAULT '{1,2,3,4,5}',
+    timezone VARCHAR(50) NOT NULL DEFAULT 'Europe/Moscow',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (workday_end > workday_start)
+);
+
+INSERT INTO lead_settings (assignment_mode)
+SELECT 'round_robin'
+WHERE NOT EXISTS (SELECT 1 FROM lead_settings);
+
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS manager_id BIGINT REFERENCES managers(id) ON DELETE SET NULL;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS first_response_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS sla_due_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_leads_manager ON leads(manager_id, status);
+CREATE INDEX IF NOT EXISTS idx_leads_sla_pending ON leads(sla_due_at) WHERE first_response_at IS NULL;
+
+-- Первый ответ по старым заявкам — первая смена статуса в истории
+UPDATE leads l
+SET first_response_at = (
+    SELECT MIN(h.created_at) FROM lead_status_history h
+    WHERE h.lead_id = l.id AND h.from_status IS NOT NULL
+)
+WHERE l.first_response_at IS NULL AND l.status <> 'new';
@@ -0,0 +1,54 @@
+-- Spam protection for public forms: limits, blocklist, quarantine
+
+-- Настройки хранятся версиями, как pricing_config: действует последняя запись
+CREATE TABLE IF NOT EXISTS spam_settings (
+    id SERIAL PRIMARY KEY,
+    min_fill_seconds INTEGER NOT NULL DEFAULT 3 CHECK (min_fill_seconds >= 0),
+    token_ttl_hours INTEGER NOT NULL DEFAULT 24 CHECK (token_ttl_hours > 0),
+    phone_daily_limit INTEGER NOT NULL DEFAULT 3 CHECK (phone_daily_limit > 0),
+    ip_hourly_limit INTEGER NOT NULL DEFAULT 5 CHECK (ip_hourly_limit > 0),
+    ip_daily_limit INTEGER NOT NULL DEFAULT 20 CHECK (ip_daily_limit > 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO spam_settings (min_fill_seconds)
+SELECT 3
+WHERE NOT EXISTS (SELECT 1 FROM spam_settings);
+
+CREATE TABLE IF NOT EXISTS spam_blocklist (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('phone', 'ip', 'keyword')),
+    value VARCHAR(200) NOT NULL,
+    comment VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (kind, value)
+);
+
+-- Все отправки форм, включая подозрительные: по ним считаются лимиты
+CREATE TABLE IF NOT EXISTS form_submissions (
+    id BIGSERIAL PRIMARY KEY,
+    form VARCHAR(20) NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_form_submissions_ip ON form_submissions(ip, created_at);
+CREATE INDEX IF NOT EXISTS idx_form_submissions_phone ON form_submissions(phone, created_at);
+
+CREATE TABLE IF NOT EXISTS spam_quarantine (
+    id BIGSERIAL PRIMARY KEY,
+    form VARCHAR(20) NOT NULL CHECK (form IN ('lead', 'review')),
+    -- Данные формы как они пришли; по ним заявка или отзыв создаются при выпуске
+    payload JSONB NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    reasons TEXT[] NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'released', 'rejected')),
+    -- ID созданной заявки или отзыва после выпуска
+    result_id BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    reviewed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_spam_quarantine_status ON spam_quarantine(status, created_at);
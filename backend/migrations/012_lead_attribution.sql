@@ -0,0 +1,15 @@
+-- Marketing attribution on leads: UTM, referrer, landing page, client ID
+
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_source VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_medium VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_content VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_term VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS referrer VARCHAR(500) NOT NULL DEFAULT '';
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS landing_page VARCHAR(500) NOT NULL DEFAULT '';
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS first_visit_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS client_id VARCHAR(100) NOT NULL DEFAULT '';
+-- Канал для отчетов: utm_source, иначе домен referrer, иначе direct
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS channel VARCHAR(200) NOT NULL DEFAULT 'direct';
+
+CREATE INDEX IF NOT EXISTS idx_leads_channel ON leads(channel, utm_campaign, created_at);
@@ -0,0 +1,48 @@
+-- Customers keyed by normalized phone; leads and quotes link to them
+
+CREATE TABLE IF NOT EXISTS customers (
+    id BIGSERIAL PRIMARY KEY,
+    -- Основной телефон в виде +7XXXXXXXXXX
+    phone VARCHAR(20) NOT NULL UNIQUE,
+    -- Телефоны клиентов, объединенных с этим
+    extra_phones TEXT[] NOT NULL DEFAULT '{}',
+    name VARCHAR(200) NOT NULL DEFAULT '',
+    email VARCHAR(200) NOT NULL DEFAULT '',
+    addresses TEXT[] NOT NULL DEFAULT '{}',
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_customers_extra_phones ON customers USING GIN (extra_phones);
+CREATE INDEX IF NOT EXISTS idx_customers_email ON customers(LOWER(email)) WHERE email <> '';
+
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS customer_id BIGINT REFERENCES customers(id) ON DELETE SET NULL;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS customer_id BIGINT REFERENCES customers(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_leads_customer ON leads(customer_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_quotes_customer ON quotes(customer_id, created_at);
+
+-- Клиенты из существующих заявок: имя и email — из последней заявки, адреса —
+-- из всех. Миграция выполняется при каждом запуске, поэтому телефоны, уже
+-- объединенные с другим клиентом, не создаются заново, а адреса заполняются
+-- только у новых записей и не возвращают удаленные вручную.
+INSERT INTO customers (phone, name, email, addresses, created_at)
+SELECT DISTINCT ON (l.phone) l.phone, l.name, l.email,
+    ARRAY(SELECT DISTINCT a.address FROM leads a WHERE a.phone = l.phone AND a.address <> ''),
+    MIN(l.created_at) OVER (PARTITION BY l.phone)
+FROM leads l
+WHERE l.phone <> ''
+  AND NOT EXISTS (SELECT 1 FROM customers c WHERE l.phone = ANY(c.extra_phones))
+ORDER BY l.phone, l.created_at DESC
+ON CONFLICT (phone) DO NOTHING;
+
+UPDATE leads l
+SET customer_id = c.id
+FROM customers c
+WHERE l.customer_id IS NULL AND (l.phone = c.phone OR l.phone = ANY(c.extra_phones));
+
+UPDATE quotes q
+SET customer_id = l.customer_id
+FROM leads l
+WHERE q.customer_id IS NULL AND l.quote_token = q.token AND l.customer_id IS NOT NULL;
@@ -0,0 +1,60 @@
+-- Orders created from leads or quotes: items, production and payments
+
+CREATE TABLE IF NOT EXISTS orders (
+    id BIGSERIAL PRIMARY KEY,
+    lead_id BIGINT REFERENCES leads(id) ON DELETE SET NULL,
+    quote_id BIGINT REFERENCES quotes(id) ON DELETE SET NULL,
+    customer_id BIGINT REFERENCES customers(id) ON DELETE SET NULL,
+    customer_name VARCHAR(200) NOT NULL DEFAULT '',
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    address VARCHAR(500) NOT NULL DEFAULT '',
+    -- Выезд и прочие доплаты сверх изделий
+    surcharges JSONB NOT NULL DEFAULT '[]',
+    total DECIMAL(12,2) NOT NULL CHECK (total >= 0),
+    -- Сумма платежей, пересчитывается при каждом платеже
+    paid_amount DECIMAL(12,2) NOT NULL DEFAULT 0 CHECK (paid_amount >= 0),
+    production_status VARCHAR(20) NOT NULL DEFAULT 'new'
+        CHECK (production_status IN ('new', 'in_production', 'ready', 'installed', 'cancelled')),
+    installation_date DATE,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(production_status, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_lead ON orders(lead_id);
+CREATE INDEX IF NOT EXISTS idx_orders_customer ON orders(customer_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_installation ON orders(installation_date) WHERE installation_date IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    product_type VARCHAR(20) NOT NULL,
+    material_id BIGINT REFERENCES materials(id) ON DELETE SET NULL,
+    -- Название материала на момент заказа: каталог может измениться
+    material_name VARCHAR(255) NOT NULL DEFAULT '',
+    width_mm INTEGER NOT NULL CHECK (width_mm > 0),
+    height_mm INTEGER NOT NULL CHECK (height_mm > 0),
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    -- Управление, цвет фурнитуры, крепление и т.п.
+    options JSONB NOT NULL DEFAULT '{}',
+    unit_price DECIMAL(12,2) NOT NULL CHECK (unit_price >= 0),
+    price DECIMAL(12,2) NOT NULL CHECK (price >= 0),
+    breakdown TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_order ON order_items(order_id, position);
+
+CREATE TABLE IF NOT EXISTS order_payments (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    method VARCHAR(20) NOT NULL CHECK (method IN ('cash', 'card', 'transfer')),
+    paid_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    comment VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_payments_order ON order_payments(order_id, paid_at);
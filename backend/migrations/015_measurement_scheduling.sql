@@ -0,0 +1,60 @@
+-- Measurement appointments: measurers, working calendars, bookings
+
+CREATE TABLE IF NOT EXISTS measurers (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    -- Личный чат с ботом для уведомлений о записях
+    telegram_chat_id BIGINT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    -- Рабочая неделя: дни (1 — понедельник, 7 — воскресенье) и часы
+    work_days INTEGER[] NOT NULL DEFAULT '{1,2,3,4,5,6}',
+    work_start SMALLINT NOT NULL DEFAULT 9 CHECK (work_start BETWEEN 0 AND 23),
+    work_end SMALLINT NOT NULL DEFAULT 20 CHECK (work_end BETWEEN 1 AND 24),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (work_end > work_start)
+);
+
+-- Исключения из рабочей недели: отпуск, больничный, праздники
+CREATE TABLE IF NOT EXISTS measurer_days_off (
+    measurer_id BIGINT NOT NULL REFERENCES measurers(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    comment VARCHAR(200) NOT NULL DEFAULT '',
+    PRIMARY KEY (measurer_id, day)
+);
+
+-- Настройки хранятся версиями, как pricing_config: действует последняя запись
+CREATE TABLE IF NOT EXISTS measurement_settings (
+    id SERIAL PRIMARY KEY,
+    slot_minutes INTEGER NOT NULL DEFAULT 60 CHECK (slot_minutes BETWEEN 15 AND 480),
+    -- Время на дорогу между замерами
+    travel_buffer_minutes INTEGER NOT NULL DEFAULT 30 CHECK (travel_buffer_minutes BETWEEN 0 AND 240),
+    -- За сколько часов до начала можно записаться и на сколько дней вперед
+    min_notice_hours INTEGER NOT NULL DEFAULT 2 CHECK (min_notice_hours >= 0),
+    horizon_days INTEGER NOT NULL DEFAULT 14 CHECK (horizon_days BETWEEN 1 AND 90),
+    timezone VARCHAR(50) NOT NULL DEFAULT 'Europe/Moscow',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO measurement_settings (slot_minutes)
+SELECT 60
+WHERE NOT EXISTS (SELECT 1 FROM measurement_settings);
+
+CREATE TABLE IF NOT EXISTS measurement_bookings (
+    id BIGSERIAL PRIMARY KEY,
+    -- Токен для переноса и отмены клиентом без авторизации
+    token VARCHAR(32) NOT NULL UNIQUE,
+    measurer_id BIGINT NOT NULL REFERENCES measurers(id),
+    lead_id BIGINT NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'booked' CHECK (status IN ('booked', 'cancelled')),
+    cancel_reason VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_measurement_bookings_active ON measurement_bookings(measurer_id, starts_at) WHERE status = 'booked';
+CREATE INDEX IF NOT EXISTS idx_measurement_bookings_lead ON measurement_bookings(lead_id);
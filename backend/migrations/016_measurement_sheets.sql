@@ -0,0 +1,53 @@
+-- Digital measurement sheets: per-window measurements taken on site
+
+CREATE TABLE IF NOT EXISTS measurement_sheets (
+    id BIGSERIAL PRIMARY KEY,
+    -- Лист привязан к заявке, к заказу или к обоим
+    lead_id BIGINT REFERENCES leads(id) ON DELETE CASCADE,
+    order_id BIGINT REFERENCES orders(id) ON DELETE SET NULL,
+    booking_id BIGINT REFERENCES measurement_bookings(id) ON DELETE SET NULL,
+    measurer_id BIGINT REFERENCES measurers(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'completed')),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_measurement_sheets_lead ON measurement_sheets(lead_id);
+CREATE INDEX IF NOT EXISTS idx_measurement_sheets_order ON measurement_sheets(order_id);
+
+CREATE TABLE IF NOT EXISTS measurement_windows (
+    id BIGSERIAL PRIMARY KEY,
+    sheet_id BIGINT NOT NULL REFERENCES measurement_sheets(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    room VARCHAR(100) NOT NULL DEFAULT '',
+    product_type VARCHAR(20) NOT NULL DEFAULT '' CHECK (product_type IN ('', 'horizontal', 'vertical', 'roller')),
+    material_id BIGINT REFERENCES materials(id) ON DELETE SET NULL,
+    -- Проем меряют в нескольких местах: он редко бывает ровным
+    width_top_mm INTEGER CHECK (width_top_mm > 0),
+    width_middle_mm INTEGER CHECK (width_middle_mm > 0),
+    width_bottom_mm INTEGER CHECK (width_bottom_mm > 0),
+    height_left_mm INTEGER CHECK (height_left_mm > 0),
+    height_middle_mm INTEGER CHECK (height_middle_mm > 0),
+    height_right_mm INTEGER CHECK (height_right_mm > 0),
+    depth_mm INTEGER CHECK (depth_mm > 0),
+    -- Размер изделия, если замерщик задал его сам
+    product_width_mm INTEGER CHECK (product_width_mm > 0),
+    product_height_mm INTEGER CHECK (product_height_mm > 0),
+    mounting_type VARCHAR(20) NOT NULL DEFAULT ''
+        CHECK (mounting_type IN ('', 'opening', 'sash', 'wall', 'ceiling')),
+    mounting_surface VARCHAR(20) NOT NULL DEFAULT ''
+        CHECK (mounting_surface IN ('', 'concrete', 'brick', 'drywall', 'wood', 'pvc', 'metal')),
+    control_side VARCHAR(10) NOT NULL DEFAULT '' CHECK (control_side IN ('', 'left', 'right')),
+    -- Ручки, подоконник, батарея и все, что мешает установке
+    obstacles VARCHAR(1000) NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    options JSONB NOT NULL DEFAULT '{}',
+    notes TEXT NOT NULL DEFAULT '',
+    photos TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_measurement_windows_sheet ON measurement_windows(sheet_id, position);
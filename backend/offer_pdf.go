@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-pdf/fpdf"
+)
+
+const offerFontFamily = "DejaVu"
+
+// OfferLine — строка коммерческого предложения.
+type OfferLine struct {
+	Title  string
+	Size   string
+	AreaM2 float64
+	Price  float64
+}
+
+// Offer содержит всё, что печатается в коммерческом предложении,
+// независимо от того, из чего оно собрано (расчёт или заказ).
+type Offer struct {
+	Number     string
+	Date       time.Time
+	ValidUntil time.Time
+	Lines      []OfferLine
+	Total      float64
+	Promotions []Promotion
+	// Для заказа: дата монтажа и оплата
+	InstallationDate *time.Time
+	Paid             *float64
+	Balance          float64
+}
+
+func productTypeTitle(pt ProductType) string {
+	switch pt {
+	case ProductTypeHorizontal:
+		return "Горизонтальные жалюзи"
+	case ProductTypeVertical:
+		return "Вертикальные жалюзи"
+	case ProductTypeRoller:
+		return "Рулонные шторы"
+	default:
+		return string(pt)
+	}
+}
+
+// offerFromQuote печатает акции из снимка расчета, а не текущие: цена
+// расчета зафиксирована вместе с ними.
+func offerFromQuote(q Quote) Offer {
+	offer := Offer{
+		Number:     fmt.Sprintf("КП-%06d", q.ID),
+		Date:       q.CreatedAt,
+		ValidUntil: q.ExpiresAt,
+		Total:      q.Total,
+		Promotions: q.Promotions,
+	}
+	for _, item := range q.Items {
+		offer.Lines = append(offer.Lines, OfferLine{
+			Title:  productTypeTitle(item.ProductType) + ", " + item.MaterialName,
+			Size:   fmt.Sprintf("%d × %d", item.WidthMm, item.HeightMm),
+			AreaM2: item.AreaM2,
+			Price:  item.Price,
+		})
+	}
+	for _, s := range q.Surcharges {
+		offer.Lines = append(offer.Lines, OfferLine{Title: s.Label, Price: s.Amount})
+	}
+	return offer
+}
+
+// offerFromOrder собирает предложение по заказу: изделия и выезд по ценам
+// заказа, оплаченная сумма, остаток и дата монтажа
+func offerFromOrder(o Order) Offer {
+	o = o.withDerived()
+	paid := o.PaidAmount
+	offer := Offer{
+		Number:           fmt.Sprintf("З-%06d", o.ID),
+		Date:             o.CreatedAt,
+		Total:            o.Total,
+		InstallationDate: o.InstallationDate,
+		Paid:             &paid,
+		Balance:          o.Balance,
+	}
+	for _, item := range o.Items {
+		title := productTypeTitle(item.ProductType) + ", " + item.MaterialName
+		if item.Quantity > 1 {
+			title += fmt.Sprintf(", %d шт.", item.Quantity)
+		}
+		offer.Lines = append(offer.Lines, OfferLine{
+			Title:  title,
+			Size:   fmt.Sprintf("%d × %d", item.WidthMm, item.HeightMm),
+			AreaM2: roundTo(float64(item.WidthMm*item.HeightMm)/1e6*float64(item.Quantity), 2),
+			Price:  item.Price,
+		})
+	}
+	for _, s := range o.Surcharges {
+		offer.Lines = append(offer.Lines, OfferLine{Title: s.Label, Price: s.Amount})
+	}
+	return offer
+}
+
+// formatRub печатает сумму с разделителями разрядов: 12 340 ₽.
+func formatRub(v float64) string {
+	digits := strconv.FormatInt(int64(math.Round(v)), 10)
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String() + " ₽"
+}
+
+// renderOfferPDF собирает PDF коммерческого предложения. Шрифты DejaVu
+// берутся из fontDir, логотип подключается, только если файл существует.
+func renderOfferPDF(site SiteConfig, offer Offer, fontDir, logoPath string) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Коммерческое предложение "+offer.Number, true)
+	pdf.SetAuthor(site.CompanyName, true)
+	pdf.AddUTF8Font(offerFontFamily, "", filepath.Join(fontDir, "DejaVuSansCondensed.ttf"))
+	pdf.AddUTF8Font(offerFontFamily, "B", filepath.Join(fontDir, "DejaVuSansCondensed-Bold.ttf"))
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont(offerFontFamily, "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		var parts []string
+		for _, part := range []string{site.CompanyName, site.CompanyPhone, site.CompanyEmail} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		pdf.CellFormat(0, 5, strings.Join(parts, " · "), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// Шапка: логотип и реквизиты компании
+	headerX := 15.0
+	if logoPath != "" {
+		if _, err := os.Stat(logoPath); err == nil {
+			pdf.ImageOptions(logoPath, 15, 12, 0, 18, false, fpdf.ImageOptions{ReadDpi: true}, 0, "")
+			headerX = 60
+		}
+	}
+	pdf.SetXY(headerX, 12)
+	pdf.SetFont(offerFontFamily, "B", 16)
+	pdf.CellFormat(0, 8, site.CompanyName, "", 2, "L", false, 0, "")
+	pdf.SetFont(offerFontFamily, "", 9)
+	for _, line := range []string{site.CompanyPhone, site.CompanyEmail, site.CompanyAddress} {
+		if line != "" {
+			pdf.SetX(headerX)
+			pdf.CellFormat(0, 5, line, "", 2, "L", false, 0, "")
+		}
+	}
+	pdf.SetY(40)
+	pdf.SetDrawColor(200, 200, 200)
+	pdf.Line(15, 38, 195, 38)
+
+	pdf.SetFont(offerFontFamily, "B", 14)
+	pdf.CellFormat(0, 8, "Коммерческое предложение № "+offer.Number, "", 1, "L", false, 0, "")
+	pdf.SetFont(offerFontFamily, "", 10)
+	pdf.CellFormat(0, 6, "от "+offer.Date.Format("02.01.2006"), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	// Таблица позиций
+	widths := []float64{10, 95, 30, 20, 25}
+	headers := []string{"№", "Изделие", "Размер, мм", "м²", "Цена"}
+	pdf.SetFont(offerFontFamily, "B", 9)
+	pdf.SetFillColor(240, 240, 240)
+	for i, h := range headers {
+		pdf.CellFormat(widths[i], 7, h, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont(offerFontFamily, "", 9)
+	for i, line := range offer.Lines {
+		title := pdf.SplitText(line.Title, widths[1]-2*pdf.GetCellMargin())
+		height := 6 * float64(len(title))
+		if pdf.GetY()+height > 270 {
+			pdf.AddPage()
+		}
+		x, y := pdf.GetXY()
+		pdf.CellFormat(widths[0], height, strconv.Itoa(i+1), "1", 0, "C", false, 0, "")
+		pdf.Rect(x+widths[0], y, widths[1], height, "D")
+		for j, text := range title {
+			pdf.SetXY(x+widths[0], y+6*float64(j))
+			pdf.CellFormat(widths[1], 6, text, "", 0, "L", false, 0, "")
+		}
+		pdf.SetXY(x+widths[0]+widths[1], y)
+		pdf.CellFormat(widths[2], height, line.Size, "1", 0, "C", false, 0, "")
+		area := ""
+		if line.AreaM2 > 0 {
+			area = fmt.Sprintf("%.2f", line.AreaM2)
+		}
+		pdf.CellFormat(widths[3], height, area, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[4], height, formatRub(line.Price), "1", 1, "R", false, 0, "")
+	}
+
+	pdf.SetFont(offerFontFamily, "B", 10)
+	pdf.CellFormat(widths[0]+widths[1]+widths[2]+widths[3], 8, "Итого", "1", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[4], 8, formatRub(offer.Total), "1", 1, "R", false, 0, "")
+	if offer.Paid != nil {
+		pdf.SetFont(offerFontFamily, "", 10)
+		pdf.CellFormat(widths[0]+widths[1]+widths[2]+widths[3], 7, "Оплачено", "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 7, formatRub(*offer.Paid), "1", 1, "R", false, 0, "")
+		pdf.SetFont(offerFontFamily, "B", 10)
+		pdf.CellFormat(widths[0]+widths[1]+widths[2]+widths[3], 8, "К оплате", "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 8, formatRub(offer.Balance), "1", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont(offerFontFamily, "", 10)
+	if !offer.ValidUntil.IsZero() {
+		pdf.CellFormat(0, 6, "Предложение действительно до "+offer.ValidUntil.Format("02.01.2006")+".", "", 1, "L", false, 0, "")
+	}
+	if offer.InstallationDate != nil {
+		pdf.CellFormat(0, 6, "Дата монтажа: "+offer.InstallationDate.Format("02.01.2006")+".", "", 1, "L", false, 0, "")
+	}
+
+	if len(offer.Promotions) > 0 {
+		pdf.Ln(4)
+		pdf.SetFont(offerFontFamily, "B", 10)
+		pdf.CellFormat(0, 6, "Действующие акции", "", 1, "L", false, 0, "")
+		pdf.SetFont(offerFontFamily, "", 9)
+		for _, p := range offer.Promotions {
+			pdf.MultiCell(0, 5, "• "+p.Title+" — "+p.Description, "", "L", false)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writePDF(w http.ResponseWriter, filename string, content []byte) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(content)
+}
+
+func (a *App) handleQuotePDF() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		quote, err := a.Storage.findQuoteByToken(chi.URLParam(r, "token"))
+		a.writeQuotePDF(w, quote, err)
+	}
+}
+
+// handleAdminQuotePDF — то же предложение по ID расчета, для админки
+func (a *App) handleAdminQuotePDF() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid quote ID"})
+			return
+		}
+		quote, err := a.Storage.findQuote(id)
+		a.writeQuotePDF(w, quote, err)
+	}
+}
+
+// writeQuotePDF отдает PDF найденного расчета или ошибку поиска
+func (a *App) writeQuotePDF(w http.ResponseWriter, quote *Quote, err error) {
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+		return
+	}
+	if quote == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "quote not found"})
+		return
+	}
+
+	site, err := a.Storage.getSiteConfig()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+		return
+	}
+
+	content, err := renderOfferPDF(site, offerFromQuote(*quote), a.Config.FontDir, a.Config.LogoPath)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "pdf generation failed"})
+		return
+	}
+	writePDF(w, fmt.Sprintf("kp-%06d.pdf", quote.ID), content)
+}
+
+// handleOrderPDF — коммерческое предложение по заказу, для админки
+func (a *App) handleOrderPDF() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid order ID"})
+			return
+		}
+		order, err := a.Storage.findOrder(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if order == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "order not found"})
+			return
+		}
+
+		site, err := a.Storage.getSiteConfig()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+
+		content, err := renderOfferPDF(site, offerFromOrder(*order), a.Config.FontDir, a.Config.LogoPath)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "pdf generation failed"})
+			return
+		}
+		writePDF(w, fmt.Sprintf("order-%06d.pdf", order.ID), content)
+	}
+}
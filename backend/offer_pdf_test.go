@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOfferFromOrder(t *testing.T) {
+	installation := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	materialID := int64(3)
+	offer := offerFromOrder(Order{
+		ID: 12,
+		Items: []OrderItem{
+			{ProductType: ProductTypeVertical, MaterialID: &materialID, MaterialName: "Лайн белый", WidthMm: 1000, HeightMm: 1500, Quantity: 2, UnitPrice: 2000, Price: 4000},
+		},
+		Surcharges:       []Surcharge{{Label: "Выезд", Amount: 500}},
+		Total:            4500,
+		PaidAmount:       1000,
+		InstallationDate: &installation,
+		CreatedAt:        time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	})
+
+	if offer.Number != "З-000012" || offer.Total != 4500 {
+		t.Errorf("number = %q, total = %v", offer.Number, offer.Total)
+	}
+	if len(offer.Lines) != 2 {
+		t.Fatalf("lines = %+v, want item and surcharge", offer.Lines)
+	}
+	if line := offer.Lines[0]; line.Size != "1000 × 1500" || line.AreaM2 != 3 || line.Price != 4000 {
+		t.Errorf("item line = %+v", line)
+	}
+	if line := offer.Lines[1]; line.Title != "Выезд" || line.Price != 500 {
+		t.Errorf("surcharge line = %+v", line)
+	}
+	if offer.Paid == nil || *offer.Paid != 1000 || offer.Balance != 3500 {
+		t.Errorf("paid = %v, balance = %v", offer.Paid, offer.Balance)
+	}
+	if offer.InstallationDate == nil || !offer.InstallationDate.Equal(installation) {
+		t.Errorf("installation date = %v", offer.InstallationDate)
+	}
+	if !offer.ValidUntil.IsZero() {
+		t.Errorf("validUntil = %v, want none for an order", offer.ValidUntil)
+	}
+}
@@ -0,0 +1,1130 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// ProductionStatus — этап изготовления и установки заказа
+type ProductionStatus string
+
+const (
+	ProductionNew          ProductionStatus = "new"
+	ProductionInProduction ProductionStatus = "in_production"
+	ProductionReady        ProductionStatus = "ready"
+	ProductionInstalled    ProductionStatus = "installed"
+	ProductionCancelled    ProductionStatus = "cancelled"
+)
+
+// productionTransitions — разрешенные переходы. Готовый заказ можно вернуть
+// в производство на переделку; установленный и отмененный окончательны.
+var productionTransitions = map[ProductionStatus][]ProductionStatus{
+	ProductionNew:          {ProductionInProduction, ProductionCancelled},
+	ProductionInProduction: {ProductionReady, ProductionCancelled},
+	ProductionReady:        {ProductionInstalled, ProductionInProduction, ProductionCancelled},
+	ProductionInstalled:    {},
+	ProductionCancelled:    {},
+}
+
+func (s ProductionStatus) valid() bool {
+	_, ok := productionTransitions[s]
+	return ok
+}
+
+// itemsEditable — изделия и выезд меняются, пока заказ не установлен и не отменен
+func (s ProductionStatus) itemsEditable() bool {
+	return s != ProductionInstalled && s != ProductionCancelled
+}
+
+func canChangeProduction(from, to ProductionStatus) bool {
+	for _, next := range productionTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PaymentStatus вычисляется по сумме платежей и итогу заказа
+type PaymentStatus string
+
+const (
+	PaymentUnpaid  PaymentStatus = "unpaid"
+	PaymentPartial PaymentStatus = "partial"
+	PaymentPaid    PaymentStatus = "paid"
+)
+
+var (
+	errOrderNoItems   = errors.New("order has no items")
+	errOverpayment    = errors.New("payment exceeds order balance")
+	errTotalBelowPaid = errors.New("order total is less than already paid")
+	errLeadNotFound   = errors.New("lead not found")
+	errInvalidDate    = errors.New("invalid installationDate")
+	errQuoteExpired   = errors.New("quote has expired, make a new estimate")
+)
+
+type OrderItemRequest struct {
+	ProductType ProductType       `json:"productType" validate:"required,oneof=horizontal vertical roller"`
+	MaterialID  int64             `json:"materialId" validate:"required,gt=0"`
+	WidthMm     int               `json:"widthMm" validate:"required,gt=0,lte=4000"`
+	HeightMm    int               `json:"heightMm" validate:"required,gt=0,lte=3000"`
+	Quantity    int               `json:"quantity" validate:"omitempty,gt=0,lte=100"`
+	Options     map[string]string `json:"options,omitempty" validate:"max=20"`
+	// UnitPrice — договорная цена за штуку; без нее цена считается по каталогу
+	UnitPrice *float64 `json:"unitPrice,omitempty" validate:"omitempty,gte=0"`
+}
+
+// OrderRequest — создание заказа. Изделия берутся из items, иначе из
+// сохраненного расчета (quoteToken или расчет заявки), иначе из материала
+// и размеров заявки.
+type OrderRequest struct {
+	LeadID           *int64             `json:"leadId,omitempty" validate:"omitempty,gt=0"`
+	QuoteToken       string             `json:"quoteToken,omitempty" validate:"max=64"`
+	CustomerName     string             `json:"customerName,omitempty" validate:"max=200"`
+	Phone            string             `json:"phone,omitempty" validate:"max=30"`
+	Address          string             `json:"address,omitempty" validate:"max=500"`
+	Items            []OrderItemRequest `json:"items,omitempty" validate:"max=50,dive"`
+	InstallationDate string             `json:"installationDate,omitempty"`
+	Note             string             `json:"note,omitempty" validate:"max=5000"`
+}
+
+// OrderUpdateRequest — изменение заказа; отсутствующие поля не меняются,
+// пустая installationDate снимает дату. items заменяют все изделия.
+type OrderUpdateRequest struct {
+	Address          *string            `json:"address,omitempty" validate:"omitempty,max=500"`
+	Items            []OrderItemRequest `json:"items,omitempty" validate:"max=50,dive"`
+	InstallationDate *string            `json:"installationDate,omitempty"`
+	Note             *string            `json:"note,omitempty" validate:"omitempty,max=5000"`
+}
+
+type OrderStatusRequest struct {
+	Status  ProductionStatus `json:"status" validate:"required"`
+	Actor   string           `json:"actor" validate:"max=100"`
+	Comment string           `json:"comment" validate:"max=2000"`
+}
+
+type OrderItem struct {
+	ID           int64             `json:"id"`
+	Position     int               `json:"position"`
+	ProductType  ProductType       `json:"productType"`
+	MaterialID   *int64            `json:"materialId,omitempty"`
+	MaterialName string            `json:"materialName"`
+	WidthMm      int               `json:"widthMm"`
+	HeightMm     int               `json:"heightMm"`
+	Quantity     int               `json:"quantity"`
+	Options      map[string]string `json:"options"`
+	UnitPrice    float64           `json:"unitPrice"`
+	Price        float64           `json:"price"`
+	Breakdown    string            `json:"breakdown,omitempty"`
+}
+
+type OrderPayment struct {
+	ID      int64     `json:"id"`
+	OrderID int64     `json:"orderId"`
+	Amount  float64   `json:"amount" validate:"required,gt=0"`
+	Method  string    `json:"method" validate:"required,oneof=cash card transfer"`
+	PaidAt  time.Time `json:"paidAt"`
+	Comment string    `json:"comment,omitempty" validate:"max=500"`
+}
+
+type Order struct {
+	ID               int64            `json:"id"`
+	LeadID           *int64           `json:"leadId,omitempty"`
+	QuoteID          *int64           `json:"quoteId,omitempty"`
+	CustomerID       *int64           `json:"customerId,omitempty"`
+	CustomerName     string           `json:"customerName"`
+	Phone            string           `json:"phone"`
+	Address          string           `json:"address,omitempty"`
+	Items            []OrderItem      `json:"items,omitempty"`
+	Surcharges       []Surcharge      `json:"surcharges"`
+	Total            float64          `json:"total"`
+	PaidAmount       float64          `json:"paidAmount"`
+	ProductionStatus ProductionStatus `json:"productionStatus"`
+	InstallationDate *time.Time       `json:"installationDate,omitempty"`
+	Note             string           `json:"note,omitempty"`
+	Payments         []OrderPayment   `json:"payments,omitempty"`
+	CreatedAt        time.Time        `json:"createdAt"`
+	UpdatedAt        time.Time        `json:"updatedAt"`
+	// Вычисляемые поля
+	PaymentStatus PaymentStatus      `json:"paymentStatus"`
+	Balance       float64            `json:"balance"`
+	NextStatuses  []ProductionStatus `json:"nextStatuses"`
+}
+
+// withDerived заполняет вычисляемые поля, которые не хранятся в базе.
+func (o Order) withDerived() Order {
+	o.Balance = roundTo(o.Total-o.PaidAmount, 2)
+	switch {
+	case o.PaidAmount <= 0:
+		o.PaymentStatus = PaymentUnpaid
+	case o.Balance > 0:
+		o.PaymentStatus = PaymentPartial
+	default:
+		o.PaymentStatus = PaymentPaid
+	}
+	o.NextStatuses = append([]ProductionStatus{}, productionTransitions[o.ProductionStatus]...)
+	return o
+}
+
+// recalcTotal — изделия плюс доплаты
+func (o *Order) recalcTotal() {
+	var total float64
+	for _, item := range o.Items {
+		total += item.Price
+	}
+	for _, s := range o.Surcharges {
+		total += s.Amount
+	}
+	o.Total = roundTo(total, 2)
+}
+
+// orderChange — то, что для изменения заказа пересчитано по каталогу и зонам
+type orderChange struct {
+	// Items заменяют изделия; nil — изделия не меняются
+	Items []OrderItem
+	// Surcharges — выезд по новому адресу; nil — выезд не пересчитывается.
+	// Заказ по расчету сохраняет выезд из расчета.
+	Surcharges []Surcharge
+	// ItemStatuses — статусы, в которых можно заменить изделия; пусто — любые,
+	// кроме установленного и отмененного
+	ItemStatuses []ProductionStatus
+}
+
+// OrderFilter — отбор заказов в админке; пустые поля не ограничивают
+type OrderFilter struct {
+	Statuses      []string
+	PaymentStatus PaymentStatus
+	CustomerID    *int64
+	LeadID        *int64
+	From, To      *time.Time
+	Limit         int
+	Offset        int
+}
+
+func parseOrderDate(v string) (*time.Time, error) {
+	if v = strings.TrimSpace(v); v == "" {
+		return nil, nil
+	}
+	d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return nil, errInvalidDate
+	}
+	return &d, nil
+}
+
+// priceOrderItems считает изделия по текущим ценам каталога; договорная
+// цена за штуку, если указана, заменяет расчетную
+func (a *App) priceOrderItems(reqs []OrderItemRequest) ([]OrderItem, error) {
+	config, err := a.Storage.getPricingConfig()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]OrderItem, 0, len(reqs))
+	for i, req := range reqs {
+		material, err := a.Storage.findMaterial(req.MaterialID)
+		if err != nil {
+			return nil, err
+		}
+		if material == nil {
+			return nil, fmt.Errorf("%w: %d", errMaterialNotFound, req.MaterialID)
+		}
+		quantity := req.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		estimate := priceWindow(*material, config, req.WidthMm, req.HeightMm)
+		unitPrice, breakdown := estimate.Price, estimate.Breakdown
+		if req.UnitPrice != nil {
+			unitPrice, breakdown = roundTo(*req.UnitPrice, 2), "Договорная цена"
+		}
+		options := req.Options
+		if options == nil {
+			options = map[string]string{}
+		}
+		materialID := material.ID
+		items = append(items, OrderItem{
+			Position:     i + 1,
+			ProductType:  req.ProductType,
+			MaterialID:   &materialID,
+			MaterialName: material.Name,
+			WidthMm:      req.WidthMm,
+			HeightMm:     req.HeightMm,
+			Quantity:     quantity,
+			Options:      options,
+			UnitPrice:    unitPrice,
+			Price:        roundTo(unitPrice*float64(quantity), 2),
+			Breakdown:    breakdown,
+		})
+	}
+	return items, nil
+}
+
+// quoteOrderItems переносит в заказ изделия сохраненного расчета по ценам
+// расчета. Цены держатся только до expiresAt: по истекшему расчету
+// возвращается errQuoteExpired, нужен новый расчет.
+func quoteOrderItems(quote Quote, now time.Time) ([]OrderItem, error) {
+	if quote.withDerived(now).Expired {
+		return nil, errQuoteExpired
+	}
+	items := make([]OrderItem, 0, len(quote.Items))
+	for i, qi := range quote.Items {
+		materialID := qi.MaterialID
+		items = append(items, OrderItem{
+			Position:     i + 1,
+			ProductType:  qi.ProductType,
+			MaterialID:   &materialID,
+			MaterialName: qi.MaterialName,
+			WidthMm:      qi.WidthMm,
+			HeightMm:     qi.HeightMm,
+			Quantity:     1,
+			Options:      map[string]string{},
+			UnitPrice:    qi.Price,
+			Price:        qi.Price,
+			Breakdown:    qi.Breakdown,
+		})
+	}
+	return items, nil
+}
+
+// buildOrder собирает заказ из заявки, расчета и/или явного списка изделий
+func (a *App) buildOrder(req OrderRequest) (Order, error) {
+	order := Order{
+		CustomerName:     strings.TrimSpace(req.CustomerName),
+		Address:          strings.TrimSpace(req.Address),
+		Note:             strings.TrimSpace(req.Note),
+		ProductionStatus: ProductionNew,
+		Surcharges:       []Surcharge{},
+	}
+	if v := strings.TrimSpace(req.Phone); v != "" {
+		phone, err := normalizePhone(v)
+		if err != nil {
+			return order, err
+		}
+		order.Phone = phone
+	}
+	date, err := parseOrderDate(req.InstallationDate)
+	if err != nil {
+		return order, err
+	}
+	order.InstallationDate = date
+
+	var lead *Lead
+	if req.LeadID != nil {
+		if lead, err = a.Storage.findLead(*req.LeadID); err != nil {
+			return order, err
+		}
+		if lead == nil {
+			return order, errLeadNotFound
+		}
+		order.LeadID, order.CustomerID = &lead.ID, lead.CustomerID
+		if order.CustomerName == "" {
+			order.CustomerName = lead.Name
+		}
+		if order.Phone == "" {
+			order.Phone = lead.Phone
+		}
+		if order.Address == "" {
+			order.Address = lead.Address
+		}
+	}
+
+	quoteToken := req.QuoteToken
+	if quoteToken == "" && lead != nil {
+		quoteToken = lead.QuoteToken
+	}
+	var quote *Quote
+	if quoteToken != "" {
+		if quote, err = a.Storage.findQuoteByToken(quoteToken); err != nil {
+			return order, err
+		}
+		if quote == nil {
+			return order, errQuoteNotFound
+		}
+		order.QuoteID = &quote.ID
+	}
+
+	switch {
+	case len(req.Items) > 0:
+		if order.Items, err = a.priceOrderItems(req.Items); err != nil {
+			return order, err
+		}
+	case quote != nil:
+		if order.Items, err = quoteOrderItems(*quote, time.Now()); err != nil {
+			return order, err
+		}
+	case lead != nil && lead.ProductType != "" && lead.MaterialID != nil && lead.WidthMm != nil && lead.HeightMm != nil:
+		// Без типа изделия в заявке изделие не угадывается: нужны явные items
+		order.Items, err = a.priceOrderItems([]OrderItemRequest{{
+			ProductType: lead.ProductType,
+			MaterialID:  *lead.MaterialID,
+			WidthMm:     *lead.WidthMm,
+			HeightMm:    *lead.HeightMm,
+		}})
+		if err != nil {
+			return order, err
+		}
+	}
+	if len(order.Items) == 0 {
+		return order, errOrderNoItems
+	}
+
+	// Выезд: из действующего расчета, иначе по адресу заказа
+	if quote != nil && !quote.withDerived(time.Now()).Expired {
+		order.Surcharges = append(order.Surcharges, quote.Surcharges...)
+	} else if order.Address != "" {
+		zone, err := a.resolveAddress(&CustomerAddress{Address: order.Address})
+		if err != nil {
+			return order, err
+		}
+		order.Surcharges = append(order.Surcharges, zone.Surcharges...)
+	}
+	order.recalcTotal()
+	return order, nil
+}
+
+// Orders
+const orderColumns = `id, lead_id, quote_id, customer_id, customer_name, phone, address, surcharges, total, paid_amount,
+	production_status, installation_date, note, created_at, updated_at`
+
+func scanOrder(row interface{ Scan(...any) error }) (Order, error) {
+	var (
+		o          Order
+		surcharges []byte
+	)
+	err := row.Scan(&o.ID, &o.LeadID, &o.QuoteID, &o.CustomerID, &o.CustomerName, &o.Phone, &o.Address, &surcharges,
+		&o.Total, &o.PaidAmount, &o.ProductionStatus, &o.InstallationDate, &o.Note, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return o, err
+	}
+	return o, json.Unmarshal(surcharges, &o.Surcharges)
+}
+
+func insertOrderItemsTx(tx *sql.Tx, orderID int64, items []OrderItem) error {
+	for i, item := range items {
+		options, err := json.Marshal(item.Options)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO order_items (order_id, position, product_type, material_id, material_name, width_mm, height_mm,
+			                         quantity, options, unit_price, price, breakdown)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`, orderID, i+1, item.ProductType, item.MaterialID, item.MaterialName, item.WidthMm, item.HeightMm,
+			item.Quantity, options, item.UnitPrice, item.Price, item.Breakdown)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addOrder сохраняет заказ с изделиями, привязывает его к клиенту и
+// переводит заявку в ordered, если это разрешено из ее текущего статуса
+func (s *DatabaseStore) addOrder(o Order, actor string) (Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return o, err
+	}
+	defer tx.Rollback()
+
+	if o, err = addOrderTx(tx, o, actor); err != nil {
+		return o, err
+	}
+	return o, tx.Commit()
+}
+
+// addOrderTx — addOrder внутри чужой транзакции, например вместе с привязкой
+// к листу замера
+func addOrderTx(tx *sql.Tx, o Order, actor string) (Order, error) {
+	surcharges, err := json.Marshal(o.Surcharges)
+	if err != nil {
+		return o, err
+	}
+
+	if o.CustomerID == nil && o.Phone != "" {
+		customerID, err := upsertCustomerTx(tx, o.Phone, o.CustomerName, "", o.Address)
+		if err != nil {
+			return o, err
+		}
+		o.CustomerID = &customerID
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO orders (lead_id, quote_id, customer_id, customer_name, phone, address, surcharges, total,
+		                    production_status, installation_date, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at
+	`, o.LeadID, o.QuoteID, o.CustomerID, o.CustomerName, o.Phone, o.Address, surcharges, o.Total,
+		o.ProductionStatus, o.InstallationDate, o.Note).Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return o, err
+	}
+	if err := insertOrderItemsTx(tx, o.ID, o.Items); err != nil {
+		return o, err
+	}
+	if o.QuoteID != nil && o.CustomerID != nil {
+		if _, err := tx.Exec("UPDATE quotes SET customer_id = $1 WHERE id = $2 AND customer_id IS NULL", o.CustomerID, o.QuoteID); err != nil {
+			return o, err
+		}
+	}
+	if err := advanceLeadTx(tx, o.LeadID, LeadStatusOrdered, actor, fmt.Sprintf("Заказ №%d", o.ID)); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
+// advanceLeadTx переводит заявку заказа в статус to, если такой переход
+// разрешен; иначе заявка остается как есть
+func advanceLeadTx(tx *sql.Tx, leadID *int64, to LeadStatus, actor, comment string) error {
+	if leadID == nil {
+		return nil
+	}
+	_, err := transitionLeadTx(tx, LeadTransition{LeadID: *leadID, To: to, Actor: actor, Comment: comment})
+	if errors.Is(err, errInvalidTransition) {
+		return nil
+	}
+	return err
+}
+
+// findOrder возвращает заказ с изделиями и платежами или nil
+func (s *DatabaseStore) findOrder(id int64) (*Order, error) {
+	o, err := scanOrder(s.db.QueryRow("SELECT "+orderColumns+" FROM orders WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.Items, err = s.getOrderItems(id); err != nil {
+		return nil, err
+	}
+	if o.Payments, err = s.getOrderPayments(id); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (s *DatabaseStore) getOrderItems(orderID int64) ([]OrderItem, error) {
+	return queryOrderItems(s.db, orderID)
+}
+
+func queryOrderItems(q querier, orderID int64) ([]OrderItem, error) {
+	rows, err := q.Query(`
+		SELECT id, position, product_type, material_id, material_name, width_mm, height_mm, quantity, options,
+		       unit_price, price, breakdown
+		FROM order_items
+		WHERE order_id = $1
+		ORDER BY position, id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []OrderItem{}
+	for rows.Next() {
+		var (
+			item    OrderItem
+			options []byte
+		)
+		if err := rows.Scan(&item.ID, &item.Position, &item.ProductType, &item.MaterialID, &item.MaterialName,
+			&item.WidthMm, &item.HeightMm, &item.Quantity, &options, &item.UnitPrice, &item.Price, &item.Breakdown); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(options, &item.Options); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *DatabaseStore) getOrderPayments(orderID int64) ([]OrderPayment, error) {
+	rows, err := s.db.Query(`
+		SELECT id, order_id, amount, method, paid_at, comment
+		FROM order_payments
+		WHERE order_id = $1
+		ORDER BY paid_at, id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []OrderPayment{}
+	for rows.Next() {
+		var p OrderPayment
+		if err := rows.Scan(&p.ID, &p.OrderID, &p.Amount, &p.Method, &p.PaidAt, &p.Comment); err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+func (s *DatabaseStore) getOrders(f OrderFilter) ([]Order, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if len(f.Statuses) > 0 {
+		add("production_status = ANY($%d)", pq.Array(f.Statuses))
+	}
+	switch f.PaymentStatus {
+	case PaymentUnpaid:
+		where = append(where, "paid_amount = 0")
+	case PaymentPartial:
+		where = append(where, "paid_amount > 0 AND paid_amount < total")
+	case PaymentPaid:
+		where = append(where, "paid_amount > 0 AND paid_amount >= total")
+	}
+	if f.CustomerID != nil {
+		add("customer_id = $%d", *f.CustomerID)
+	}
+	if f.LeadID != nil {
+		add("lead_id = $%d", *f.LeadID)
+	}
+	if f.From != nil {
+		add("created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("created_at < $%d", *f.To)
+	}
+
+	query := "SELECT " + orderColumns + " FROM orders"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// updateOrder меняет адрес, дату, заметку и при необходимости заменяет
+// изделия и выезд. Итог не может стать меньше уже оплаченной суммы.
+// Изделия установленного или отмененного заказа не меняются.
+func (s *DatabaseStore) updateOrder(id int64, in OrderUpdateRequest, change orderChange) (*Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	o, err := scanOrder(tx.QueryRow("SELECT "+orderColumns+" FROM orders WHERE id = $1 FOR UPDATE", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Address != nil {
+		o.Address = strings.TrimSpace(*in.Address)
+	}
+	if in.Note != nil {
+		o.Note = strings.TrimSpace(*in.Note)
+	}
+	if in.InstallationDate != nil {
+		if o.InstallationDate, err = parseOrderDate(*in.InstallationDate); err != nil {
+			return nil, err
+		}
+	}
+	if change.Items != nil {
+		editable := o.ProductionStatus.itemsEditable()
+		if len(change.ItemStatuses) > 0 {
+			editable = false
+			for _, st := range change.ItemStatuses {
+				editable = editable || st == o.ProductionStatus
+			}
+		}
+		if !editable {
+			return nil, fmt.Errorf("%w: items of %s order", errInvalidTransition, o.ProductionStatus)
+		}
+	}
+	// Новый выезд по адресу — только для заказа без расчета и пока его
+	// изделия можно менять
+	surchargesChanged := change.Surcharges != nil && o.QuoteID == nil && o.ProductionStatus.itemsEditable()
+
+	if change.Items != nil || surchargesChanged {
+		if change.Items != nil {
+			o.Items = change.Items
+		} else if o.Items, err = queryOrderItems(tx, id); err != nil {
+			return nil, err
+		}
+		if surchargesChanged {
+			o.Surcharges = change.Surcharges
+		}
+		o.recalcTotal()
+		if o.Total < o.PaidAmount {
+			return nil, errTotalBelowPaid
+		}
+	}
+	if change.Items != nil {
+		if _, err := tx.Exec("DELETE FROM order_items WHERE order_id = $1", id); err != nil {
+			return nil, err
+		}
+		if err := insertOrderItemsTx(tx, id, change.Items); err != nil {
+			return nil, err
+		}
+	}
+	surcharges, err := json.Marshal(o.Surcharges)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE orders SET address = $2, note = $3, installation_date = $4, total = $5, surcharges = $6, updated_at = NOW()
+		WHERE id = $1
+	`, id, o.Address, o.Note, o.InstallationDate, o.Total, surcharges)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.findOrder(id)
+}
+
+// changeOrderStatus переводит заказ по этапам производства. Установка
+// заказа переводит и его заявку в installed.
+func (s *DatabaseStore) changeOrderStatus(id int64, to ProductionStatus, actor, comment string) (*Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		current ProductionStatus
+		leadID  *int64
+	)
+	err = tx.QueryRow("SELECT production_status, lead_id FROM orders WHERE id = $1 FOR UPDATE", id).Scan(&current, &leadID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !canChangeProduction(current, to) {
+		return nil, fmt.Errorf("%w: %s → %s", errInvalidTransition, current, to)
+	}
+	if _, err := tx.Exec("UPDATE orders SET production_status = $2, updated_at = NOW() WHERE id = $1", id, to); err != nil {
+		return nil, err
+	}
+	if to == ProductionInstalled {
+		if comment == "" {
+			comment = fmt.Sprintf("Заказ №%d установлен", id)
+		}
+		if err := advanceLeadTx(tx, leadID, LeadStatusInstalled, actor, comment); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.findOrder(id)
+}
+
+// addOrderPayment записывает платеж и пересчитывает оплаченную сумму.
+// Платеж больше остатка отклоняется.
+func (s *DatabaseStore) addOrderPayment(p OrderPayment) (*Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		total, paid float64
+		status      ProductionStatus
+	)
+	err = tx.QueryRow("SELECT total, paid_amount, production_status FROM orders WHERE id = $1 FOR UPDATE", p.OrderID).
+		Scan(&total, &paid, &status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status == ProductionCancelled {
+		return nil, fmt.Errorf("%w: payment to cancelled order", errInvalidTransition)
+	}
+	if roundTo(paid+p.Amount, 2) > total {
+		return nil, errOverpayment
+	}
+	_, err = tx.Exec(`
+		INSERT INTO order_payments (order_id, amount, method, paid_at, comment)
+		VALUES ($1, $2, $3, $4, $5)
+	`, p.OrderID, p.Amount, p.Method, p.PaidAt, p.Comment)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncPaidAmountTx(tx, p.OrderID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.findOrder(p.OrderID)
+}
+
+// deleteOrderPayment удаляет ошибочный платеж. nil — заказа или платежа нет.
+func (s *DatabaseStore) deleteOrderPayment(orderID, paymentID int64) (*Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM order_payments WHERE id = $1 AND order_id = $2", paymentID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	if err := syncPaidAmountTx(tx, orderID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.findOrder(orderID)
+}
+
+func syncPaidAmountTx(tx *sql.Tx, orderID int64) error {
+	_, err := tx.Exec(`
+		UPDATE orders
+		SET paid_amount = (SELECT COALESCE(SUM(amount), 0) FROM order_payments WHERE order_id = $1), updated_at = NOW()
+		WHERE id = $1
+	`, orderID)
+	return err
+}
+
+func (s *DatabaseStore) getCustomerOrders(customerID int64) ([]Order, error) {
+	return s.getOrders(OrderFilter{CustomerID: &customerID, Limit: 500})
+}
+
+// parseOrderFilter читает параметры списка заказов: status (несколько через
+// запятую), paymentStatus, customerId, leadId, from и to (YYYY-MM-DD, to
+// включительно), limit, offset
+func parseOrderFilter(q map[string][]string) (OrderFilter, error) {
+	get := func(key string) string {
+		if v := q[key]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	f := OrderFilter{Limit: 50}
+
+	for _, s := range strings.Split(get("status"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			if !ProductionStatus(s).valid() {
+				return f, errors.New("invalid status")
+			}
+			f.Statuses = append(f.Statuses, s)
+		}
+	}
+	switch ps := PaymentStatus(get("paymentStatus")); ps {
+	case "", PaymentUnpaid, PaymentPartial, PaymentPaid:
+		f.PaymentStatus = ps
+	default:
+		return f, errors.New("invalid paymentStatus")
+	}
+	for key, dst := range map[string]**int64{"customerId": &f.CustomerID, "leadId": &f.LeadID} {
+		if v := get(key); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return f, errors.New("invalid " + key)
+			}
+			*dst = &id
+		}
+	}
+	if v := get("from"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("invalid from date")
+		}
+		f.From = &d
+	}
+	if v := get("to"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("invalid to date")
+		}
+		d = d.AddDate(0, 0, 1)
+		f.To = &d
+	}
+	if v := get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			return f, errors.New("invalid limit")
+		}
+		f.Limit = n
+	}
+	if v := get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, errors.New("invalid offset")
+		}
+		f.Offset = n
+	}
+	return f, nil
+}
+
+// writeOrderError отвечает на ошибки сборки и изменения заказа
+func writeOrderError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errLeadNotFound), errors.Is(err, errQuoteNotFound), errors.Is(err, errMaterialNotFound),
+		errors.Is(err, errOrderNoItems), errors.Is(err, errInvalidPhone), errors.Is(err, errInvalidDate):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, errOverpayment), errors.Is(err, errTotalBelowPaid), errors.Is(err, errInvalidTransition),
+		errors.Is(err, errQuoteExpired):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+	}
+}
+
+// Handlers
+func (a *App) handleCreateOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in OrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		order, err := a.buildOrder(in)
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+		created, err := a.Storage.addOrder(order, "admin")
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, created.withDerived())
+	}
+}
+
+func (a *App) handleListOrders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseOrderFilter(r.URL.Query())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		orders, err := a.Storage.getOrders(filter)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		for i := range orders {
+			orders[i] = orders[i].withDerived()
+		}
+		writeJSON(w, http.StatusOK, orders)
+	}
+}
+
+func (a *App) handleGetOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid order ID"})
+			return
+		}
+
+		order, err := a.Storage.findOrder(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if order == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "order not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, order.withDerived())
+	}
+}
+
+func (a *App) handleUpdateOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid order ID"})
+			return
+		}
+
+		var in OrderUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		var change orderChange
+		if len(in.Items) > 0 {
+			if change.Items, err = a.priceOrderItems(in.Items); err != nil {
+				writeOrderError(w, err)
+				return
+			}
+		}
+		if in.Address != nil {
+			zone, err := a.resolveAddress(&CustomerAddress{Address: *in.Address})
+			if err != nil {
+				writeOrderError(w, err)
+				return
+			}
+			change.Surcharges = append([]Surcharge{}, zone.Surcharges...)
+		}
+		order, err := a.Storage.updateOrder(id, in, change)
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+		if order == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "order not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, order.withDerived())
+	}
+}
+
+func (a *App) handleChangeOrderStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid order ID"})
+			return
+		}
+
+		var in OrderStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil || !in.Status.valid() {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		actor := strings.TrimSpace(in.Actor)
+		if actor == "" {
+			actor = "admin"
+		}
+
+		order, err := a.Storage.changeOrderStatus(id, in.Status, actor, strings.TrimSpace(in.Comment))
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+		if order == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "order not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, order.withDerived())
+	}
+}
+
+func (a *App) handleAddOrderPayment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid order ID"})
+			return
+		}
+
+		var p OrderPayment
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(p); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		p.OrderID = id
+		p.Amount = roundTo(p.Amount, 2)
+		p.Comment = strings.TrimSpace(p.Comment)
+		if p.PaidAt.IsZero() {
+			p.PaidAt = time.Now()
+		}
+
+		order, err := a.Storage.addOrderPayment(p)
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+		if order == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "order not found"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, order.withDerived())
+	}
+}
+
+func (a *App) handleDeleteOrderPayment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid order ID"})
+			return
+		}
+		paymentID, err := strconv.ParseInt(chi.URLParam(r, "paymentId"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+			return
+		}
+
+		order, err := a.Storage.deleteOrderPayment(id, paymentID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if order == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "payment not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, order.withDerived())
+	}
+}
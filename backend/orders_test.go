@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testQuote(expiresAt time.Time) Quote {
+	return Quote{
+		ID: 7,
+		Items: []QuoteItem{
+			{ProductType: ProductTypeVertical, MaterialID: 3, MaterialName: "Лайн белый", WidthMm: 1200, HeightMm: 1500, Price: 4200, Breakdown: "расчет"},
+			{ProductType: ProductTypeRoller, MaterialID: 5, MaterialName: "Блэкаут серый", WidthMm: 800, HeightMm: 1400, Price: 3100},
+		},
+		Surcharges: []Surcharge{{Label: "Выезд", Amount: 500}},
+		ExpiresAt:  expiresAt,
+	}
+}
+
+func TestQuoteOrderItemsKeepsQuotePricesUntilExpiry(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	items, err := quoteOrderItems(testQuote(now.Add(time.Hour)), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("items = %d, want 2", len(items))
+	}
+	first := items[0]
+	if first.Position != 1 || first.ProductType != ProductTypeVertical || first.MaterialID == nil || *first.MaterialID != 3 ||
+		first.Quantity != 1 || first.UnitPrice != 4200 || first.Price != 4200 || first.Breakdown != "расчет" {
+		t.Errorf("first item = %+v", first)
+	}
+	if items[1].Position != 2 || items[1].Price != 3100 {
+		t.Errorf("second item = %+v", items[1])
+	}
+}
+
+func TestQuoteOrderItemsRejectsExpiredQuote(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	// Срок истекает ровно в expiresAt
+	for _, expiresAt := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		items, err := quoteOrderItems(testQuote(expiresAt), now)
+		if !errors.Is(err, errQuoteExpired) {
+			t.Errorf("expiresAt %s: err = %v, want errQuoteExpired", expiresAt, err)
+		}
+		if items != nil {
+			t.Errorf("expiresAt %s: items = %+v, want none", expiresAt, items)
+		}
+	}
+}
+
+func TestItemsEditable(t *testing.T) {
+	for status, want := range map[ProductionStatus]bool{
+		ProductionNew:          true,
+		ProductionInProduction: true,
+		ProductionReady:        true,
+		ProductionInstalled:    false,
+		ProductionCancelled:    false,
+	} {
+		if got := status.itemsEditable(); got != want {
+			t.Errorf("%s: itemsEditable = %v, want %v", status, got, want)
+		}
+	}
+}
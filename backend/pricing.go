@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// priceWindow рассчитывает стоимость одного изделия по переданной конфигурации цен.
+func priceWindow(material Material, config PricingConfig, widthMm, heightMm int) PriceEstimateResponse {
+	widthM := float64(widthMm) / 1000.0
+	heightM := float64(heightMm) / 1000.0
+	area := widthM * heightM
+	if area < config.MinAreaM2 {
+		area = config.MinAreaM2
+	}
+
+	// Базовая стоимость: площадь × стоимость м² материала
+	materialCost := area * material.PricePerM2
+
+	// Добавляем стоимость каркаса (30% от стоимости материала)
+	frameCost := materialCost * config.FrameMarkup
+
+	// Себестоимость: материал + каркас
+	costPrice := materialCost + frameCost
+
+	// Добавляем наценку производства (50% от себестоимости)
+	productionCost := costPrice * config.ProductionMarkup
+
+	// Итоговая стоимость: себестоимость + наценка производства
+	total := costPrice + productionCost
+
+	// Округляем до 10 рублей
+	total = math.Round(total/10) * 10
+
+	return PriceEstimateResponse{
+		Price:    total,
+		Currency: "₽",
+		AreaM2:   area,
+		Breakdown: fmt.Sprintf("Площадь: %.2f м² × %d ₽/м² = %.0f ₽ (материал) + %.0f ₽ (каркас %.0f%%) + %.0f ₽ (наценка %.0f%%) = %.0f ₽",
+			area, int(material.PricePerM2), materialCost, frameCost, config.FrameMarkup*100,
+			productionCost, config.ProductionMarkup*100, total),
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+)
+
+type WindowSize struct {
+	WidthMm  int `json:"widthMm" validate:"required,gt=0,lte=4000"`
+	HeightMm int `json:"heightMm" validate:"required,gt=0,lte=3000"`
+}
+
+// referenceSizes — типовые проемы, на которых владелец оценивает изменение цен:
+// от небольшого окна (упирается в минимальную площадь) до балконного блока.
+var referenceSizes = []WindowSize{
+	{WidthMm: 600, HeightMm: 1200},
+	{WidthMm: 1000, HeightMm: 1400},
+	{WidthMm: 1500, HeightMm: 1600},
+	{WidthMm: 2400, HeightMm: 2000},
+}
+
+type PricingSimulationRequest struct {
+	Config PricingConfig `json:"config"`
+	Sizes  []WindowSize  `json:"sizes,omitempty" validate:"omitempty,max=20,dive"`
+}
+
+type PricingSimulationRow struct {
+	MaterialID   int64   `json:"materialId"`
+	MaterialName string  `json:"materialName"`
+	Category     string  `json:"category"`
+	WidthMm      int     `json:"widthMm"`
+	HeightMm     int     `json:"heightMm"`
+	CurrentPrice float64 `json:"currentPrice"`
+	NewPrice     float64 `json:"newPrice"`
+	Delta        float64 `json:"delta"`
+	DeltaPercent float64 `json:"deltaPercent"`
+}
+
+type PricingSimulationStats struct {
+	Rows            int     `json:"rows"`
+	Increased       int     `json:"increased"`
+	Decreased       int     `json:"decreased"`
+	Unchanged       int     `json:"unchanged"`
+	TotalCurrent    float64 `json:"totalCurrent"`
+	TotalNew        float64 `json:"totalNew"`
+	AvgDelta        float64 `json:"avgDelta"`
+	AvgDeltaPercent float64 `json:"avgDeltaPercent"`
+	MinDeltaPercent float64 `json:"minDeltaPercent"`
+	MaxDeltaPercent float64 `json:"maxDeltaPercent"`
+}
+
+type PricingSimulationResponse struct {
+	Current  PricingConfig          `json:"current"`
+	Proposed PricingConfig          `json:"proposed"`
+	Sizes    []WindowSize           `json:"sizes"`
+	Rows     []PricingSimulationRow `json:"rows"`
+	Stats    PricingSimulationStats `json:"stats"`
+}
+
+func roundTo(v float64, digits int) float64 {
+	p := math.Pow(10, float64(digits))
+	return math.Round(v*p) / p
+}
+
+// simulatePricing пересчитывает каждый материал на эталонных размерах по
+// текущей и предлагаемой конфигурации; ничего не сохраняет.
+func simulatePricing(materials []Material, current, proposed PricingConfig, sizes []WindowSize) PricingSimulationResponse {
+	resp := PricingSimulationResponse{
+		Current:  current,
+		Proposed: proposed,
+		Sizes:    sizes,
+		Rows:     []PricingSimulationRow{},
+	}
+
+	var sumDeltaPercent float64
+	for _, m := range materials {
+		for _, size := range sizes {
+			before := priceWindow(m, current, size.WidthMm, size.HeightMm).Price
+			after := priceWindow(m, proposed, size.WidthMm, size.HeightMm).Price
+			row := PricingSimulationRow{
+				MaterialID:   m.ID,
+				MaterialName: m.Name,
+				Category:     m.Category,
+				WidthMm:      size.WidthMm,
+				HeightMm:     size.HeightMm,
+				CurrentPrice: before,
+				NewPrice:     after,
+				Delta:        after - before,
+			}
+			if before > 0 {
+				row.DeltaPercent = roundTo(row.Delta/before*100, 2)
+			}
+			resp.Rows = append(resp.Rows, row)
+
+			stats := &resp.Stats
+			switch {
+			case row.Delta > 0:
+				stats.Increased++
+			case row.Delta < 0:
+				stats.Decreased++
+			default:
+				stats.Unchanged++
+			}
+			if stats.Rows == 0 || row.DeltaPercent < stats.MinDeltaPercent {
+				stats.MinDeltaPercent = row.DeltaPercent
+			}
+			if stats.Rows == 0 || row.DeltaPercent > stats.MaxDeltaPercent {
+				stats.MaxDeltaPercent = row.DeltaPercent
+			}
+			stats.Rows++
+			stats.TotalCurrent += before
+			stats.TotalNew += after
+			sumDeltaPercent += row.DeltaPercent
+		}
+	}
+
+	if n := float64(resp.Stats.Rows); n > 0 {
+		resp.Stats.AvgDelta = roundTo((resp.Stats.TotalNew-resp.Stats.TotalCurrent)/n, 2)
+		resp.Stats.AvgDeltaPercent = roundTo(sumDeltaPercent/n, 2)
+	}
+	return resp
+}
+
+func (a *App) handleSimulatePricing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in PricingSimulationRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		if len(in.Sizes) == 0 {
+			in.Sizes = referenceSizes
+		}
+
+		current, err := a.Storage.getPricingConfig()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		materials, err := a.Storage.getMaterials()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, simulatePricing(materials, current, in.Config, in.Sizes))
+	}
+}
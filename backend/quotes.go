@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errMaterialNotFound = errors.New("material not found")
+
+type QuoteRequest struct {
+	Windows []PriceEstimateRequest `json:"windows" validate:"required,min=1,max=30,dive"`
+	Address *CustomerAddress       `json:"address,omitempty"`
+}
+
+// QuoteItem хранит цену изделия на момент расчёта вместе с данными материала,
+// чтобы сохранённый расчёт не зависел от последующих изменений каталога.
+type QuoteItem struct {
+	ProductType  ProductType `json:"productType"`
+	MaterialID   int64       `json:"materialId"`
+	MaterialName string      `json:"materialName"`
+	SupplierCode string      `json:"supplierCode"`
+	PricePerM2   float64     `json:"pricePerM2"`
+	WidthMm      int         `json:"widthMm"`
+	HeightMm     int         `json:"heightMm"`
+	AreaM2       float64     `json:"areaM2"`
+	Price        float64     `json:"price"`
+	Breakdown    string      `json:"breakdown"`
+}
+
+type Quote struct {
+	ID            int64         `json:"id"`
+	Token         string        `json:"token"`
+	Request       QuoteRequest  `json:"request"`
+	Items         []QuoteItem   `json:"items"`
+	PricingConfig PricingConfig `json:"pricingConfig"`
+	Zone          string        `json:"zone,omitempty"`
+	OutOfArea     bool          `json:"outOfArea"`
+	Surcharges    []Surcharge   `json:"surcharges"`
+	Promotions    []Promotion   `json:"promotions"`
+	Total         float64       `json:"total"`
+	Currency      string        `json:"currency"`
+	ExpiresAt     time.Time     `json:"expiresAt"`
+	CreatedAt     time.Time     `json:"createdAt"`
+	Expired       bool          `json:"expired"`
+	ShareURL      string        `json:"shareUrl"`
+	PDFURL        string        `json:"pdfUrl"`
+}
+
+func newQuoteToken() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// withDerived заполняет вычисляемые поля, которые не хранятся в базе.
+func (q Quote) withDerived(now time.Time) Quote {
+	q.Expired = !now.Before(q.ExpiresAt)
+	q.ShareURL = "/api/quotes/" + q.Token
+	q.PDFURL = q.ShareURL + "/pdf"
+	return q
+}
+
+// buildQuote рассчитывает все окна по текущим ценам и фиксирует конфигурацию
+// и действующие акции. Выезд по зоне адреса добавляется к итогу один раз на
+// весь расчёт.
+func (a *App) buildQuote(req QuoteRequest) (Quote, error) {
+	config, err := a.Storage.getPricingConfig()
+	if err != nil {
+		return Quote{}, err
+	}
+	promotions, err := a.Storage.getPromotions()
+	if err != nil {
+		return Quote{}, err
+	}
+	zone, err := a.resolveAddress(req.Address)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	quote := Quote{
+		Request:       req,
+		PricingConfig: config,
+		OutOfArea:     zone.OutOfArea,
+		Surcharges:    zone.Surcharges,
+		Promotions:    promotions,
+		Currency:      "₽",
+	}
+	if zone.Zone != nil {
+		quote.Zone = zone.Zone.Name
+	}
+	for _, s := range quote.Surcharges {
+		quote.Total += s.Amount
+	}
+	for _, window := range req.Windows {
+		material, err := a.Storage.findMaterial(window.MaterialID)
+		if err != nil {
+			return Quote{}, err
+		}
+		if material == nil {
+			return Quote{}, fmt.Errorf("%w: %d", errMaterialNotFound, window.MaterialID)
+		}
+
+		estimate := priceWindow(*material, config, window.WidthMm, window.HeightMm)
+		quote.Items = append(quote.Items, QuoteItem{
+			ProductType:  window.ProductType,
+			MaterialID:   material.ID,
+			MaterialName: material.Name,
+			SupplierCode: material.SupplierCode,
+			PricePerM2:   material.PricePerM2,
+			WidthMm:      window.WidthMm,
+			HeightMm:     window.HeightMm,
+			AreaM2:       estimate.AreaM2,
+			Price:        estimate.Price,
+			Breakdown:    estimate.Breakdown,
+		})
+		quote.Total += estimate.Price
+	}
+	return quote, nil
+}
+
+// Quotes
+const quoteColumns = `id, token, request, items, pricing_config, COALESCE(zone, ''), out_of_area, surcharges, promotions, total, currency, expires_at, created_at`
+
+func scanQuote(row interface{ Scan(...any) error }) (Quote, error) {
+	var (
+		q                                                 Quote
+		request, items, configRaw, surcharges, promotions []byte
+	)
+	err := row.Scan(&q.ID, &q.Token, &request, &items, &configRaw, &q.Zone, &q.OutOfArea, &surcharges, &promotions,
+		&q.Total, &q.Currency, &q.ExpiresAt, &q.CreatedAt)
+	if err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal(request, &q.Request); err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal(items, &q.Items); err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal(configRaw, &q.PricingConfig); err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal(surcharges, &q.Surcharges); err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal(promotions, &q.Promotions); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+func (s *DatabaseStore) addQuote(quote Quote) (Quote, error) {
+	request, err := json.Marshal(quote.Request)
+	if err != nil {
+		return quote, err
+	}
+	items, err := json.Marshal(quote.Items)
+	if err != nil {
+		return quote, err
+	}
+	config, err := json.Marshal(quote.PricingConfig)
+	if err != nil {
+		return quote, err
+	}
+	if quote.Surcharges == nil {
+		quote.Surcharges = []Surcharge{}
+	}
+	surcharges, err := json.Marshal(quote.Surcharges)
+	if err != nil {
+		return quote, err
+	}
+	if quote.Promotions == nil {
+		quote.Promotions = []Promotion{}
+	}
+	promotions, err := json.Marshal(quote.Promotions)
+	if err != nil {
+		return quote, err
+	}
+
+	err = s.db.QueryRow(`
+		INSERT INTO quotes (token, request, items, pricing_config, zone, out_of_area, surcharges, promotions, total, currency, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`, quote.Token, request, items, config, quote.Zone, quote.OutOfArea, surcharges, promotions,
+		quote.Total, quote.Currency, quote.ExpiresAt).Scan(&quote.ID, &quote.CreatedAt)
+	return quote, err
+}
+
+func (s *DatabaseStore) findQuoteByToken(token string) (*Quote, error) {
+	q, err := scanQuote(s.db.QueryRow(`SELECT `+quoteColumns+` FROM quotes WHERE token = $1`, token))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &q, err
+}
+
+func (s *DatabaseStore) findQuote(id int64) (*Quote, error) {
+	q, err := scanQuote(s.db.QueryRow(`SELECT `+quoteColumns+` FROM quotes WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &q, err
+}
+
+func (s *DatabaseStore) getQuotes(limit int) ([]Quote, error) {
+	rows, err := s.db.Query(`SELECT `+quoteColumns+` FROM quotes ORDER BY created_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotes []Quote
+	for rows.Next() {
+		q, err := scanQuote(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, rows.Err()
+}
+
+// Handlers
+func (a *App) handleCreateQuote() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in QuoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		quote, err := a.buildQuote(in)
+		if errors.Is(err, errMaterialNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+
+		quote.Token, err = newQuoteToken()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "token generation failed"})
+			return
+		}
+		now := time.Now()
+		quote.ExpiresAt = now.Add(a.Config.QuoteValidity)
+
+		created, err := a.Storage.addQuote(quote)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, created.withDerived(now))
+	}
+}
+
+func (a *App) handleGetQuote() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		quote, err := a.Storage.findQuoteByToken(chi.URLParam(r, "token"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if quote == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "quote not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, quote.withDerived(time.Now()))
+	}
+}
+
+func (a *App) handleListQuotes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		quotes, err := a.Storage.getQuotes(100)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		now := time.Now()
+		for i := range quotes {
+			quotes[i] = quotes[i].withDerived(now)
+		}
+		writeJSON(w, http.StatusOK, quotes)
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	errUnknownRepairService = errors.New("unknown repair service")
+	errRepairNotAvailable   = errors.New("repair service is not available for product type")
+)
+
+// RepairService — услуга ремонта с ценой для каждого типа изделия.
+// Услуга без цены для типа изделия для него не оказывается.
+type RepairService struct {
+	ID          int64                   `json:"id"`
+	Code        string                  `json:"code" validate:"required,max=50"`
+	Name        string                  `json:"name" validate:"required,max=255"`
+	Description string                  `json:"description,omitempty"`
+	Unit        string                  `json:"unit" validate:"required,max=20"`
+	IsCallOut   bool                    `json:"isCallOut"`
+	IsActive    bool                    `json:"isActive"`
+	Order       int                     `json:"order,omitempty"`
+	Prices      map[ProductType]float64 `json:"prices" validate:"required,min=1,dive,keys,oneof=horizontal vertical roller,endkeys,gte=0"`
+}
+
+type RepairDefect struct {
+	ServiceCode string `json:"serviceCode" validate:"required,max=50"`
+	Quantity    int    `json:"quantity" validate:"omitempty,gt=0,lte=100"`
+}
+
+type RepairEstimateRequest struct {
+	ProductType ProductType    `json:"productType" validate:"required,oneof=horizontal vertical roller"`
+	Defects     []RepairDefect `json:"defects" validate:"required,min=1,max=20,dive"`
+}
+
+type RepairEstimateLine struct {
+	ServiceCode string  `json:"serviceCode"`
+	Name        string  `json:"name"`
+	Unit        string  `json:"unit"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unitPrice"`
+	Price       float64 `json:"price"`
+}
+
+type RepairEstimateResponse struct {
+	ProductType ProductType          `json:"productType"`
+	Lines       []RepairEstimateLine `json:"lines"`
+	Price       float64              `json:"price"`
+	Currency    string               `json:"currency"`
+	Breakdown   string               `json:"breakdown"`
+}
+
+// priceRepair считает ремонт по списку неисправностей. Выезд мастера
+// добавляется один раз, даже если его не указали явно.
+func priceRepair(services []RepairService, in RepairEstimateRequest) (RepairEstimateResponse, error) {
+	byCode := make(map[string]RepairService, len(services))
+	for _, svc := range services {
+		byCode[svc.Code] = svc
+	}
+
+	resp := RepairEstimateResponse{ProductType: in.ProductType, Currency: "₽"}
+	addLine := func(svc RepairService, quantity int) error {
+		unitPrice, ok := svc.Prices[in.ProductType]
+		if !ok {
+			return fmt.Errorf("%w: %s", errRepairNotAvailable, svc.Code)
+		}
+		resp.Lines = append(resp.Lines, RepairEstimateLine{
+			ServiceCode: svc.Code,
+			Name:        svc.Name,
+			Unit:        svc.Unit,
+			Quantity:    quantity,
+			UnitPrice:   unitPrice,
+			Price:       unitPrice * float64(quantity),
+		})
+		return nil
+	}
+
+	for _, svc := range services {
+		if svc.IsCallOut {
+			if err := addLine(svc, 1); err != nil {
+				return resp, err
+			}
+		}
+	}
+	for _, defect := range in.Defects {
+		svc, ok := byCode[defect.ServiceCode]
+		if !ok {
+			return resp, fmt.Errorf("%w: %s", errUnknownRepairService, defect.ServiceCode)
+		}
+		if svc.IsCallOut {
+			continue
+		}
+		quantity := defect.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		if err := addLine(svc, quantity); err != nil {
+			return resp, err
+		}
+	}
+
+	parts := make([]string, 0, len(resp.Lines))
+	for _, line := range resp.Lines {
+		resp.Price += line.Price
+		parts = append(parts, fmt.Sprintf("%s: %d × %.0f ₽ = %.0f ₽", line.Name, line.Quantity, line.UnitPrice, line.Price))
+	}
+	resp.Price = math.Round(resp.Price/10) * 10
+	resp.Breakdown = strings.Join(parts, "; ") + fmt.Sprintf(" — итого %.0f ₽", resp.Price)
+	return resp, nil
+}
+
+// Repair services
+func (s *DatabaseStore) getRepairServices(activeOnly bool) ([]RepairService, error) {
+	rows, err := s.db.Query(`
+		SELECT id, code, name, COALESCE(description, ''), unit, is_call_out, is_active, order_index
+		FROM repair_services
+		WHERE is_active = true OR NOT $1
+		ORDER BY order_index, id
+	`, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var services []RepairService
+	index := make(map[int64]int)
+	for rows.Next() {
+		svc := RepairService{Prices: make(map[ProductType]float64)}
+		err := rows.Scan(&svc.ID, &svc.Code, &svc.Name, &svc.Description, &svc.Unit, &svc.IsCallOut, &svc.IsActive, &svc.Order)
+		if err != nil {
+			return nil, err
+		}
+		index[svc.ID] = len(services)
+		services = append(services, svc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	priceRows, err := s.db.Query(`SELECT service_id, product_type, price FROM repair_service_prices`)
+	if err != nil {
+		return nil, err
+	}
+	defer priceRows.Close()
+
+	for priceRows.Next() {
+		var (
+			serviceID   int64
+			productType ProductType
+			price       float64
+		)
+		if err := priceRows.Scan(&serviceID, &productType, &price); err != nil {
+			return nil, err
+		}
+		if i, ok := index[serviceID]; ok {
+			services[i].Prices[productType] = price
+		}
+	}
+	return services, priceRows.Err()
+}
+
+func replaceRepairPrices(tx *sql.Tx, svc RepairService) error {
+	if _, err := tx.Exec(`DELETE FROM repair_service_prices WHERE service_id = $1`, svc.ID); err != nil {
+		return err
+	}
+	for productType, price := range svc.Prices {
+		_, err := tx.Exec(`
+			INSERT INTO repair_service_prices (service_id, product_type, price)
+			VALUES ($1, $2, $3)
+		`, svc.ID, productType, price)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DatabaseStore) addRepairService(svc RepairService) (RepairService, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return svc, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO repair_services (code, name, description, unit, is_call_out, is_active, order_index)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, svc.Code, svc.Name, svc.Description, svc.Unit, svc.IsCallOut, svc.IsActive, svc.Order).Scan(&svc.ID)
+	if err != nil {
+		return svc, err
+	}
+	if err := replaceRepairPrices(tx, svc); err != nil {
+		return svc, err
+	}
+	return svc, tx.Commit()
+}
+
+func (s *DatabaseStore) updateRepairService(svc RepairService) (*RepairService, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE repair_services
+		SET code = $2, name = $3, description = $4, unit = $5, is_call_out = $6, is_active = $7, order_index = $8, updated_at = NOW()
+		WHERE id = $1
+	`, svc.ID, svc.Code, svc.Name, svc.Description, svc.Unit, svc.IsCallOut, svc.IsActive, svc.Order)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	if err := replaceRepairPrices(tx, svc); err != nil {
+		return nil, err
+	}
+	return &svc, tx.Commit()
+}
+
+// Handlers
+func (a *App) handleRepairServices() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		services, err := a.Storage.getRepairServices(true)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, services)
+	}
+}
+
+func (a *App) handleRepairEstimate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in RepairEstimateRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		services, err := a.Storage.getRepairServices(true)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+
+		resp, err := priceRepair(services, in)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (a *App) handleCreateRepairService() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var svc RepairService
+		if err := json.NewDecoder(r.Body).Decode(&svc); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(svc); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		created, err := a.Storage.addRepairService(svc)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func (a *App) handleUpdateRepairService() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid repair service ID"})
+			return
+		}
+
+		var svc RepairService
+		if err := json.NewDecoder(r.Body).Decode(&svc); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(svc); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		svc.ID = id
+		updated, err := a.Storage.updateRepairService(svc)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if updated == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "repair service not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
@@ -0,0 +1,802 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// Причины, по которым отправка формы попадает в карантин
+const (
+	spamHoneypot       = "honeypot"
+	spamNoToken        = "no_form_token"
+	spamBadToken       = "bad_form_token"
+	spamTokenExpired   = "form_token_expired"
+	spamTooFast        = "too_fast"
+	spamPhoneLimit     = "phone_limit"
+	spamIPHourlyLimit  = "ip_hourly_limit"
+	spamIPDailyLimit   = "ip_daily_limit"
+	spamBlockedPhone   = "blocked_phone"
+	spamBlockedIP      = "blocked_ip"
+	spamBlockedKeyword = "blocked_keyword"
+)
+
+const (
+	formLead   = "lead"
+	formReview = "review"
+)
+
+const (
+	quarantinePending  = "pending"
+	quarantineReleased = "released"
+	quarantineRejected = "rejected"
+)
+
+var (
+	errQuarantineNotFound = errors.New("quarantine entry not found")
+	errQuarantineReviewed = errors.New("quarantine entry is already reviewed")
+)
+
+type SpamSettings struct {
+	// MinFillSeconds — быстрее люди форму не заполняют
+	MinFillSeconds int `json:"minFillSeconds" validate:"gte=0,lte=600"`
+	// TokenTTLHours — сколько действует токен формы
+	TokenTTLHours   int `json:"tokenTtlHours" validate:"gt=0,lte=720"`
+	PhoneDailyLimit int `json:"phoneDailyLimit" validate:"gt=0,lte=1000"`
+	IPHourlyLimit   int `json:"ipHourlyLimit" validate:"gt=0,lte=1000"`
+	IPDailyLimit    int `json:"ipDailyLimit" validate:"gt=0,lte=10000"`
+}
+
+var defaultSpamSettings = SpamSettings{
+	MinFillSeconds:  3,
+	TokenTTLHours:   24,
+	PhoneDailyLimit: 3,
+	IPHourlyLimit:   5,
+	IPDailyLimit:    20,
+}
+
+type BlocklistEntry struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind" validate:"required,oneof=phone ip keyword"`
+	Value     string    `json:"value" validate:"required,max=200"`
+	Comment   string    `json:"comment,omitempty" validate:"max=500"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type QuarantineEntry struct {
+	ID         int64           `json:"id"`
+	Form       string          `json:"form"`
+	Payload    json.RawMessage `json:"payload"`
+	IP         string          `json:"ip,omitempty"`
+	Phone      string          `json:"phone,omitempty"`
+	Reasons    []string        `json:"reasons"`
+	Status     string          `json:"status"`
+	ResultID   *int64          `json:"resultId,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	ReviewedAt *time.Time      `json:"reviewedAt,omitempty"`
+}
+
+// FormSubmission — то, что проверяется в отправке формы
+type FormSubmission struct {
+	Form      string
+	IP        string
+	Phone     string
+	Text      string
+	Honeypot  string
+	FormToken string
+}
+
+// FormTokenResponse — токен выдается при открытии формы и возвращается
+// вместе с ней; по нему сервер узнает, сколько форма заполнялась
+type FormTokenResponse struct {
+	Token    string    `json:"token"`
+	IssuedAt time.Time `json:"issuedAt"`
+}
+
+// newFormSecret — ключ подписи на время работы процесса, если
+// FORM_TOKEN_SECRET не задан. После перезапуска старые токены недействительны.
+func newFormSecret() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func formTokenMAC(secret string, issued int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "form:%d", issued)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signFormToken — токен вида <unix-время выдачи>.<подпись>
+func signFormToken(secret string, issued time.Time) string {
+	ts := issued.Unix()
+	return strconv.FormatInt(ts, 10) + "." + formTokenMAC(secret, ts)
+}
+
+// parseFormToken проверяет подпись и возвращает время выдачи
+func parseFormToken(secret, token string) (time.Time, bool) {
+	tsPart, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return time.Time{}, false
+	}
+	ts, err := strconv.ParseInt(tsPart, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if !hmac.Equal([]byte(sig), []byte(formTokenMAC(secret, ts))) {
+		return time.Time{}, false
+	}
+	return time.Unix(ts, 0), true
+}
+
+// clientIP — адрес посетителя. X-Forwarded-For учитывается, только если
+// запрос пришел от доверенного прокси (TRUSTED_PROXIES): тогда берется
+// последний адрес цепочки, не принадлежащий доверенным прокси. Иначе
+// заголовок мог подставить сам клиент и используется адрес соединения.
+func (a *App) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !a.Config.trustedProxy(host) {
+		return host
+	}
+	parts := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(parts) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(parts[i])
+		if ip == "" {
+			continue
+		}
+		if !a.Config.trustedProxy(ip) {
+			return ip
+		}
+		host = ip
+	}
+	return host
+}
+
+// trustedProxy — входит ли адрес в TRUSTED_PROXIES
+func (c AppConfig) trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range c.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies разбирает список адресов и подсетей через запятую.
+// Одиночный адрес считается подсетью из одного адреса.
+func parseTrustedProxies(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// normalizeBlocklistValue приводит значение к виду, в котором оно
+// сравнивается с отправкой: телефон — +7XXXXXXXXXX, слово — строчными
+func normalizeBlocklistValue(kind, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case "phone":
+		return normalizePhone(value)
+	case "ip":
+		if net.ParseIP(value) == nil {
+			return "", errors.New("invalid ip")
+		}
+		return value, nil
+	default:
+		return strings.ToLower(value), nil
+	}
+}
+
+// Spam settings
+func (s *DatabaseStore) getSpamSettings() (SpamSettings, error) {
+	var settings SpamSettings
+	err := s.db.QueryRow(`
+		SELECT min_fill_seconds, token_ttl_hours, phone_daily_limit, ip_hourly_limit, ip_daily_limit
+		FROM spam_settings
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&settings.MinFillSeconds, &settings.TokenTTLHours, &settings.PhoneDailyLimit,
+		&settings.IPHourlyLimit, &settings.IPDailyLimit)
+	if err == sql.ErrNoRows {
+		return defaultSpamSettings, nil
+	}
+	return settings, err
+}
+
+func (s *DatabaseStore) updateSpamSettings(settings SpamSettings) error {
+	_, err := s.db.Exec(`
+		INSERT INTO spam_settings (min_fill_seconds, token_ttl_hours, phone_daily_limit, ip_hourly_limit, ip_daily_limit)
+		VALUES ($1, $2, $3, $4, $5)
+	`, settings.MinFillSeconds, settings.TokenTTLHours, settings.PhoneDailyLimit, settings.IPHourlyLimit, settings.IPDailyLimit)
+	return err
+}
+
+// Blocklist
+func (s *DatabaseStore) getBlocklist() ([]BlocklistEntry, error) {
+	rows, err := s.db.Query("SELECT id, kind, value, comment, created_at FROM spam_blocklist ORDER BY kind, value")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []BlocklistEntry{}
+	for rows.Next() {
+		var e BlocklistEntry
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Value, &e.Comment, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// addBlocklistEntry добавляет запись; повторное добавление обновляет комментарий
+func (s *DatabaseStore) addBlocklistEntry(e BlocklistEntry) (BlocklistEntry, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO spam_blocklist (kind, value, comment)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind, value) DO UPDATE SET comment = EXCLUDED.comment
+		RETURNING id, created_at
+	`, e.Kind, e.Value, e.Comment).Scan(&e.ID, &e.CreatedAt)
+	return e, err
+}
+
+func (s *DatabaseStore) deleteBlocklistEntry(id int64) error {
+	_, err := s.db.Exec("DELETE FROM spam_blocklist WHERE id = $1", id)
+	return err
+}
+
+// blockedReasons сверяет телефон, адрес и текст с черным списком
+func (s *DatabaseStore) blockedReasons(sub FormSubmission) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT kind, value FROM spam_blocklist
+		WHERE (kind = 'phone' AND value = $1) OR (kind = 'ip' AND value = $2) OR kind = 'keyword'
+	`, sub.Phone, sub.IP)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	text := strings.ToLower(sub.Text)
+	seen := map[string]bool{}
+	var reasons []string
+	for rows.Next() {
+		var kind, value string
+		if err := rows.Scan(&kind, &value); err != nil {
+			return nil, err
+		}
+		reason := ""
+		switch {
+		case kind == "phone" && sub.Phone != "":
+			reason = spamBlockedPhone
+		case kind == "ip" && sub.IP != "":
+			reason = spamBlockedIP
+		case kind == "keyword" && value != "" && strings.Contains(text, value):
+			reason = spamBlockedKeyword
+		}
+		if reason != "" && !seen[reason] {
+			seen[reason] = true
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons, rows.Err()
+}
+
+// recordSubmission учитывает отправку и возвращает, сколько отправок
+// было до нее: с этого телефона за сутки, с этого адреса за час и за сутки.
+// Подсчет и запись идут под блокировкой телефона и адреса, чтобы
+// одновременные отправки не проходили лимит вместе
+func (s *DatabaseStore) recordSubmission(sub FormSubmission) (phoneDay, ipHour, ipDay int, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	var keys []string
+	if sub.Phone != "" {
+		keys = append(keys, "form_submissions:phone:"+sub.Phone)
+	}
+	if sub.IP != "" {
+		keys = append(keys, "form_submissions:ip:"+sub.IP)
+	}
+	// Один порядок блокировок во всех транзакциях
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err = tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", key); err != nil {
+			return
+		}
+	}
+
+	err = tx.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE $1 <> '' AND phone = $1 AND created_at > NOW() - INTERVAL '1 day'),
+			COUNT(*) FILTER (WHERE $2 <> '' AND ip = $2 AND created_at > NOW() - INTERVAL '1 hour'),
+			COUNT(*) FILTER (WHERE $2 <> '' AND ip = $2 AND created_at > NOW() - INTERVAL '1 day')
+		FROM form_submissions
+		WHERE created_at > NOW() - INTERVAL '1 day' AND (phone = $1 OR ip = $2)
+	`, sub.Phone, sub.IP).Scan(&phoneDay, &ipHour, &ipDay)
+	if err != nil {
+		return
+	}
+	if _, err = tx.Exec("INSERT INTO form_submissions (form, ip, phone) VALUES ($1, $2, $3)", sub.Form, sub.IP, sub.Phone); err != nil {
+		return
+	}
+	err = tx.Commit()
+	return
+}
+
+// purgeFormSubmissions удаляет отправки, которые уже не нужны для лимитов
+func (s *DatabaseStore) purgeFormSubmissions() error {
+	_, err := s.db.Exec("DELETE FROM form_submissions WHERE created_at < NOW() - INTERVAL '2 days'")
+	return err
+}
+
+// Quarantine
+const quarantineColumns = `id, form, payload, ip, phone, reasons, status, result_id, created_at, reviewed_at`
+
+func scanQuarantineEntry(row interface{ Scan(...any) error }) (QuarantineEntry, error) {
+	var (
+		e       QuarantineEntry
+		payload []byte
+	)
+	err := row.Scan(&e.ID, &e.Form, &payload, &e.IP, &e.Phone, pq.Array(&e.Reasons), &e.Status, &e.ResultID,
+		&e.CreatedAt, &e.ReviewedAt)
+	e.Payload = payload
+	return e, err
+}
+
+func (s *DatabaseStore) addQuarantineEntry(e QuarantineEntry) (QuarantineEntry, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO spam_quarantine (form, payload, ip, phone, reasons)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at
+	`, e.Form, []byte(e.Payload), e.IP, e.Phone, pq.Array(e.Reasons)).Scan(&e.ID, &e.Status, &e.CreatedAt)
+	return e, err
+}
+
+func (s *DatabaseStore) findQuarantineEntry(id int64) (*QuarantineEntry, error) {
+	e, err := scanQuarantineEntry(s.db.QueryRow("SELECT "+quarantineColumns+" FROM spam_quarantine WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *DatabaseStore) getQuarantine(status, form string, limit, offset int) ([]QuarantineEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT `+quarantineColumns+` FROM spam_quarantine
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR form = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, status, form, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []QuarantineEntry{}
+	for rows.Next() {
+		e, err := scanQuarantineEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// closeQuarantineEntry отмечает решение по записи. Решение принимается
+// один раз: для уже разобранной записи возвращается errQuarantineReviewed.
+func (s *DatabaseStore) closeQuarantineEntry(id int64, status string, resultID *int64) error {
+	res, err := s.db.Exec(`
+		UPDATE spam_quarantine SET status = $2, result_id = $3, reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`, id, status, resultID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errQuarantineReviewed
+	}
+	return nil
+}
+
+// releaseQuarantineEntry занимает ожидающую запись и в той же транзакции
+// создает по ней заявку или отзыв. Если запись уже разобрана параллельным
+// запросом, возвращается errQuarantineReviewed и ничего не создается.
+func (s *DatabaseStore) releaseQuarantineEntry(id int64, create func(tx *sql.Tx) (int64, error)) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var claimed int64
+	err = tx.QueryRow(`
+		UPDATE spam_quarantine SET status = 'released', reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING id
+	`, id).Scan(&claimed)
+	if err == sql.ErrNoRows {
+		return 0, errQuarantineReviewed
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	resultID, err := create(tx)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE spam_quarantine SET result_id = $2 WHERE id = $1", id, resultID); err != nil {
+		return 0, err
+	}
+	return resultID, tx.Commit()
+}
+
+// screenSubmission проверяет отправку формы и возвращает причины, по которым
+// она подозрительна. Пустой список — отправку можно принимать.
+func (a *App) screenSubmission(sub FormSubmission) ([]string, error) {
+	settings, err := a.Storage.getSpamSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	var reasons []string
+	if strings.TrimSpace(sub.Honeypot) != "" {
+		reasons = append(reasons, spamHoneypot)
+	}
+	if sub.FormToken == "" {
+		reasons = append(reasons, spamNoToken)
+	} else if issued, ok := parseFormToken(a.Config.FormTokenSecret, sub.FormToken); !ok {
+		reasons = append(reasons, spamBadToken)
+	} else if age := time.Since(issued); age < time.Duration(settings.MinFillSeconds)*time.Second {
+		reasons = append(reasons, spamTooFast)
+	} else if age > time.Duration(settings.TokenTTLHours)*time.Hour {
+		reasons = append(reasons, spamTokenExpired)
+	}
+
+	phoneDay, ipHour, ipDay, err := a.Storage.recordSubmission(sub)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Phone != "" && phoneDay >= settings.PhoneDailyLimit {
+		reasons = append(reasons, spamPhoneLimit)
+	}
+	if ipHour >= settings.IPHourlyLimit {
+		reasons = append(reasons, spamIPHourlyLimit)
+	}
+	if ipDay >= settings.IPDailyLimit {
+		reasons = append(reasons, spamIPDailyLimit)
+	}
+
+	blocked, err := a.Storage.blockedReasons(sub)
+	if err != nil {
+		return nil, err
+	}
+	return append(reasons, blocked...), nil
+}
+
+// quarantineSubmission сохраняет подозрительную отправку для проверки.
+// Посетитель получает обычный ответ «принято», чтобы боты не подбирали обход.
+func (a *App) quarantineSubmission(w http.ResponseWriter, sub FormSubmission, reasons []string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+	_, err = a.Storage.addQuarantineEntry(QuarantineEntry{
+		Form:    sub.Form,
+		Payload: data,
+		IP:      sub.IP,
+		Phone:   sub.Phone,
+		Reasons: reasons,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "received"})
+}
+
+// releaseQuarantined создает заявку или отзыв из записи карантина в обход
+// проверок и возвращает ID созданного. Запись закрывается в той же
+// транзакции, поэтому повторный выпуск ничего не дублирует.
+func (a *App) releaseQuarantined(e QuarantineEntry) (int64, error) {
+	switch e.Form {
+	case formLead:
+		var in LeadRequest
+		if err := json.Unmarshal(e.Payload, &in); err != nil {
+			return 0, err
+		}
+		lead, err := a.buildLead(in)
+		if err != nil {
+			return 0, err
+		}
+		settings, err := a.Storage.getLeadSettings()
+		if err != nil {
+			return 0, err
+		}
+		var created Lead
+		id, err := a.Storage.releaseQuarantineEntry(e.ID, func(tx *sql.Tx) (int64, error) {
+			var err error
+			created, err = addLeadTx(tx, settings, lead)
+			return created.ID, err
+		})
+		if err != nil {
+			return 0, err
+		}
+		a.notifyLead(created)
+		return id, nil
+	case formReview:
+		var in ReviewRequest
+		if err := json.Unmarshal(e.Payload, &in); err != nil {
+			return 0, err
+		}
+		return a.Storage.releaseQuarantineEntry(e.ID, func(tx *sql.Tx) (int64, error) {
+			created, err := addReviewTx(tx, in.review())
+			return created.ID, err
+		})
+	}
+	return 0, fmt.Errorf("unknown form %q", e.Form)
+}
+
+// Handlers
+func (a *App) handleFormToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, FormTokenResponse{Token: signFormToken(a.Config.FormTokenSecret, now), IssuedAt: now})
+	}
+}
+
+func (a *App) handleGetSpamSettings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings, err := a.Storage.getSpamSettings()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+	}
+}
+
+func (a *App) handleUpdateSpamSettings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var settings SpamSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(settings); err != nil || settings.IPDailyLimit < settings.IPHourlyLimit {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		if err := a.Storage.updateSpamSettings(settings); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+	}
+}
+
+func (a *App) handleGetBlocklist() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries, err := a.Storage.getBlocklist()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+func (a *App) handleAddBlocklistEntry() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var e BlocklistEntry
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(e); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+		value, err := normalizeBlocklistValue(e.Kind, e.Value)
+		if err != nil || value == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + e.Kind})
+			return
+		}
+		e.Value = value
+
+		created, err := a.Storage.addBlocklistEntry(e)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func (a *App) handleDeleteBlocklistEntry() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid blocklist ID"})
+			return
+		}
+
+		if err := a.Storage.deleteBlocklistEntry(id); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// handleListQuarantine отдает записи карантина; по умолчанию — ожидающие
+// решения. Параметры: status (pending, released, rejected, all), form, limit, offset.
+func (a *App) handleListQuarantine() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		status := q.Get("status")
+		switch status {
+		case "":
+			status = quarantinePending
+		case "all":
+			status = ""
+		case quarantinePending, quarantineReleased, quarantineRejected:
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid status"})
+			return
+		}
+		form := q.Get("form")
+		if form != "" && form != formLead && form != formReview {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
+			return
+		}
+		limit, offset := 50, 0
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 500 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		if v := q.Get("offset"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+				return
+			}
+			offset = n
+		}
+
+		entries, err := a.Storage.getQuarantine(status, form, limit, offset)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+func (a *App) handleReleaseQuarantine() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := a.pendingQuarantineEntry(w, r)
+		if !ok {
+			return
+		}
+
+		resultID, err := a.releaseQuarantined(*entry)
+		switch {
+		case errors.Is(err, errQuarantineReviewed):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		case errors.Is(err, errInvalidPhone), errors.Is(err, errQuoteNotFound), errors.Is(err, errMaterialNotFound):
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": quarantineReleased, "form": entry.Form, "resultId": resultID})
+	}
+}
+
+func (a *App) handleRejectQuarantine() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := a.pendingQuarantineEntry(w, r)
+		if !ok {
+			return
+		}
+
+		err := a.Storage.closeQuarantineEntry(entry.ID, quarantineRejected, nil)
+		if errors.Is(err, errQuarantineReviewed) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": quarantineRejected})
+	}
+}
+
+// pendingQuarantineEntry находит запись из URL и пишет ошибку, если записи
+// нет или по ней уже принято решение
+func (a *App) pendingQuarantineEntry(w http.ResponseWriter, r *http.Request) (*QuarantineEntry, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid quarantine ID"})
+		return nil, false
+	}
+	entry, err := a.Storage.findQuarantineEntry(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+		return nil, false
+	}
+	if entry == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": errQuarantineNotFound.Error()})
+		return nil, false
+	}
+	if entry.Status != quarantinePending {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": errQuarantineReviewed.Error()})
+		return nil, false
+	}
+	return entry, true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var errTelegramDisabled = errors.New("telegram bot token is not configured")
+
+var telegramClient = &http.Client{Timeout: 10 * time.Second}
+
+// notifyTelegram отправляет текст всем подписчикам бота. Ошибка возвращается,
+// если не удалось доставить хотя бы одному подписчику.
+func (a *App) notifyTelegram(text string) error {
+	if a.Config.TelegramBotToken == "" {
+		return errTelegramDisabled
+	}
+	subscribers, err := a.Storage.getTelegramSubscribers()
+	if err != nil {
+		return err
+	}
+
+	var failed []int64
+	for _, chatID := range subscribers {
+		if err := sendTelegramMessage(a.Config.TelegramBotToken, chatID, text); err != nil {
+			failed = append(failed, chatID)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("telegram send failed for chats %v", failed)
+	}
+	return nil
+}
+
+// telegramMaxRunes — предел длины сообщения Telegram с запасом
+const telegramMaxRunes = 4000
+
+func sendTelegramMessage(token string, chatID int64, text string) error {
+	if runes := []rune(text); len(runes) > telegramMaxRunes {
+		text = string(runes[:telegramMaxRunes]) + "…"
+	}
+	body, err := json.Marshal(map[string]any{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := telegramClient.Post("https://api.telegram.org/bot"+token+"/sendMessage", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sendMessage: status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// ServiceZone описывает зону выезда. Адрес попадает в зону по названию
+// района или населённого пункта, по началу почтового индекса или по
+// координатам внутри многоугольника. Зоны проверяются по возрастанию priority.
+type ServiceZone struct {
+	ID               int64        `json:"id"`
+	Name             string       `json:"name" validate:"required,max=100"`
+	Districts        []string     `json:"districts"`
+	PostcodePrefixes []string     `json:"postcodePrefixes" validate:"dive,numeric,max=6"`
+	Polygon          [][2]float64 `json:"polygon,omitempty" validate:"omitempty,min=3"`
+	MeasurementFee   float64      `json:"measurementFee" validate:"gte=0"`
+	InstallationFee  float64      `json:"installationFee" validate:"gte=0"`
+	OutOfArea        bool         `json:"outOfArea"`
+	Priority         int          `json:"priority"`
+	IsActive         bool         `json:"isActive"`
+}
+
+// CustomerAddress — адрес клиента в расчётах и заявках. Координаты
+// передаются фронтендом, если адрес выбран на карте.
+type CustomerAddress struct {
+	Address  string   `json:"address" validate:"max=500"`
+	Postcode string   `json:"postcode,omitempty" validate:"omitempty,numeric,len=6"`
+	Lat      *float64 `json:"lat,omitempty" validate:"omitempty,gte=-90,lte=90"`
+	Lon      *float64 `json:"lon,omitempty" validate:"omitempty,gte=-180,lte=180"`
+}
+
+// Surcharge — дополнительная строка расчёта сверх стоимости изделий.
+type Surcharge struct {
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+}
+
+type ZoneResolution struct {
+	Zone       *ServiceZone `json:"zone"`
+	OutOfArea  bool         `json:"outOfArea"`
+	Surcharges []Surcharge  `json:"surcharges"`
+}
+
+var postcodePattern = regexp.MustCompile(`(^|\D)(\d{6})(\D|$)`)
+
+func (addr CustomerAddress) postcode() string {
+	if addr.Postcode != "" {
+		return addr.Postcode
+	}
+	if m := postcodePattern.FindStringSubmatch(addr.Address); m != nil {
+		return m[2]
+	}
+	return ""
+}
+
+func normalizeAddress(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+}
+
+// containsWord ищет название целиком, чтобы «Пушкин» не совпадал
+// с «Пушкинской улицей».
+func containsWord(text, word string) bool {
+	if word == "" {
+		return false
+	}
+	for start := 0; ; {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !unicode.IsLetter(before) && !unicode.IsLetter(after) {
+			return true
+		}
+		start = end
+	}
+}
+
+// pointInPolygon — проверка лучом; вершины заданы как [широта, долгота].
+func pointInPolygon(lat, lon float64, polygon [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		yi, xi := polygon[i][0], polygon[i][1]
+		yj, xj := polygon[j][0], polygon[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (z ServiceZone) matches(addr CustomerAddress) bool {
+	if addr.Lat != nil && addr.Lon != nil && len(z.Polygon) >= 3 && pointInPolygon(*addr.Lat, *addr.Lon, z.Polygon) {
+		return true
+	}
+	if postcode := addr.postcode(); postcode != "" {
+		for _, prefix := range z.PostcodePrefixes {
+			if strings.HasPrefix(postcode, prefix) {
+				return true
+			}
+		}
+	}
+	text := normalizeAddress(addr.Address)
+	for _, district := range z.Districts {
+		if containsWord(text, normalizeAddress(district)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveZone возвращает первую подходящую зону; zones должны быть
+// отсортированы по приоритету.
+func resolveZone(zones []ServiceZone, addr CustomerAddress) *ServiceZone {
+	for i := range zones {
+		if zones[i].matches(addr) {
+			return &zones[i]
+		}
+	}
+	return nil
+}
+
+func zoneResolution(zone *ServiceZone) ZoneResolution {
+	res := ZoneResolution{Zone: zone}
+	if zone == nil {
+		return res
+	}
+	res.OutOfArea = zone.OutOfArea
+	if zone.MeasurementFee > 0 {
+		res.Surcharges = append(res.Surcharges, Surcharge{Label: "Выезд на замер (" + zone.Name + ")", Amount: zone.MeasurementFee})
+	}
+	if zone.InstallationFee > 0 {
+		res.Surcharges = append(res.Surcharges, Surcharge{Label: "Выезд на монтаж (" + zone.Name + ")", Amount: zone.InstallationFee})
+	}
+	return res
+}
+
+// resolveAddress находит зону по адресу; пустой адрес зоны не имеет.
+func (a *App) resolveAddress(addr *CustomerAddress) (ZoneResolution, error) {
+	if addr == nil || (strings.TrimSpace(addr.Address) == "" && addr.Postcode == "" && (addr.Lat == nil || addr.Lon == nil)) {
+		return ZoneResolution{}, nil
+	}
+	zones, err := a.Storage.getServiceZones(true)
+	if err != nil {
+		return ZoneResolution{}, err
+	}
+	return zoneResolution(resolveZone(zones, *addr)), nil
+}
+
+// applySurcharges добавляет выезд к цене и строке расчёта.
+func applySurcharges(resp *PriceEstimateResponse, res ZoneResolution) {
+	if res.Zone != nil {
+		resp.Zone = res.Zone.Name
+	}
+	resp.OutOfArea = res.OutOfArea
+	resp.Surcharges = res.Surcharges
+	for _, s := range res.Surcharges {
+		resp.Price += s.Amount
+		resp.Breakdown += fmt.Sprintf("\n+ %.0f ₽ — %s", s.Amount, s.Label)
+	}
+	if len(res.Surcharges) > 0 {
+		resp.Breakdown += fmt.Sprintf("\nИтого с выездом: %.0f ₽", resp.Price)
+	}
+	if res.OutOfArea {
+		resp.Breakdown += "\nАдрес вне основной зоны обслуживания — выезд по согласованию"
+	}
+}
+
+// Service zones
+func scanServiceZone(row interface{ Scan(...any) error }) (ServiceZone, error) {
+	var (
+		z       ServiceZone
+		polygon []byte
+	)
+	err := row.Scan(&z.ID, &z.Name, pq.Array(&z.Districts), pq.Array(&z.PostcodePrefixes), &polygon,
+		&z.MeasurementFee, &z.InstallationFee, &z.OutOfArea, &z.Priority, &z.IsActive)
+	if err != nil {
+		return z, err
+	}
+	if len(polygon) > 0 {
+		if err := json.Unmarshal(polygon, &z.Polygon); err != nil {
+			return z, err
+		}
+	}
+	return z, nil
+}
+
+// zoneParams приводит поля зоны к виду для записи: пустые списки
+// сохраняются как '{}', отсутствующий многоугольник — как NULL.
+func zoneParams(z ServiceZone) (districts, postcodes pq.StringArray, polygon any, err error) {
+	districts, postcodes = pq.StringArray{}, pq.StringArray{}
+	districts = append(districts, z.Districts...)
+	postcodes = append(postcodes, z.PostcodePrefixes...)
+	if len(z.Polygon) > 0 {
+		polygon, err = json.Marshal(z.Polygon)
+	}
+	return districts, postcodes, polygon, err
+}
+
+func (s *DatabaseStore) getServiceZones(activeOnly bool) ([]ServiceZone, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, districts, postcode_prefixes, polygon, measurement_fee, installation_fee, out_of_area, priority, is_active
+		FROM service_zones
+		WHERE is_active = true OR NOT $1
+		ORDER BY priority, id
+	`, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zones []ServiceZone
+	for rows.Next() {
+		z, err := scanServiceZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+func (s *DatabaseStore) addServiceZone(z ServiceZone) (ServiceZone, error) {
+	districts, postcodes, polygon, err := zoneParams(z)
+	if err != nil {
+		return z, err
+	}
+	err = s.db.QueryRow(`
+		INSERT INTO service_zones (name, districts, postcode_prefixes, polygon, measurement_fee, installation_fee, out_of_area, priority, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, z.Name, districts, postcodes, polygon,
+		z.MeasurementFee, z.InstallationFee, z.OutOfArea, z.Priority, z.IsActive).Scan(&z.ID)
+	return z, err
+}
+
+func (s *DatabaseStore) updateServiceZone(z ServiceZone) (*ServiceZone, error) {
+	districts, postcodes, polygon, err := zoneParams(z)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.db.Exec(`
+		UPDATE service_zones
+		SET name = $2, districts = $3, postcode_prefixes = $4, polygon = $5, measurement_fee = $6,
+		    installation_fee = $7, out_of_area = $8, priority = $9, is_active = $10, updated_at = NOW()
+		WHERE id = $1
+	`, z.ID, z.Name, districts, postcodes, polygon,
+		z.MeasurementFee, z.InstallationFee, z.OutOfArea, z.Priority, z.IsActive)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return &z, nil
+}
+
+func (s *DatabaseStore) deleteServiceZone(id int64) error {
+	_, err := s.db.Exec("DELETE FROM service_zones WHERE id = $1", id)
+	return err
+}
+
+// Handlers
+func (a *App) handleGetServiceZones() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zones, err := a.Storage.getServiceZones(false)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, zones)
+	}
+}
+
+func (a *App) handleResolveZone() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in CustomerAddress
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		res, err := a.resolveAddress(&in)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	}
+}
+
+func (a *App) handleCreateServiceZone() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var z ServiceZone
+		if err := json.NewDecoder(r.Body).Decode(&z); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(z); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		created, err := a.Storage.addServiceZone(z)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func (a *App) handleUpdateServiceZone() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid zone ID"})
+			return
+		}
+
+		var z ServiceZone
+		if err := json.NewDecoder(r.Body).Decode(&z); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+
+		if err := a.Validate.Struct(z); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "validation failed"})
+			return
+		}
+
+		z.ID = id
+		updated, err := a.Storage.updateServiceZone(z)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		if updated == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "zone not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func (a *App) handleDeleteServiceZone() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid zone ID"})
+			return
+		}
+
+		if err := a.Storage.deleteServiceZone(id); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
@@ -0,0 +1,22 @@
+import { NextResponse } from 'next/server'
+
+// GET — токен формы заявки или отзыва; без него бэкенд отправит заявку в карантин
+export async function GET() {
+  try {
+    if (!process.env.API_BASE_URL) {
+      return NextResponse.json({ error: 'API not configured' }, { status: 500 })
+    }
+
+    const res = await fetch(`${process.env.API_BASE_URL}/api/forms/token`, { cache: 'no-store' })
+
+    const text = await res.text()
+    try {
+      const json = JSON.parse(text)
+      return NextResponse.json(json, { status: res.status, headers: { 'Cache-Control': 'no-store' } })
+    } catch {
+      return NextResponse.json({ error: 'Bad upstream response' }, { status: 502 })
+    }
+  } catch {
+    return NextResponse.json({ error: 'Failed to get form token' }, { status: 500 })
+  }
+}
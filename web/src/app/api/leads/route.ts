@@ -1,104 +1,33 @@
 import { NextRequest, NextResponse } from 'next/server'
 
-import { getTelegramSubscribers } from '@/lib/telegram-subscribers'
-
-type LeadKind = 'request' | 'measure'
-
-type LeadPayload = {
-  kind: LeadKind
-  name: string
-  phone: string
-  comment?: string
-  pageUrl?: string
-}
-
-function requireEnv(name: string): string {
-  const v = process.env[name]
-  if (!v) throw new Error(`Missing env: ${name}`)
-  return v
-}
-
-function escapeTelegram(text: string): string {
-  return text.replace(/[_*\[\]()~`>#+\-=|{}.!]/g, '\\$&')
-}
+import { clientIp } from '@/lib/client-ip'
 
+// POST — заявка с сайта. Сохраняет ее, проверяет на спам и рассылает
+// уведомления бэкенд; маршрут только передает ему адрес посетителя.
 export async function POST(req: NextRequest) {
   try {
-    const token = requireEnv('TELEGRAM_BOT_TOKEN')
-    const subscribers = await getTelegramSubscribers()
-
-    const payload = (await req.json()) as Partial<LeadPayload>
-
-    const kind = payload.kind
-    const name = (payload.name ?? '').trim()
-    const phone = (payload.phone ?? '').trim()
-    const comment = (payload.comment ?? '').trim()
-    const pageUrl = (payload.pageUrl ?? '').trim()
-
-    if (kind !== 'request' && kind !== 'measure') {
-      return NextResponse.json({ error: 'Invalid kind' }, { status: 400 })
+    if (!process.env.API_BASE_URL) {
+      return NextResponse.json({ error: 'API not configured' }, { status: 500 })
     }
 
-    if (!name || !phone) {
-      return NextResponse.json({ error: 'Name and phone are required' }, { status: 400 })
+    const headers: Record<string, string> = { 'Content-Type': 'application/json' }
+    const ip = clientIp(req)
+    if (ip) headers['X-Forwarded-For'] = ip
+
+    const res = await fetch(`${process.env.API_BASE_URL}/api/leads`, {
+      method: 'POST',
+      headers,
+      body: await req.text(),
+    })
+
+    const text = await res.text()
+    try {
+      const json = JSON.parse(text)
+      return NextResponse.json(json, { status: res.status })
+    } catch {
+      return NextResponse.json({ error: 'Bad upstream response' }, { status: 502 })
     }
-
-    const title = kind === 'measure' ? 'Вызов замерщика' : 'Заявка'
-
-    const lines: string[] = []
-    lines.push(`*${escapeTelegram(title)}*`)
-    lines.push('')
-    lines.push(`*Имя:* ${escapeTelegram(name)}`)
-    lines.push(`*Телефон:* ${escapeTelegram(phone)}`)
-    if (comment) lines.push(`*Комментарий:* ${escapeTelegram(comment)}`)
-    if (pageUrl) lines.push(`*Страница:* ${escapeTelegram(pageUrl)}`)
-
-    const text = lines.join('\n')
-
-    if (subscribers.length === 0) {
-      return NextResponse.json(
-        { error: 'No Telegram subscribers. Open the bot and press /start to subscribe.' },
-        { status: 409 },
-      )
-    }
-
-    const results = await Promise.allSettled(
-      subscribers.map(async (chatId) => {
-        const res = await fetch(`https://api.telegram.org/bot${token}/sendMessage`, {
-          method: 'POST',
-          headers: { 'Content-Type': 'application/json' },
-          body: JSON.stringify({
-            chat_id: chatId,
-            text,
-            parse_mode: 'MarkdownV2',
-            disable_web_page_preview: true,
-          }),
-        })
-
-        if (!res.ok) {
-          const details = await res.text().catch(() => '')
-          throw new Error(details || 'sendMessage failed')
-        }
-      }),
-    )
-
-    const failed = results
-      .map((r, i) => ({ r, chatId: subscribers[i] }))
-      .filter((x) => x.r.status === 'rejected')
-
-    if (failed.length > 0) {
-      return NextResponse.json(
-        {
-          error: 'Telegram send partially failed',
-          failedChatIds: failed.map((f) => f.chatId),
-        },
-        { status: 502 },
-      )
-    }
-
-    return NextResponse.json({ success: true })
-  } catch (e) {
-    const msg = e instanceof Error ? e.message : 'Failed to send lead'
-    return NextResponse.json({ error: msg }, { status: 500 })
+  } catch {
+    return NextResponse.json({ error: 'Failed to send lead' }, { status: 500 })
   }
 }
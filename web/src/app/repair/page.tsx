@@ -136,7 +136,7 @@ export default function RepairPage() {
           </p>
           <div className="flex flex-col sm:flex-row gap-4 justify-center">
             <OpenRequestModalButton
-              kind="request"
+              kind="repair"
               className="bg-white text-slate-900 px-8 py-4 rounded-full font-medium hover:bg-slate-100 transition-colors duration-200"
             >
               {primaryText}
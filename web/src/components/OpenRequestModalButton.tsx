@@ -1,10 +1,10 @@
 'use client'
 
 import React from 'react'
-import { useRequestModal } from '@/components/RequestModalProvider'
+import { useRequestModal, type RequestModalKind } from '@/components/RequestModalProvider'
 
 type Props = {
-  kind?: 'request' | 'measure'
+  kind?: RequestModalKind
   className?: string
   children: React.ReactNode
 }
@@ -1,8 +1,31 @@
 'use client'
 
-import React, { createContext, useCallback, useContext, useMemo, useState } from 'react'
+import React, { createContext, useCallback, useContext, useEffect, useMemo, useState } from 'react'
 
-type RequestModalKind = 'request' | 'measure'
+import { captureAttribution, getAttribution } from '@/lib/attribution'
+
+export type RequestModalKind = 'request' | 'measure' | 'repair'
+
+type RepairProductType = 'horizontal' | 'vertical' | 'roller'
+
+type RepairService = {
+  code: string
+  name: string
+  unit: string
+  isCallOut: boolean
+  prices: Partial<Record<RepairProductType, number>>
+}
+
+type RepairEstimate = {
+  price: number
+  breakdown: string
+}
+
+const repairProductTypes: { value: RepairProductType; label: string }[] = [
+  { value: 'horizontal', label: 'Горизонтальные жалюзи' },
+  { value: 'vertical', label: 'Вертикальные жалюзи' },
+  { value: 'roller', label: 'Рулонные шторы' },
+]
 
 type RequestModalContextValue = {
   open: (kind?: RequestModalKind) => void
@@ -26,6 +49,15 @@ export default function RequestModalProvider({ children }: { children: React.Rea
   const [name, setName] = useState('')
   const [phone, setPhone] = useState('')
   const [comment, setComment] = useState('')
+  // website — поле-ловушка для ботов, formToken — время открытия формы для сервера
+  const [website, setWebsite] = useState('')
+  const [formToken, setFormToken] = useState('')
+
+  // Форма ремонта: изделие, неисправности (код услуги → количество) и расчет
+  const [repairServices, setRepairServices] = useState<RepairService[]>([])
+  const [productType, setProductType] = useState<RepairProductType>('horizontal')
+  const [defects, setDefects] = useState<Record<string, number>>({})
+  const [repairEstimate, setRepairEstimate] = useState<RepairEstimate | null>(null)
 
   const [status, setStatus] = useState<'idle' | 'submitting' | 'success' | 'error'>('idle')
   const [errorMessage, setErrorMessage] = useState('')
@@ -41,11 +73,66 @@ export default function RequestModalProvider({ children }: { children: React.Rea
     setIsOpen(true)
     setStatus('idle')
     setErrorMessage('')
+    fetch('/api/forms/token', { cache: 'no-store' })
+      .then((res) => (res.ok ? res.json() : null))
+      .then((data) => setFormToken(data?.token ?? ''))
+      .catch(() => setFormToken(''))
+    if (nextKind === 'repair') {
+      fetch('/api/repair/services', { cache: 'no-store' })
+        .then((res) => (res.ok ? res.json() : []))
+        .then((data) => setRepairServices(Array.isArray(data) ? data : []))
+        .catch(() => setRepairServices([]))
+    }
+  }, [])
+
+  const defectList = useMemo(
+    () => Object.entries(defects).map(([serviceCode, quantity]) => ({ serviceCode, quantity })),
+    [defects]
+  )
+
+  useEffect(() => {
+    if (!isOpen || kind !== 'repair' || defectList.length === 0) {
+      setRepairEstimate(null)
+      return
+    }
+    let cancelled = false
+    fetch('/api/repair/estimate', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ productType, defects: defectList }),
+    })
+      .then((res) => (res.ok ? res.json() : null))
+      .then((data) => {
+        if (!cancelled) setRepairEstimate(data)
+      })
+      .catch(() => {
+        if (!cancelled) setRepairEstimate(null)
+      })
+    return () => {
+      cancelled = true
+    }
+  }, [isOpen, kind, productType, defectList])
+
+  const toggleDefect = (code: string) => {
+    setDefects((prev) => {
+      const next = { ...prev }
+      if (next[code]) {
+        delete next[code]
+      } else {
+        next[code] = 1
+      }
+      return next
+    })
+  }
+
+  useEffect(() => {
+    captureAttribution()
   }, [])
 
   const value = useMemo(() => ({ open, close }), [open, close])
 
-  const title = kind === 'measure' ? 'Вызвать замерщика' : 'Оставить заявку'
+  const title =
+    kind === 'measure' ? 'Вызвать замерщика' : kind === 'repair' ? 'Заявка на ремонт' : 'Оставить заявку'
 
   const submit = useCallback(async () => {
     if (!name.trim() || !phone.trim()) return
@@ -63,6 +150,13 @@ export default function RequestModalProvider({ children }: { children: React.Rea
           phone: phone.trim(),
           comment: comment.trim(),
           pageUrl: typeof window !== 'undefined' ? window.location.href : '',
+          website,
+          formToken,
+          attribution: getAttribution(),
+          repair:
+            kind === 'repair' && defectList.length > 0
+              ? { productType, defects: defectList, estimatedPrice: repairEstimate?.price }
+              : undefined,
         }),
       })
 
@@ -78,13 +172,14 @@ export default function RequestModalProvider({ children }: { children: React.Rea
         setName('')
         setPhone('')
         setComment('')
+        setDefects({})
         close()
       }, 800)
     } catch (e) {
       setStatus('error')
       setErrorMessage(e instanceof Error ? e.message : 'Не удалось отправить заявку')
     }
-  }, [name, phone, comment, kind, close])
+  }, [name, phone, comment, website, formToken, kind, productType, defectList, repairEstimate, close])
 
   return (
     <RequestModalContext.Provider value={value}>
@@ -155,6 +250,74 @@ export default function RequestModalProvider({ children }: { children: React.Rea
                   />
                 </div>
 
+                {kind === 'repair' ? (
+                  <div className="space-y-3">
+                    <div>
+                      <label className="mb-1 block text-xs font-medium text-zinc-600">Изделие</label>
+                      <select
+                        value={productType}
+                        onChange={(e) => {
+                          setProductType(e.target.value as RepairProductType)
+                          setDefects({})
+                        }}
+                        className="w-full rounded-lg border border-zinc-300 px-3 py-2 text-sm outline-none focus:border-zinc-900 focus:ring-1 focus:ring-zinc-900"
+                      >
+                        {repairProductTypes.map((t) => (
+                          <option key={t.value} value={t.value}>
+                            {t.label}
+                          </option>
+                        ))}
+                      </select>
+                    </div>
+
+                    <div>
+                      <label className="mb-1 block text-xs font-medium text-zinc-600">Что сломалось</label>
+                      <div className="space-y-2">
+                        {repairServices
+                          .filter((s) => !s.isCallOut && s.prices[productType] !== undefined)
+                          .map((s) => (
+                            <div key={s.code} className="flex items-center justify-between gap-3 text-sm text-zinc-900">
+                              <label className="flex items-center gap-2">
+                                <input
+                                  type="checkbox"
+                                  checked={Boolean(defects[s.code])}
+                                  onChange={() => toggleDefect(s.code)}
+                                />
+                                {s.name}
+                              </label>
+                              {defects[s.code] ? (
+                                <input
+                                  type="number"
+                                  min={1}
+                                  max={100}
+                                  value={defects[s.code]}
+                                  onChange={(e) =>
+                                    setDefects((prev) => ({
+                                      ...prev,
+                                      [s.code]: Math.min(100, Math.max(1, Number(e.target.value) || 1)),
+                                    }))
+                                  }
+                                  className="w-20 rounded-lg border border-zinc-300 px-2 py-1 text-sm"
+                                  aria-label={`Количество, ${s.unit}`}
+                                />
+                              ) : null}
+                            </div>
+                          ))}
+                      </div>
+                    </div>
+
+                    {repairEstimate ? (
+                      <p className="text-sm text-zinc-900">
+                        Ориентировочно:{' '}
+                        <span className="font-semibold">
+                          {repairEstimate.price.toLocaleString('ru-RU')} ₽
+                        </span>
+                        <span className="mt-1 block text-xs text-zinc-500">{repairEstimate.breakdown}</span>
+                      </p>
+                    ) : null}
+                  </div>
+                ) : null}
+
                 <div>
                   <label className="mb-1 block text-xs font-medium text-zinc-600">Комментарий</label>
                   <textarea
@@ -166,6 +329,16 @@ export default function RequestModalProvider({ children }: { children: React.Rea
                   />
                 </div>
 
+                <input
+                  value={website}
+                  onChange={(e) => setWebsite(e.target.value)}
+                  name="website"
+                  tabIndex={-1}
+                  autoComplete="off"
+                  aria-hidden="true"
+                  className="absolute left-[-10000px] h-px w-px opacity-0"
+                />
+
                 <button
                   type="button"
                   onClick={submit}
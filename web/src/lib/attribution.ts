@@ -0,0 +1,72 @@
+// Метки первого визита для заявок: UTM, referrer, страница входа, clientId Метрики.
+// Сохраняются один раз и передаются с каждой заявкой в поле attribution.
+
+export type LeadAttribution = {
+  utmSource?: string
+  utmMedium?: string
+  utmCampaign?: string
+  utmContent?: string
+  utmTerm?: string
+  referrer?: string
+  landingPage?: string
+  firstVisitAt?: string
+  clientId?: string
+}
+
+const STORAGE_KEY = 'lead_attribution'
+
+const UTM_PARAMS: Array<[string, keyof LeadAttribution]> = [
+  ['utm_source', 'utmSource'],
+  ['utm_medium', 'utmMedium'],
+  ['utm_campaign', 'utmCampaign'],
+  ['utm_content', 'utmContent'],
+  ['utm_term', 'utmTerm'],
+]
+
+function readStored(): LeadAttribution | null {
+  try {
+    const raw = window.localStorage.getItem(STORAGE_KEY)
+    return raw ? (JSON.parse(raw) as LeadAttribution) : null
+  } catch {
+    return null
+  }
+}
+
+// Яндекс Метрика хранит clientId в cookie _ym_uid
+function readClientId(): string | undefined {
+  const match = document.cookie.match(/(?:^|;\s*)_ym_uid=([^;]+)/)
+  return match ? decodeURIComponent(match[1]) : undefined
+}
+
+// captureAttribution запоминает метки первого визита. Переход по новой
+// рекламной ссылке (с utm_source) перезаписывает их.
+export function captureAttribution(): void {
+  if (typeof window === 'undefined') return
+
+  const params = new URLSearchParams(window.location.search)
+  const stored = readStored()
+  if (stored && !params.get('utm_source')) return
+
+  const next: LeadAttribution = {
+    referrer: document.referrer || undefined,
+    landingPage: window.location.href,
+    firstVisitAt: new Date().toISOString(),
+  }
+  for (const [param, key] of UTM_PARAMS) {
+    const value = params.get(param)
+    if (value) next[key] = value
+  }
+  try {
+    window.localStorage.setItem(STORAGE_KEY, JSON.stringify(next))
+  } catch {
+    // localStorage недоступен (приватный режим) — метки просто не сохранятся
+  }
+}
+
+export function getAttribution(): LeadAttribution | undefined {
+  if (typeof window === 'undefined') return undefined
+  const stored = readStored()
+  const clientId = readClientId()
+  if (!stored && !clientId) return undefined
+  return { ...stored, clientId: clientId ?? stored?.clientId }
+}
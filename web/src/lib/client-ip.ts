@@ -0,0 +1,11 @@
+import type { NextRequest } from 'next/server'
+
+// clientIp — адрес посетителя, как его передал прокси хостинга: x-real-ip
+// или последний адрес X-Forwarded-For (его добавляет сам прокси, а не браузер).
+// Бэкенд доверяет X-Forwarded-For только от адресов из TRUSTED_PROXIES.
+export function clientIp(req: NextRequest): string {
+  const realIp = req.headers.get('x-real-ip')?.trim()
+  if (realIp) return realIp
+  const forwarded = (req.headers.get('x-forwarded-for') ?? '').split(',')
+  return forwarded[forwarded.length - 1]?.trim() ?? ''
+}